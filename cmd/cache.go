@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+)
+
+//Cache command
+type Cache struct {
+	*command
+}
+
+//CacheFactory creates the command
+func CacheFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &Cache{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd cache")
+
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *Cache) Execute(args []string) (err error) { return errShowHelp("") }
+
+// Description returns long-form help text
+func (cmd *Cache) Description() string {
+	return "Group of commands used to manage the local cache of downloaded datasets. A dataset is only downloaded again when it changed since it was cached, the least recently used datasets are removed when the cache grows too large."
+}
+
+// Synopsis returns a one-line
+func (cmd *Cache) Synopsis() string {
+	return "Group of commands used to manage the local cache of downloaded datasets."
+}
+
+// Usage shows usage
+func (cmd *Cache) Usage() string { return "nerd cache <subcommand>" }
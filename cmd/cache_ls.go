@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+
+	humanize "github.com/dustin/go-humanize"
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+)
+
+//CacheLs command
+type CacheLs struct {
+	*command
+}
+
+//CacheLsFactory creates the command
+func CacheLsFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &CacheLs{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, &CacheOpts{}, flags.None, "nerd cache ls")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *CacheLs) Execute(args []string) (err error) {
+	if len(args) > 0 {
+		return errShowUsage(MessageNoArgumentRequired)
+	}
+
+	copts, ok := cmd.advancedOpts.(*CacheOpts)
+	if !ok {
+		return fmt.Errorf("unable to use cache options")
+	}
+
+	copts.NoCache = false
+	cache, err := copts.Cache()
+	if err != nil {
+		return renderConfigError(err, "failed to configure cache")
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		return renderServiceError(err, "failed to list cached datasets")
+	}
+
+	if len(entries) == 0 {
+		cmd.out.Infof("No cached dataset found.")
+		return nil
+	}
+
+	hdr := []string{"DATASET", "SIZE", "LAST USED", "CACHED AT"}
+	rows := [][]string{}
+	for _, e := range entries {
+		rows = append(rows, []string{
+			e.Dataset,
+			humanize.Bytes(uint64(e.Size)),
+			humanize.Time(e.LastUsed),
+			humanize.Time(e.Created),
+		})
+	}
+
+	return cmd.out.Table(hdr, rows)
+}
+
+// Description returns long-form help text
+func (cmd *CacheLs) Description() string { return cmd.Synopsis() }
+
+// Synopsis returns a one-line
+func (cmd *CacheLs) Synopsis() string {
+	return "Return the datasets in the local cache, the most recently used first."
+}
+
+// Usage shows usage
+func (cmd *CacheLs) Usage() string { return "nerd cache ls [OPTIONS]" }
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+
+	humanize "github.com/dustin/go-humanize"
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+)
+
+//CachePrune command
+type CachePrune struct {
+	All bool `long:"all" description:"remove all datasets from the cache instead of only those that exceed the maximum size"`
+
+	*command
+}
+
+//CachePruneFactory creates the command
+func CachePruneFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &CachePrune{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, &CacheOpts{}, flags.None, "nerd cache prune")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *CachePrune) Execute(args []string) (err error) {
+	if len(args) > 0 {
+		return errShowUsage(MessageNoArgumentRequired)
+	}
+
+	copts, ok := cmd.advancedOpts.(*CacheOpts)
+	if !ok {
+		return fmt.Errorf("unable to use cache options")
+	}
+
+	copts.NoCache = false
+	cache, err := copts.Cache()
+	if err != nil {
+		return renderConfigError(err, "failed to configure cache")
+	}
+
+	maxSize, err := humanize.ParseBytes(copts.CacheMaxSize)
+	if err != nil {
+		return renderConfigError(err, "invalid cache size '%s'", copts.CacheMaxSize)
+	}
+
+	if cmd.All {
+		maxSize = 0
+	}
+
+	removed, err := cache.Prune(int64(maxSize))
+	if err != nil {
+		return renderServiceError(err, "failed to prune cache")
+	}
+
+	var freed int64
+	for _, e := range removed {
+		freed += e.Size
+	}
+
+	cmd.out.Infof("Removed %d cached dataset(s), freed %s", len(removed), humanize.Bytes(uint64(freed)))
+	return nil
+}
+
+// Description returns long-form help text
+func (cmd *CachePrune) Description() string {
+	return cmd.Synopsis() + " The least recently used datasets are removed until the cache is no larger than '--cache-max-size'."
+}
+
+// Synopsis returns a one-line
+func (cmd *CachePrune) Synopsis() string {
+	return "Remove datasets from the local cache."
+}
+
+// Usage shows usage
+func (cmd *CachePrune) Usage() string { return "nerd cache prune [OPTIONS]" }
@@ -12,6 +12,7 @@ import (
 	flags "github.com/jessevdk/go-flags"
 	"github.com/mitchellh/cli"
 	"github.com/nerdalize/nerd/nerd/conf"
+	"github.com/nerdalize/nerd/pkg/transfer"
 	"github.com/pkg/errors"
 	"github.com/posener/complete"
 	"github.com/sirupsen/logrus"
@@ -195,6 +196,11 @@ func (cmd *command) Run(args []string) int {
 		return cmd.fail(err, "", true)
 	}
 
+	//interrupted transfers are resumed using state that is kept next to the config file
+	if loc, err := conf.GetDefaultConfigLocation(); err == nil {
+		transfer.ResumeDir = filepath.Join(filepath.Dir(loc), "resume")
+	}
+
 	if err := cmd.runFunc(remaining); err != nil {
 		switch cause := errors.Cause(err).(type) {
 		case errShowUsage:
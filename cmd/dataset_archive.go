@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+	"github.com/nerdalize/nerd/pkg/transfer"
+	transferstore "github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/nerdalize/nerd/svc"
+	"github.com/pkg/errors"
+)
+
+//DatasetArchive command
+type DatasetArchive struct {
+	StorageClass string `long:"storage-class" description:"storage class to move the dataset content to, use 'STANDARD' to move it back" default:"GLACIER"`
+
+	*command
+}
+
+//DatasetArchiveFactory creates the command
+func DatasetArchiveFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &DatasetArchive{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd dataset archive")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *DatasetArchive) Execute(args []string) (err error) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	if len(args) < 1 {
+		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
+	} else if len(args) > 1 {
+		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 1, ""))
+	}
+
+	deps, err := NewDeps(cmd.Logger(), cmd.globalOpts.KubeOpts)
+	if err != nil {
+		return renderConfigError(err, "failed to configure")
+	}
+
+	var mgr transfer.Manager
+	if mgr, err = transfer.NewKubeManager(
+		svc.NewKube(deps),
+	); err != nil {
+		return errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	h, err := mgr.Open(ctx, args[0])
+	if err != nil {
+		return renderServiceError(err, "failed to open dataset '%s'", args[0])
+	}
+
+	defer h.Close()
+	if err = h.Transition(ctx, cmd.StorageClass, transfer.NewDiscardReporter()); err != nil {
+		return renderServiceError(err, "failed to archive dataset")
+	}
+
+	cmd.out.Infof("Moved dataset '%s' to storage class '%s'", h.Name(), cmd.StorageClass)
+	if transferstore.StorageClassArchived(cmd.StorageClass) {
+		cmd.out.Infof("To download the dataset again, first use: `nerd dataset restore %s`", h.Name())
+	}
+
+	return nil
+}
+
+// Description returns long-form help text
+func (cmd *DatasetArchive) Description() string {
+	return cmd.Synopsis() + " The content is copied onto itself with the new storage class, its table of contents stays readable such that 'nerd dataset ls' keeps working. Content in the 'GLACIER' class needs to be restored with 'nerd dataset restore' before it can be downloaded. Parts that are shared with other datasets are not moved."
+}
+
+// Synopsis returns a one-line
+func (cmd *DatasetArchive) Synopsis() string {
+	return "Move the content of a dataset that is no longer used to a colder, cheaper, storage class."
+}
+
+// Usage shows usage
+func (cmd *DatasetArchive) Usage() string { return "nerd dataset archive [OPTIONS] DATASET_NAME" }
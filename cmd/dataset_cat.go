@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+	"github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/svc"
+	"github.com/pkg/errors"
+)
+
+//DatasetCat command
+type DatasetCat struct {
+	*command
+}
+
+//DatasetCatFactory creates the command
+func DatasetCatFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &DatasetCat{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd dataset cat")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *DatasetCat) Execute(args []string) (err error) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	if len(args) < 2 {
+		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 2, "s"))
+	} else if len(args) > 2 {
+		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 2, "s"))
+	}
+
+	deps, err := NewDeps(cmd.Logger(), cmd.globalOpts.KubeOpts)
+	if err != nil {
+		return renderConfigError(err, "failed to configure")
+	}
+
+	var mgr transfer.Manager
+	if mgr, err = transfer.NewKubeManager(
+		svc.NewKube(deps),
+	); err != nil {
+		return errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	h, err := mgr.Open(ctx, args[0])
+	if err != nil {
+		return renderServiceError(err, "failed to open dataset '%s'", args[0])
+	}
+
+	defer h.Close()
+	err = h.ReadFile(ctx, args[1], os.Stdout)
+	if err != nil {
+		return renderServiceError(err, "failed to read '%s' from dataset", args[1])
+	}
+
+	return nil
+}
+
+// Description returns long-form help text
+func (cmd *DatasetCat) Description() string {
+	return cmd.Synopsis() + " Only the part of the dataset that holds the file is downloaded."
+}
+
+// Synopsis returns a one-line
+func (cmd *DatasetCat) Synopsis() string {
+	return "Write a single file from a dataset to standard output."
+}
+
+// Usage shows usage
+func (cmd *DatasetCat) Usage() string { return "nerd dataset cat [OPTIONS] DATASET_NAME PATH" }
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+	datasetsv1 "github.com/nerdalize/nerd/crd/pkg/apis/stable.nerdalize.com/v1"
+	"github.com/nerdalize/nerd/svc"
+	"github.com/pkg/errors"
+)
+
+var (
+	//FetchJobMountPath is where the output dataset is mounted in the fetch job
+	FetchJobMountPath = "/output"
+
+	//FetchJobPollInterval determines how often the fetch job is checked while following it
+	FetchJobPollInterval = time.Second * 2
+)
+
+//DatasetCreate command
+type DatasetCreate struct {
+	FromURL    string `long:"from-url" description:"http(s) url to download the dataset content from, archives are extracted" required:"true"`
+	SHA256     string `long:"sha256" description:"expected hex encoded sha256 checksum of the downloaded file, the dataset is not created when it differs"`
+	NoExtract  bool   `long:"no-extract" description:"store archives as a single file instead of extracting them"`
+	FetchImage string `long:"fetch-image" description:"container image that is used to fetch the url, it must contain the nerd binary as its entrypoint" default:"nerdalize/nerd"`
+	Detach     bool   `long:"detach" description:"return when the fetch job is submitted instead of following its progress"`
+
+	*command
+}
+
+//DatasetCreateFactory creates the command
+func DatasetCreateFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &DatasetCreate{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, &TransferOpts{}, flags.None, "nerd dataset create")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *DatasetCreate) Execute(args []string) (err error) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	if len(args) < 1 {
+		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
+	} else if len(args) > 1 {
+		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 1, ""))
+	}
+
+	u, err := url.Parse(cmd.FromURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return errShowUsage(fmt.Sprintf("invalid url '%s', only http and https urls are supported", cmd.FromURL))
+	}
+
+	if cmd.SHA256 != "" {
+		if d, err := hex.DecodeString(cmd.SHA256); err != nil || len(d) != 32 {
+			return errShowUsage(fmt.Sprintf("invalid sha256 checksum '%s', expected 64 hexadecimal characters", cmd.SHA256))
+		}
+	}
+
+	kopts := cmd.globalOpts.KubeOpts
+	deps, err := NewDeps(cmd.Logger(), kopts)
+	if err != nil {
+		return renderConfigError(err, "failed to configure")
+	}
+
+	kube := svc.NewKube(deps)
+	t, ok := cmd.advancedOpts.(*TransferOpts)
+	if !ok {
+		return fmt.Errorf("unable to use transfer options")
+	}
+
+	mgr, sto, sta, err := t.TransferManager(kube)
+	if err != nil {
+		return errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	h, err := mgr.Create(ctx, args[0], *sto, *sta)
+	if err != nil {
+		return renderServiceError(err, "failed to create dataset")
+	}
+
+	defer h.Close()
+
+	//the fetch job writes into an output volume, when the job finishes the
+	//content is pushed to the dataset like any other job output
+	jargs := []string{"dataset", "fetch"}
+	if cmd.SHA256 != "" {
+		jargs = append(jargs, "--sha256", cmd.SHA256)
+	}
+	if cmd.NoExtract {
+		jargs = append(jargs, "--no-extract")
+	}
+
+	out, err := kube.RunJob(ctx, &svc.RunJobInput{
+		Image:  cmd.FetchImage,
+		Args:   append(jargs, cmd.FromURL, FetchJobMountPath),
+		Memory: "1Gi",
+		VCPU:   "1",
+		Volumes: []svc.JobVolume{{
+			MountPath:     FetchJobMountPath,
+			OutputDataset: h.Name(),
+		}},
+	})
+	if err != nil {
+		mgr.Remove(ctx, h.Name())
+		return renderServiceError(err, "failed to run fetch job")
+	}
+
+	if _, err = kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{Name: h.Name(), OutputFrom: out.Name}); err != nil {
+		return renderServiceError(err, "failed to update dataset")
+	}
+
+	cmd.out.Infof("Submitted fetch job '%s' for dataset: '%s'", out.Name, h.Name())
+	if cmd.Detach {
+		cmd.out.Infof("To see whats happening, use: 'nerd job logs %s'", out.Name)
+		return nil
+	}
+
+	err = cmd.follow(ctx, kube, out.Name, h.Name())
+	if ctx.Err() != nil {
+		cmd.out.Infof("Stopped following, the fetch job continues in the background. To see whats happening, use: 'nerd job logs %s'", out.Name)
+		return nil
+	}
+
+	return err
+}
+
+//follow reports the progress of the fetch job and waits until its output is stored in the dataset
+func (cmd *DatasetCreate) follow(ctx context.Context, kube *svc.Kube, jobName, datasetName string) error {
+	phase := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(FetchJobPollInterval):
+		}
+
+		jobs, err := kube.ListJobs(ctx, &svc.ListJobsInput{})
+		if err != nil {
+			return renderServiceError(err, "failed to list jobs")
+		}
+
+		var job *svc.ListJobItem
+		for _, item := range jobs.Items {
+			if item.Name == jobName {
+				job = item
+			}
+		}
+
+		if job == nil {
+			return errors.Errorf("fetch job '%s' no longer exists", jobName)
+		}
+
+		if p := renderItemPhase(job); p != phase {
+			phase = p
+			cmd.out.Infof("Fetch job is %s", strings.ToLower(phase))
+		}
+
+		switch phase {
+		case "Completed":
+			return cmd.waitForDataset(ctx, kube, datasetName)
+		case "Failed", "Stopped", "Deleting":
+			logs, err := kube.FetchJobLogs(ctx, &svc.FetchJobLogsInput{Name: jobName, Tail: 10})
+			if err == nil && len(strings.TrimSpace(string(logs.Data))) > 0 {
+				cmd.out.Output(strings.TrimSpace(string(logs.Data)))
+			}
+
+			return errors.Errorf("fetch job '%s' did not complete, see: 'nerd job logs %s'", jobName, jobName)
+		}
+	}
+}
+
+//waitForDataset waits for the job output to be pushed to the dataset
+func (cmd *DatasetCreate) waitForDataset(ctx context.Context, kube *svc.Kube, name string) error {
+	for {
+		ds, err := kube.GetDataset(ctx, &svc.GetDatasetInput{Name: name})
+		if err != nil {
+			return renderServiceError(err, "failed to get dataset")
+		}
+
+		switch ds.State {
+		case datasetsv1.DatasetStateReady:
+			cmd.out.Infof("Created dataset: '%s'", name)
+			return nil
+		case datasetsv1.DatasetStateFailed:
+			return errors.Errorf("failed to store fetched content in dataset '%s': %s", name, ds.StateMessage)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(FetchJobPollInterval):
+		}
+	}
+}
+
+// Description returns long-form help text
+func (cmd *DatasetCreate) Description() string {
+	return cmd.Synopsis() + " The url is downloaded by a short-lived job in the cluster, tar, tar.gz and zip archives are extracted unless '--no-extract' is provided. With '--sha256' the download is verified before it is stored."
+}
+
+// Synopsis returns a one-line
+func (cmd *DatasetCreate) Synopsis() string {
+	return "Create a dataset from content that is downloaded from an url."
+}
+
+// Usage shows usage
+func (cmd *DatasetCreate) Usage() string {
+	return "nerd dataset create [OPTIONS] --from-url URL DATASET_NAME"
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	humanize "github.com/dustin/go-humanize"
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/svc"
+	"github.com/pkg/errors"
+)
+
+//DatasetDiff command
+type DatasetDiff struct {
+	JSON bool `long:"json" description:"output the differences as json, for use in scripts and CI checks"`
+
+	*command
+}
+
+//DatasetDiffFactory creates the command
+func DatasetDiffFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &DatasetDiff{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd dataset diff")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *DatasetDiff) Execute(args []string) (err error) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	if len(args) < 2 {
+		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 2, "s"))
+	} else if len(args) > 2 {
+		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 2, "s"))
+	}
+
+	deps, err := NewDeps(cmd.Logger(), cmd.globalOpts.KubeOpts)
+	if err != nil {
+		return renderConfigError(err, "failed to configure")
+	}
+
+	var mgr transfer.Manager
+	if mgr, err = transfer.NewKubeManager(
+		svc.NewKube(deps),
+	); err != nil {
+		return errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	a, err := cmd.contents(ctx, mgr, args[0])
+	if err != nil {
+		return err
+	}
+
+	//a second argument that exists as a local directory is compared as such, otherwise
+	//it is the name of a dataset
+	dir, err := homedir.Expand(args[1])
+	if err != nil {
+		return errors.Wrap(err, "failed to expand home directory in local path")
+	}
+
+	var b *transferarchiver.TOC
+	if fi, serr := os.Stat(dir); serr == nil && fi.IsDir() {
+		b, err = transferarchiver.IndexDir(ctx, dir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to index local directory '%s'", dir)
+		}
+	} else {
+		b, err = cmd.contents(ctx, mgr, args[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	changes := a.Diff(b)
+	if cmd.JSON {
+		if changes == nil {
+			changes = []transferarchiver.TOCChange{}
+		}
+
+		return cmd.out.JSON(changes)
+	}
+
+	if len(changes) == 0 {
+		cmd.out.Infof("No differences found.")
+		return nil
+	}
+
+	for _, c := range changes {
+		switch c.Type {
+		case transferarchiver.TOCChangeAdded:
+			cmd.out.Output(fmt.Sprintf("+ %s (%s)", c.Path, humanize.Bytes(uint64(c.NewSize))))
+		case transferarchiver.TOCChangeRemoved:
+			cmd.out.Output(fmt.Sprintf("- %s (%s)", c.Path, humanize.Bytes(uint64(c.OldSize))))
+		case transferarchiver.TOCChangeModified:
+			cmd.out.Output(fmt.Sprintf("M %s (%s -> %s)", c.Path, humanize.Bytes(uint64(c.OldSize)), humanize.Bytes(uint64(c.NewSize))))
+		}
+	}
+
+	return nil
+}
+
+//contents retrieves the table of contents of the dataset with the provided name
+func (cmd *DatasetDiff) contents(ctx context.Context, mgr transfer.Manager, name string) (*transferarchiver.TOC, error) {
+	h, err := mgr.Open(ctx, name)
+	if err != nil {
+		return nil, renderServiceError(err, "failed to open dataset '%s'", name)
+	}
+
+	defer h.Close()
+	toc, err := h.Contents(ctx)
+	if err != nil {
+		return nil, renderServiceError(err, "failed to list contents of dataset '%s'", name)
+	}
+
+	return toc, nil
+}
+
+// Description returns long-form help text
+func (cmd *DatasetDiff) Description() string {
+	return cmd.Synopsis() + " The second argument is treated as a local directory when one exists at that path, otherwise as the name of a dataset. Files are compared by size and content hash."
+}
+
+// Synopsis returns a one-line
+func (cmd *DatasetDiff) Synopsis() string {
+	return "Show which files were added, removed or modified between two datasets."
+}
+
+// Usage shows usage
+func (cmd *DatasetDiff) Usage() string {
+	return "nerd dataset diff [OPTIONS] DATASET_NAME <DATASET_NAME|DIR>"
+}
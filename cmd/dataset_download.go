@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -23,16 +24,20 @@ const (
 
 //DatasetDownload command
 type DatasetDownload struct {
-	Input  string `long:"input-of" description:"specify a job name where the datasets were used as its input. Dataset name is no longer mandatory."`
-	Output string `long:"output-of" description:"specify a job name where the datasets were used as its output. Dataset name is no longer mandatory."`
+	Input  string   `long:"input-of" description:"specify a job name where the datasets were used as its input. Dataset name is no longer mandatory."`
+	Output string   `long:"output-of" description:"specify a job name where the datasets were used as its output. Dataset name is no longer mandatory."`
+	ToTar  string   `long:"to-tar" description:"write the dataset as a tar stream to this file instead of extracting it, use '-' for standard output"`
+	Link   string   `long:"from-link" description:"download a dataset that was shared with 'nerd dataset share' through its link, no access to the cluster is required"`
+	Paths  []string `long:"path" description:"download only the files at or below this path of the dataset, can be used multiple times"`
 
+	ProgressOpts
 	*command
 }
 
 //DatasetDownloadFactory creates the command
 func DatasetDownloadFactory(ui cli.Ui) cli.CommandFactory {
 	cmd := &DatasetDownload{}
-	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd dataset download")
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, &CacheOpts{}, flags.None, "nerd dataset download")
 	return func() (cli.Command, error) {
 		return cmd, nil
 	}
@@ -47,6 +52,26 @@ func (cmd *DatasetDownload) Execute(args []string) (err error) {
 		datasetName, outputDir string
 	)
 
+	if cmd.Link != "" {
+		if len(args) < 1 {
+			return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
+		} else if len(args) > 1 {
+			return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 1, ""))
+		}
+
+		return cmd.downloadLink(sigCh, args[0])
+	}
+
+	if cmd.ToTar != "" {
+		if len(args) < 1 {
+			return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
+		} else if len(args) > 1 {
+			return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 1, ""))
+		}
+
+		return cmd.downloadTar(sigCh, args[0])
+	}
+
 	switch l := len(args); {
 	case l > 2:
 		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 2, "s"))
@@ -62,6 +87,10 @@ func (cmd *DatasetDownload) Execute(args []string) (err error) {
 		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
 	}
 
+	if len(cmd.Paths) > 0 && datasetName == "" {
+		return errShowUsage("'--path' can only be used when downloading a single dataset")
+	}
+
 	//Expand tilde for homedir
 	outputDir, err = homedir.Expand(outputDir)
 	if err != nil {
@@ -78,6 +107,16 @@ func (cmd *DatasetDownload) Execute(args []string) (err error) {
 		return renderConfigError(err, "failed to configure")
 	}
 
+	copts, ok := cmd.advancedOpts.(*CacheOpts)
+	if !ok {
+		return fmt.Errorf("unable to use cache options")
+	}
+
+	cache, err := copts.Cache()
+	if err != nil {
+		return renderConfigError(err, "failed to configure cache")
+	}
+
 	kube := svc.NewKube(deps)
 	var mgr transfer.Manager
 	if mgr, err = transfer.NewKubeManager(
@@ -106,10 +145,16 @@ func (cmd *DatasetDownload) Execute(args []string) (err error) {
 		}
 
 		defer h.Close()
+		rep := cmd.Reporter()
+		if len(cmd.Paths) > 0 {
+			err = h.PullPaths(ctx, outputDir, cmd.Paths, rep)
+		} else {
+			useCache(h, cache)
+			err = h.Pull(ctx, outputDir, rep)
+		}
 
-		err = h.Pull(ctx, outputDir, &progressBarReporter{})
 		if err != nil {
-			return renderServiceError(err, "failed to download dataset")
+			return renderServiceError(reportError(rep, err), "failed to download dataset")
 		}
 
 		cmd.out.Infof("Downloaded dataset: '%s'", h.Name())
@@ -153,10 +198,12 @@ func (cmd *DatasetDownload) Execute(args []string) (err error) {
 		}
 
 		defer h.Close()
+		useCache(h, cache)
 
-		err = h.Pull(ctx, dir, &progressBarReporter{})
+		rep := cmd.Reporter()
+		err = h.Pull(ctx, dir, rep)
 		if err != nil {
-			return errors.Wrap(err, "failed to download dataset")
+			return renderServiceError(reportError(rep, err), "failed to download dataset '%s'", dataset.Name)
 		}
 	}
 
@@ -171,8 +218,116 @@ func (cmd *DatasetDownload) Execute(args []string) (err error) {
 	return nil
 }
 
+//downloadTar writes a single dataset as a tar stream, when writing to standard output
+//nothing else is printed such that the stream can be piped into other tools
+func (cmd *DatasetDownload) downloadTar(sigCh <-chan os.Signal, datasetName string) (err error) {
+	deps, err := NewDeps(cmd.Logger(), cmd.globalOpts.KubeOpts)
+	if err != nil {
+		return renderConfigError(err, "failed to configure")
+	}
+
+	var mgr transfer.Manager
+	if mgr, err = transfer.NewKubeManager(
+		svc.NewKube(deps),
+	); err != nil {
+		return errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	var (
+		w   io.Writer = os.Stdout
+		rep transfer.Reporter
+	)
+
+	rep = transfer.NewDiscardReporter()
+	if cmd.Progress == "json" {
+		rep = cmd.Reporter() //events go to standard error so they never mix with the stream
+	}
+
+	if cmd.ToTar != "-" {
+		var f *os.File
+		f, err = os.OpenFile(cmd.ToTar, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return errors.Wrap(err, "failed to create tar file")
+		}
+
+		defer f.Close()
+		w, rep = f, cmd.Reporter()
+	}
+
+	h, err := mgr.Open(ctx, datasetName)
+	if err != nil {
+		return renderServiceError(err, "failed to open dataset '%s'", datasetName)
+	}
+
+	defer h.Close()
+	err = h.PullTar(ctx, w, rep)
+	if err != nil {
+		return renderServiceError(reportError(rep, err), "failed to download dataset")
+	}
+
+	if cmd.ToTar != "-" {
+		cmd.out.Infof("Downloaded dataset '%s' to: '%s'", h.Name(), cmd.ToTar)
+	}
+
+	return nil
+}
+
+//downloadLink extracts a dataset that was shared through a link, it doesn't use the cluster
+func (cmd *DatasetDownload) downloadLink(sigCh <-chan os.Signal, outputDir string) (err error) {
+	outputDir, err = homedir.Expand(outputDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to expand home directory in dataset local path")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	m, err := transfer.FetchShare(ctx, cmd.Link)
+	if err != nil {
+		return renderServiceError(err, "failed to get shared dataset")
+	}
+
+	h, err := m.Handle()
+	if err != nil {
+		return err
+	}
+
+	defer h.Close()
+	rep := cmd.Reporter()
+	if err = h.Pull(ctx, outputDir, rep); err != nil {
+		return renderServiceError(reportError(rep, err), "failed to download dataset")
+	}
+
+	cmd.out.Infof("Downloaded shared dataset '%s' to: '%s'", h.Name(), outputDir)
+	return nil
+}
+
+//useCache makes the handle download through the cache, when one is configured
+func useCache(h transfer.Handle, cache *transfer.Cache) {
+	if sh, ok := h.(*transfer.StdHandle); ok && cache != nil {
+		sh.UseCache(cache)
+	}
+}
+
 // Description returns long-form help text
-func (cmd *DatasetDownload) Description() string { return cmd.Synopsis() }
+func (cmd *DatasetDownload) Description() string {
+	return cmd.Synopsis() + " With '--to-tar' the dataset is written as a tar stream instead, for example: 'nerd dataset download --to-tar - my-dataset | tar x -C dir'. Downloaded datasets are kept in a local cache such that they are only downloaded again when they changed, see 'nerd cache'. Datasets that were shared with 'nerd dataset share' are downloaded with '--from-link LINK DOWNLOAD_PATH', which requires no access to the cluster. Datasets that were uploaded with '--archive-format=file' or '--archive-format=zip' can be downloaded partially with one or more '--path' options."
+}
 
 // Synopsis returns a one-line
 func (cmd *DatasetDownload) Synopsis() string {
@@ -181,7 +336,7 @@ func (cmd *DatasetDownload) Synopsis() string {
 
 // Usage shows usage
 func (cmd *DatasetDownload) Usage() string {
-	return "nerd dataset [OPTIONS] download DATASET_NAME DOWNLOAD_PATH"
+	return "nerd dataset [OPTIONS] download <DATASET_NAME DOWNLOAD_PATH|--to-tar FILE DATASET_NAME|--from-link LINK DOWNLOAD_PATH>"
 }
 
 func extractDatasets(ds []*svc.ListDatasetItem, input, output string) map[string]*svc.ListDatasetItem {
@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/pkg/errors"
+)
+
+//DatasetFetch command, it runs inside the cluster as part of a fetch job
+type DatasetFetch struct {
+	SHA256    string `long:"sha256" description:"expected hex encoded sha256 checksum of the downloaded file"`
+	NoExtract bool   `long:"no-extract" description:"store archives as a single file instead of extracting them"`
+
+	*command
+}
+
+//DatasetFetchFactory creates the command
+func DatasetFetchFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &DatasetFetch{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd dataset fetch")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *DatasetFetch) Execute(args []string) (err error) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	if len(args) < 2 {
+		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 2, "s"))
+	} else if len(args) > 2 {
+		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 2, "s"))
+	}
+
+	u, dir := args[0], args[1]
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	f, err := ioutil.TempFile("", "nerd_fetch_")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary file")
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	sum, err := cmd.download(ctx, u, f)
+	if err != nil {
+		return err
+	}
+
+	if cmd.SHA256 != "" && !strings.EqualFold(cmd.SHA256, sum) {
+		return errors.Errorf("checksum mismatch, expected sha256 '%s' but downloaded content has '%s'", cmd.SHA256, sum)
+	}
+
+	cmd.out.Infof("Downloaded '%s' with sha256 '%s'", u, sum)
+
+	//content is first written to a directory next to the final files such that
+	//an interrupted fetch never leaves partial files to be stored in the dataset
+	partial := filepath.Join(dir, ".nerd-fetch-partial")
+	if err = os.MkdirAll(partial, 0755); err != nil {
+		return errors.Wrap(err, "failed to create directory for partial content")
+	}
+
+	defer os.RemoveAll(partial)
+	name := fetchFileName(u)
+	switch {
+	case cmd.NoExtract:
+		err = copyFile(f, filepath.Join(partial, name))
+	case strings.HasSuffix(name, ".tar"):
+		err = extractTar(ctx, f, partial)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		var gzr *gzip.Reader
+		if gzr, err = gzip.NewReader(f); err != nil {
+			return errors.Wrap(err, "failed to read gzip compressed content")
+		}
+
+		err = extractTar(ctx, gzr, partial)
+	case strings.HasSuffix(name, ".zip"):
+		err = extractZip(ctx, f, partial)
+	default:
+		err = copyFile(f, filepath.Join(partial, name))
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed to write downloaded content")
+	}
+
+	fis, err := ioutil.ReadDir(partial)
+	if err != nil {
+		return errors.Wrap(err, "failed to read partial content")
+	}
+
+	for _, fi := range fis {
+		if err = os.Rename(filepath.Join(partial, fi.Name()), filepath.Join(dir, fi.Name())); err != nil {
+			return errors.Wrap(err, "failed to move content into place")
+		}
+	}
+
+	cmd.out.Infof("Stored content in '%s'", dir)
+	return nil
+}
+
+//download writes the content at url 'u' to 'f' and returns its hex encoded sha256 checksum, the
+//file is positioned at the start afterwards
+func (cmd *DatasetFetch) download(ctx context.Context, u string, f *os.File) (sum string, err error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to request url")
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	//report progress at an interval, the output ends up in the job logs
+	wc := &fetchCounter{}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(time.Second * 10)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				cmd.out.Infof("Downloaded %s of %s", humanize.Bytes(wc.get()), humanize.Bytes(uint64(resp.ContentLength)))
+			}
+		}
+	}()
+
+	h := sha256.New()
+	if _, err = transferarchiver.Copy(ctx, io.MultiWriter(f, h, wc), resp.Body); err != nil {
+		return "", errors.Wrap(err, "failed to download content")
+	}
+
+	if _, err = f.Seek(0, 0); err != nil {
+		return "", errors.Wrap(err, "failed to seek to the beginning of file")
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//fetchFileName determines the name of the downloaded file from the url path
+func fetchFileName(u string) string {
+	name := "download"
+	if pu, err := url.Parse(u); err == nil && path.Base(pu.Path) != "/" && path.Base(pu.Path) != "." {
+		name = path.Base(pu.Path)
+	}
+
+	return name
+}
+
+//copyFile writes the content of 'r' to a new file at 'p'
+func copyFile(r io.Reader, p string) error {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to create file")
+	}
+
+	defer f.Close()
+	if _, err = io.Copy(f, r); err != nil {
+		return errors.Wrap(err, "failed to copy file content")
+	}
+
+	return nil
+}
+
+//extractTarget returns where an archive entry with slash separated 'name' is written in 'dir'
+func extractTarget(dir, name string) (string, error) {
+	name = path.Clean(strings.TrimLeft(name, "/"))
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", errors.Errorf("archive entry '%s' points outside of the dataset", name)
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(name)), nil
+}
+
+//extractTar writes the directories and regular files of a tar stream to 'dir'
+func extractTar(ctx context.Context, r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "failed to read next header")
+		}
+
+		target, err := extractTarget(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg, tar.TypeRegA:
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				err = copyFile(&ctxReader{ctx, tr}, target)
+			}
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "failed to extract '%s'", hdr.Name)
+		}
+	}
+}
+
+//extractZip writes the directories and regular files of a zip file to 'dir'
+func extractZip(ctx context.Context, f *os.File, dir string) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "failed to stat zip file")
+	}
+
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return errors.Wrap(err, "failed to read zip file")
+	}
+
+	for _, zf := range zr.File {
+		target, err := extractTarget(dir, zf.Name)
+		if err != nil {
+			return err
+		}
+
+		if zf.FileInfo().IsDir() {
+			if err = os.MkdirAll(target, 0755); err != nil {
+				return errors.Wrapf(err, "failed to extract '%s'", zf.Name)
+			}
+
+			continue
+		}
+
+		if !zf.Mode().IsRegular() {
+			continue //links are not supported in datasets
+		}
+
+		if err = func() error {
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+
+			defer rc.Close()
+			return copyFile(&ctxReader{ctx, rc}, target)
+		}(); err != nil {
+			return errors.Wrapf(err, "failed to extract '%s'", zf.Name)
+		}
+	}
+
+	return nil
+}
+
+//ctxReader stops reading when its context is cancelled
+type ctxReader struct {
+	ctx context.Context
+	io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.Reader.Read(p)
+}
+
+//fetchCounter counts the bytes written to it
+type fetchCounter struct {
+	n  uint64
+	mu sync.Mutex
+}
+
+func (c *fetchCounter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n += uint64(len(p))
+	return len(p), nil
+}
+
+func (c *fetchCounter) get() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+// Description returns long-form help text
+func (cmd *DatasetFetch) Description() string {
+	return cmd.Synopsis() + " This command is used by the job that 'nerd dataset create --from-url' starts in the cluster."
+}
+
+// Synopsis returns a one-line
+func (cmd *DatasetFetch) Synopsis() string {
+	return "Download an url to a directory, extracting it when it is an archive."
+}
+
+// Usage shows usage
+func (cmd *DatasetFetch) Usage() string { return "nerd dataset fetch [OPTIONS] URL DIR" }
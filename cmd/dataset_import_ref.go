@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	humanize "github.com/dustin/go-humanize"
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+	datasetsv1 "github.com/nerdalize/nerd/crd/pkg/apis/stable.nerdalize.com/v1"
+	"github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/nerdalize/nerd/svc"
+	"github.com/pkg/errors"
+)
+
+//DatasetImportRef command
+type DatasetImportRef struct {
+	AWSRegion         string `long:"aws-region" description:"AWS region of the bucket that holds the objects"`
+	CredentialsSecret string `long:"credentials-secret" description:"name of an existing secret with credentials that give read access to the objects"`
+	S3AccessKey       string `long:"s3-access-key" description:"access key that gives read access to the objects, it is stored in a new secret"`
+	S3SecretKey       string `long:"s3-secret-key" description:"secret key that gives read access to the objects, it is stored in a new secret"`
+	S3SessionToken    string `long:"s3-session-token" description:"temporary auth token, it is stored in a new secret"`
+
+	*command
+}
+
+//DatasetImportRefFactory creates the command
+func DatasetImportRefFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &DatasetImportRef{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd dataset import-ref")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *DatasetImportRef) Execute(args []string) (err error) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	if len(args) < 2 {
+		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 2, "s"))
+	} else if len(args) > 2 {
+		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 2, "s"))
+	}
+
+	bucket, prefix, err := parseS3URL(args[0])
+	if err != nil {
+		return errShowUsage(err.Error())
+	}
+
+	deps, err := NewDeps(cmd.Logger(), cmd.globalOpts.KubeOpts)
+	if err != nil {
+		return renderConfigError(err, "failed to configure")
+	}
+
+	kube := svc.NewKube(deps)
+	var mgr transfer.Manager
+	if mgr, err = transfer.NewKubeManager(
+		kube,
+	); err != nil {
+		return errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	//credentials are never stored with the dataset, when provided directly
+	//we store them in a secret that the dataset references instead
+	secret := cmd.CredentialsSecret
+	if secret == "" && cmd.S3AccessKey != "" {
+		var out *svc.CreateStoreSecretOutput
+		if out, err = kube.CreateStoreSecret(ctx, &svc.CreateStoreSecretInput{
+			AccessKey:    cmd.S3AccessKey,
+			SecretKey:    cmd.S3SecretKey,
+			SessionToken: cmd.S3SessionToken,
+		}); err != nil {
+			return renderServiceError(err, "failed to create secret for credentials")
+		}
+
+		secret = out.Name
+
+		//the secret is only kept once the dataset that references it was imported, the
+		//context may have been cancelled by then so the secret is deleted without it
+		defer func() {
+			if err != nil {
+				kube.DeleteSecret(context.Background(), &svc.DeleteSecretInput{Name: secret})
+			}
+		}()
+	}
+
+	h, err := mgr.Create(ctx, args[1], transferstore.StoreOptions{
+		Type:                     transferstore.StoreTypeS3,
+		S3StoreBucket:            bucket,
+		S3StoreAWSRegion:         cmd.AWSRegion,
+		S3StoreCredentialsSecret: secret,
+	}, transferarchiver.ArchiverOptions{
+		Type:                 transferarchiver.ArchiverTypeRef,
+		RefArchiverKeyPrefix: prefix,
+	})
+	if err != nil {
+		return renderServiceError(err, "failed to create dataset")
+	}
+
+	defer h.Close()
+	toc, err := h.Contents(ctx)
+	if err == nil && len(toc.Entries) == 0 {
+		err = errors.Errorf("no objects found under '%s'", args[0])
+	}
+
+	if err != nil {
+		mgr.Remove(ctx, h.Name())
+		return renderServiceError(err, "failed to list referenced objects")
+	}
+
+	var size uint64
+	for _, e := range toc.Entries {
+		size += uint64(e.Size)
+	}
+
+	if _, err = kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{
+		Name:  h.Name(),
+		Size:  &size,
+		State: datasetsv1.DatasetStateReady,
+	}); err != nil {
+		mgr.Remove(ctx, h.Name())
+		return renderServiceError(err, "failed to update dataset")
+	}
+
+	cmd.out.Infof("Imported dataset: '%s' referencing %d objects (%s)", h.Name(), len(toc.Entries), humanize.Bytes(size))
+	cmd.out.Infof("To use it as a job input, use: `nerd job run --input %s:/input IMAGE`", h.Name())
+	return nil
+}
+
+//parseS3URL splits an url in the form of s3://bucket/prefix into its bucket and a
+//key prefix that, when not empty, ends with a forward slash
+func parseS3URL(s string) (bucket, prefix string, err error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return "", "", errors.Errorf("invalid reference '%s', expected the 's3://bucket/prefix' format", s)
+	}
+
+	prefix = strings.TrimPrefix(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return u.Host, prefix, nil
+}
+
+// Description returns long-form help text
+func (cmd *DatasetImportRef) Description() string {
+	return cmd.Synopsis() + " Every object under the prefix becomes a file of the dataset, nothing is copied and the objects are never modified or removed by nerd."
+}
+
+// Synopsis returns a one-line
+func (cmd *DatasetImportRef) Synopsis() string {
+	return "Create a dataset that references existing objects in an S3 bucket."
+}
+
+// Usage shows usage
+func (cmd *DatasetImportRef) Usage() string {
+	return "nerd dataset import-ref [OPTIONS] s3://BUCKET/PREFIX DATASET_NAME"
+}
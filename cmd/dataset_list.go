@@ -56,13 +56,14 @@ func (cmd *DatasetList) Execute(args []string) (err error) {
 		return out.Items[i].Details.CreatedAt.After(out.Items[j].Details.CreatedAt)
 	})
 
-	hdr := []string{"DATASET", "CREATED AT", "SIZE", "INPUT FOR", "OUTPUT FROM"}
+	hdr := []string{"DATASET", "CREATED AT", "SIZE", "STATE", "INPUT FOR", "OUTPUT FROM"}
 	rows := [][]string{}
 	for _, item := range out.Items {
 		rows = append(rows, []string{
 			item.Name,
 			humanize.Time(item.Details.CreatedAt),
 			humanize.Bytes(item.Details.Size),
+			item.Details.State.String(),
 			strings.Join(item.Details.InputFor, ","),
 			strings.Join(item.Details.OutputFrom, ","),
 		})
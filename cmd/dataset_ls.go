@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	humanize "github.com/dustin/go-humanize"
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+	"github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/svc"
+	"github.com/pkg/errors"
+)
+
+//DatasetLs command
+type DatasetLs struct {
+	*command
+}
+
+//DatasetLsFactory creates the command
+func DatasetLsFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &DatasetLs{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd dataset ls")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *DatasetLs) Execute(args []string) (err error) {
+	var p string
+	switch l := len(args); {
+	case l > 2:
+		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 2, "s"))
+	case l == 2:
+		p = args[1]
+	case l < 1:
+		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
+	}
+
+	kopts := cmd.globalOpts.KubeOpts
+	deps, err := NewDeps(cmd.Logger(), kopts)
+	if err != nil {
+		return renderConfigError(err, "failed to configure")
+	}
+
+	var mgr transfer.Manager
+	if mgr, err = transfer.NewKubeManager(
+		svc.NewKube(deps),
+	); err != nil {
+		return errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, kopts.Timeout)
+	defer cancel()
+
+	h, err := mgr.Open(ctx, args[0])
+	if err != nil {
+		return renderServiceError(err, "failed to open dataset '%s'", args[0])
+	}
+
+	defer h.Close()
+	toc, err := h.Contents(ctx)
+	if err != nil {
+		return renderServiceError(err, "failed to list dataset contents")
+	}
+
+	entries := toc.List(p)
+	if len(entries) == 0 {
+		cmd.out.Infof("No files found.")
+		return nil
+	}
+
+	hdr := []string{"MODE", "SIZE", "MODIFIED", "PATH"}
+	rows := [][]string{}
+	for _, e := range entries {
+		size := humanize.Bytes(uint64(e.Size))
+		if e.Mode.IsDir() {
+			size = "-"
+		}
+
+		rows = append(rows, []string{
+			e.Mode.String(),
+			size,
+			humanize.Time(e.ModTime),
+			e.Path,
+		})
+	}
+
+	return cmd.out.Table(hdr, rows)
+}
+
+// Description returns long-form help text
+func (cmd *DatasetLs) Description() string {
+	return cmd.Synopsis() + " When a path is provided only the files at or below that path are shown."
+}
+
+// Synopsis returns a one-line
+func (cmd *DatasetLs) Synopsis() string {
+	return "List the files inside a dataset without downloading it."
+}
+
+// Usage shows usage
+func (cmd *DatasetLs) Usage() string { return "nerd dataset ls [OPTIONS] DATASET_NAME [PATH]" }
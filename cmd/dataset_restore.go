@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+	"github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/svc"
+	"github.com/pkg/errors"
+)
+
+//DatasetRestore command
+type DatasetRestore struct {
+	Days int64 `long:"days" description:"number of days the restored content stays readable" default:"7"`
+
+	*command
+}
+
+//DatasetRestoreFactory creates the command
+func DatasetRestoreFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &DatasetRestore{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd dataset restore")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *DatasetRestore) Execute(args []string) (err error) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	if len(args) < 1 {
+		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
+	} else if len(args) > 1 {
+		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 1, ""))
+	}
+
+	if cmd.Days < 1 {
+		return errShowUsage("the number of days must be at least 1")
+	}
+
+	deps, err := NewDeps(cmd.Logger(), cmd.globalOpts.KubeOpts)
+	if err != nil {
+		return renderConfigError(err, "failed to configure")
+	}
+
+	var mgr transfer.Manager
+	if mgr, err = transfer.NewKubeManager(
+		svc.NewKube(deps),
+	); err != nil {
+		return errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	h, err := mgr.Open(ctx, args[0])
+	if err != nil {
+		return renderServiceError(err, "failed to open dataset '%s'", args[0])
+	}
+
+	defer h.Close()
+	ready, err := h.Restore(ctx, cmd.Days, transfer.NewDiscardReporter())
+	if err != nil {
+		return renderServiceError(err, "failed to restore dataset")
+	}
+
+	if !ready {
+		cmd.out.Infof("Restoring dataset '%s', this can take several hours. Run this command again to check whether it completed", h.Name())
+		return nil
+	}
+
+	cmd.out.Infof("Dataset '%s' can be downloaded, use: `nerd dataset download %s`", h.Name(), h.Name())
+	return nil
+}
+
+// Description returns long-form help text
+func (cmd *DatasetRestore) Description() string {
+	return cmd.Synopsis() + " Restoring takes several hours, the command returns immediately and can be run again to check whether the restore completed. Restored content is readable for the given number of days, to move it out of the archive permanently use 'nerd dataset archive --storage-class=STANDARD' once it is restored."
+}
+
+// Synopsis returns a one-line
+func (cmd *DatasetRestore) Synopsis() string {
+	return "Make the content of an archived dataset readable again such that it can be downloaded."
+}
+
+// Usage shows usage
+func (cmd *DatasetRestore) Usage() string { return "nerd dataset restore [OPTIONS] DATASET_NAME" }
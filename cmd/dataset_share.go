@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+	"github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/svc"
+	"github.com/pkg/errors"
+)
+
+//DatasetShare command
+type DatasetShare struct {
+	Expires time.Duration `long:"expires" description:"how long the link can be used to download the dataset, at most 7 days" default:"24h"`
+
+	*command
+}
+
+//DatasetShareFactory creates the command
+func DatasetShareFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &DatasetShare{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd dataset share")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *DatasetShare) Execute(args []string) (err error) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	if len(args) < 1 {
+		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
+	} else if len(args) > 1 {
+		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 1, ""))
+	}
+
+	if cmd.Expires <= 0 || cmd.Expires > 7*24*time.Hour {
+		return errShowUsage("the link must expire within 7 days")
+	}
+
+	deps, err := NewDeps(cmd.Logger(), cmd.globalOpts.KubeOpts)
+	if err != nil {
+		return renderConfigError(err, "failed to configure")
+	}
+
+	var mgr transfer.Manager
+	if mgr, err = transfer.NewKubeManager(
+		svc.NewKube(deps),
+	); err != nil {
+		return errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	h, err := mgr.Open(ctx, args[0])
+	if err != nil {
+		return renderServiceError(err, "failed to open dataset '%s'", args[0])
+	}
+
+	defer h.Close()
+	link, err := h.Share(ctx, cmd.Expires)
+	if err != nil {
+		return renderServiceError(err, "failed to share dataset")
+	}
+
+	cmd.out.Infof("Anyone with this link can download dataset '%s' until %s:", h.Name(), time.Now().Add(cmd.Expires).Format(time.RFC1123))
+	cmd.out.Infof("%s", link)
+	cmd.out.Infof("To download it, use: `nerd dataset download --from-link '<link>' <directory>`")
+	return nil
+}
+
+// Description returns long-form help text
+func (cmd *DatasetShare) Description() string {
+	return cmd.Synopsis() + " A manifest with presigned links to every object of the dataset is stored next to it, the returned link points to this manifest. Recipients need no cluster credentials to download the dataset with 'nerd dataset download --from-link'. Links can not be revoked before they expire."
+}
+
+// Synopsis returns a one-line
+func (cmd *DatasetShare) Synopsis() string {
+	return "Create a link that allows others to download a dataset without access to the cluster."
+}
+
+// Usage shows usage
+func (cmd *DatasetShare) Usage() string { return "nerd dataset share [OPTIONS] DATASET_NAME" }
@@ -3,15 +3,19 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/pkg/errors"
 
 	"github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
 	"github.com/nerdalize/nerd/svc"
 
 	"github.com/mitchellh/cli"
@@ -20,8 +24,11 @@ import (
 
 //DatasetUpload command
 type DatasetUpload struct {
-	Name string `long:"name" short:"n" description:"assign a name to the dataset"`
+	Name    string `long:"name" short:"n" description:"assign a name to the dataset"`
+	FromTar string `long:"from-tar" description:"read the dataset as a tar stream from this file instead of a directory, use '-' for standard input. The dataset name can then be passed as the argument"`
+	Append  bool   `long:"append" description:"add the directory to an existing dataset instead of creating a new one, files with the same path are replaced. The dataset name is passed as the first argument"`
 
+	ProgressOpts
 	*command
 }
 
@@ -39,27 +46,66 @@ func (cmd *DatasetUpload) Execute(args []string) (err error) {
 	sigCh := make(chan os.Signal, 2)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
-	if len(args) < 1 {
-		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
-	} else if len(args) > 1 {
-		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 1, ""))
-	}
+	var (
+		dir  string
+		tarr io.Reader
+	)
 
-	//Expand tilde for homedir
-	dir, err := homedir.Expand(args[0])
-	if err != nil {
-		return renderServiceError(err, "failed to expand home directory in dataset local path")
-	}
+	if cmd.Append {
+		if cmd.FromTar != "" {
+			return errShowUsage("the '--append' option cannot be combined with '--from-tar'")
+		}
 
-	dir, err = filepath.Abs(dir)
-	if err != nil {
-		return renderServiceError(err, "failed to turn local path into absolute path")
+		if len(args) < 2 {
+			return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 2, "s"))
+		} else if len(args) > 2 {
+			return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 2, "s"))
+		}
+
+		cmd.Name, args = args[0], args[1:]
 	}
 
-	// check if directory exists
-	_, err = os.Open(dir)
-	if err != nil {
-		return errors.Wrap(err, "failed to upload dataset")
+	if cmd.FromTar != "" {
+		if len(args) > 1 {
+			return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 1, ""))
+		} else if len(args) == 1 {
+			cmd.Name = args[0]
+		}
+
+		tarr = os.Stdin
+		if cmd.FromTar != "-" {
+			var f *os.File
+			f, err = os.Open(cmd.FromTar)
+			if err != nil {
+				return errors.Wrap(err, "failed to open tar file")
+			}
+
+			defer f.Close()
+			tarr = f
+		}
+	} else {
+		if len(args) < 1 {
+			return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
+		} else if len(args) > 1 {
+			return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 1, ""))
+		}
+
+		//Expand tilde for homedir
+		dir, err = homedir.Expand(args[0])
+		if err != nil {
+			return renderServiceError(err, "failed to expand home directory in dataset local path")
+		}
+
+		dir, err = filepath.Abs(dir)
+		if err != nil {
+			return renderServiceError(err, "failed to turn local path into absolute path")
+		}
+
+		// check if directory exists
+		_, err = os.Open(dir)
+		if err != nil {
+			return errors.Wrap(err, "failed to upload dataset")
+		}
 	}
 
 	deps, err := NewDeps(cmd.Logger(), cmd.globalOpts.KubeOpts)
@@ -82,13 +128,28 @@ func (cmd *DatasetUpload) Execute(args []string) (err error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	var h transfer.Handle
-	if h, err = mgr.Create(
-		ctx,
-		cmd.Name,
-		*sto,
-		*sta,
-	); err != nil {
+	if cmd.Append {
+		go func() {
+			<-sigCh
+			cancel()
+		}()
+
+		return cmd.append(ctx, mgr, dir)
+	}
+
+	var (
+		h       transfer.Handle
+		resumed bool
+		ns      = deps.Namespace()
+	)
+
+	if tarr != nil {
+		h, err = mgr.Create(ctx, cmd.Name, *sto, *sta)
+	} else if h, resumed, err = createUpload(ctx, mgr, ns, dir, cmd.Name, *sto, *sta); err == nil && resumed {
+		cmd.out.Infof("Resuming earlier upload to dataset: '%s'", h.Name())
+	}
+
+	if err != nil {
 		return renderServiceError(err, "failed to create dataset with name '%s'", cmd.Name)
 	}
 
@@ -98,8 +159,25 @@ func (cmd *DatasetUpload) Execute(args []string) (err error) {
 		cancel()
 	}()
 
-	err = h.Push(ctx, dir, &progressBarReporter{})
+	rep := cmd.Reporter()
+	stored := &storeCounter{Reporter: rep}
+	if tarr != nil {
+		err = h.PushTar(ctx, tarr, rep)
+	} else {
+		err = h.Push(ctx, dir, stored)
+	}
+
 	if err != nil {
+		reportError(rep, err)
+		if tarr == nil && sta.TarArchiverPartSize > 0 && (resumed || stored.Stored() > 0) {
+			cmd.out.Infof("Upload did not complete, run the same command again to resume it")
+			return renderServiceError(err, "failed to upload dataset")
+		}
+
+		if tarr == nil {
+			transfer.RemoveUploadState(ns, dir) //nothing was stored that can be resumed
+		}
+
 		ctx := context.Background() //new context for deletion
 		e := mgr.Remove(ctx, h.Name())
 		if e != nil {
@@ -109,18 +187,88 @@ func (cmd *DatasetUpload) Execute(args []string) (err error) {
 		return renderServiceError(err, "failed to upload dataset")
 	}
 
+	if tarr == nil {
+		if err = transfer.RemoveUploadState(ns, dir); err != nil {
+			return errors.Wrap(err, "failed to clean up upload state")
+		}
+	}
+
 	cmd.out.Infof("Uploaded dataset: '%s'", h.Name())
 	cmd.out.Infof("To run a job with a dataset, use: 'nerd job run'")
 	return nil
 }
 
+//createUpload creates the dataset that 'dir' is uploaded to. When an earlier upload from 'dir' to
+//namespace 'ns' did not complete its dataset is opened instead, parts that were stored already are then skipped
+func createUpload(ctx context.Context, mgr transfer.Manager, ns, dir, name string, sto transferstore.StoreOptions, sta transferarchiver.ArchiverOptions) (h transfer.Handle, resumed bool, err error) {
+	if sta.TarArchiverPartSize <= 0 {
+		h, err = mgr.Create(ctx, name, sto, sta)
+		return h, false, err //without parts there is nothing to resume
+	}
+
+	st, err := transfer.LoadUploadState(ns, dir)
+	if err == nil && st != nil && (name == "" || name == st.Dataset) {
+		if h, err = mgr.Open(ctx, st.Dataset); err == nil {
+			return h, true, nil
+		}
+	}
+
+	if h, err = mgr.Create(ctx, name, sto, sta); err != nil {
+		return nil, false, err
+	}
+
+	if err = transfer.SaveUploadState(&transfer.UploadState{Namespace: ns, Source: dir, Dataset: h.Name()}); err != nil {
+		h.Close()
+		mgr.Remove(ctx, h.Name())
+		return nil, false, err
+	}
+
+	return h, false, nil
+}
+
+//storeCounter counts the objects that a push stored, an upload that stored none has nothing
+//that can be resumed and its dataset is removed instead
+type storeCounter struct {
+	transfer.Reporter
+	n int64
+}
+
+//HandledKey counts the stored object and informs the wrapped reporter
+func (r *storeCounter) HandledKey(k string) {
+	atomic.AddInt64(&r.n, 1)
+	r.Reporter.HandledKey(k)
+}
+
+//Stored returns the number of objects that were stored
+func (r *storeCounter) Stored() int64 { return atomic.LoadInt64(&r.n) }
+
+//append pushes 'dir' as a new layer of an existing dataset, the dataset is never
+//removed when this fails as its existing content is left untouched
+func (cmd *DatasetUpload) append(ctx context.Context, mgr transfer.Manager, dir string) (err error) {
+	h, err := mgr.Open(ctx, cmd.Name)
+	if err != nil {
+		return renderServiceError(err, "failed to open dataset '%s'", cmd.Name)
+	}
+
+	defer h.Close()
+	rep := cmd.Reporter()
+	if err = h.Append(ctx, dir, rep); err != nil {
+		return renderServiceError(reportError(rep, err), "failed to append to dataset")
+	}
+
+	cmd.out.Infof("Appended to dataset: '%s'", h.Name())
+	return nil
+}
+
 // Description returns long-form help text
-func (cmd *DatasetUpload) Description() string { return cmd.Synopsis() }
+func (cmd *DatasetUpload) Description() string {
+	return cmd.Synopsis() + " Instead of a directory a tar stream can be uploaded with '--from-tar', for example: 'tar c . | nerd dataset upload --from-tar - my-dataset'. Directory uploads that are interrupted can be resumed by running the same command again. With '--append' the content of a directory is added to an existing dataset without uploading the existing content again, for example: 'nerd dataset upload --append my-dataset ./more'."
+}
 
 // Synopsis returns a one-line
 func (cmd *DatasetUpload) Synopsis() string { return "Upload a dataset to your compute cluster." }
 
 // Usage shows usage
 func (cmd *DatasetUpload) Usage() string {
-	return "nerd dataset upload [OPTIONS] DIR_TO_UPLOAD"
+	return "nerd dataset upload [OPTIONS] <DIR_TO_UPLOAD|--from-tar FILE [DATASET_NAME]|--append DATASET_NAME DIR_TO_UPLOAD>"
 }
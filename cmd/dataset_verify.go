@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+	"github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/svc"
+	"github.com/pkg/errors"
+)
+
+//DatasetVerify command
+type DatasetVerify struct {
+	ProgressOpts
+	*command
+}
+
+//DatasetVerifyFactory creates the command
+func DatasetVerifyFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &DatasetVerify{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd dataset verify")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *DatasetVerify) Execute(args []string) (err error) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	if len(args) < 1 {
+		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
+	} else if len(args) > 1 {
+		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 1, ""))
+	}
+
+	deps, err := NewDeps(cmd.Logger(), cmd.globalOpts.KubeOpts)
+	if err != nil {
+		return renderConfigError(err, "failed to configure")
+	}
+
+	var mgr transfer.Manager
+	if mgr, err = transfer.NewKubeManager(
+		svc.NewKube(deps),
+	); err != nil {
+		return errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	h, err := mgr.Open(ctx, args[0])
+	if err != nil {
+		return renderServiceError(err, "failed to open dataset '%s'", args[0])
+	}
+
+	defer h.Close()
+	rep := cmd.Reporter()
+	mismatches, err := h.Verify(ctx, rep)
+	if err != nil {
+		return renderServiceError(reportError(rep, err), "failed to verify dataset")
+	}
+
+	if len(mismatches) == 0 {
+		cmd.out.Infof("Dataset '%s' matches what was uploaded", h.Name())
+		return nil
+	}
+
+	hdr := []string{"OBJECT", "PATH", "EXPECTED", "ACTUAL"}
+	rows := [][]string{}
+	for _, m := range mismatches {
+		rows = append(rows, []string{m.Key, m.Path, m.Expected, m.Actual})
+	}
+
+	if err = cmd.out.Table(hdr, rows); err != nil {
+		return err
+	}
+
+	return errors.Errorf("dataset '%s' does not match what was uploaded, %d object(s) or file(s) differ", h.Name(), len(mismatches))
+}
+
+// Description returns long-form help text
+func (cmd *DatasetVerify) Description() string {
+	return cmd.Synopsis() + " Every stored object is downloaded and checked against the SHA-256 digest that was recorded when the dataset was uploaded, files are checked against the digests in the table of contents. Nothing is written to disk."
+}
+
+// Synopsis returns a one-line
+func (cmd *DatasetVerify) Synopsis() string {
+	return "Check that the stored content of a dataset matches what was uploaded."
+}
+
+// Usage shows usage
+func (cmd *DatasetVerify) Usage() string { return "nerd dataset verify [OPTIONS] DATASET_NAME" }
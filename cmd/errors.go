@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/nerdalize/nerd/pkg/kubevisor"
+	"github.com/nerdalize/nerd/pkg/transfer"
 	transferstore "github.com/nerdalize/nerd/pkg/transfer/store"
 	"github.com/nerdalize/nerd/svc"
 	"github.com/pkg/errors"
@@ -61,6 +62,8 @@ func renderServiceError(err error, format string, args ...interface{}) error {
 		return errors.Errorf("%s: action took to long to complete, try again or check your internet connection", fmt.Errorf(format, args...))
 	case kubevisor.IsNetworkErr(err):
 		return errors.Errorf("%s: failed to reach the cluster, make sure you're connected to the internet and try again. Also, you can check the status page: http://status.nerdalize.com/", fmt.Errorf(format, args...))
+	case svc.IsAttemptNotExistsErr(err):
+		return errors.Errorf("%s: %v", fmt.Errorf(format, args...), err)
 	case kubevisor.IsNotExistsErr(err):
 		return errors.Errorf("%s: it does not exist", fmt.Errorf(format, args...))
 	case kubevisor.IsKubernetesErr(err):
@@ -79,6 +82,18 @@ func renderServiceError(err error, format string, args ...interface{}) error {
 		return ErrNamespaceNotSet
 	case errors.Cause(err) == ErrNotLoggedIn:
 		return ErrNotLoggedIn
+	case errors.Cause(err) == transfer.ErrFileNotExists:
+		return errors.Errorf("%s: no such file in the dataset, use 'nerd dataset ls' to see its contents", fmt.Errorf(format, args...))
+	case errors.Cause(err) == transfer.ErrNotAFile:
+		return errors.Errorf("%s: it is a directory, use 'nerd dataset ls' to see its contents", fmt.Errorf(format, args...))
+	case errors.Cause(err) == transferstore.ErrObjectArchived:
+		return errors.Errorf("%s: the dataset is archived, use 'nerd dataset restore' and try again once the restore completed", fmt.Errorf(format, args...))
+	case errors.Cause(err) == transfer.ErrPartialPullNotSupported:
+		return errors.Errorf("%s: only datasets uploaded with '--archive-format=file' or '--archive-format=zip' can be downloaded partially, use 'nerd dataset cat' for single files", fmt.Errorf(format, args...))
+	case errors.Cause(err) == transferstore.ErrAccessDenied:
+		return errors.Errorf("%s: the storage server denied access, check the credentials in the secret of the dataset", fmt.Errorf(format, args...))
+	case errors.Cause(err) == transferstore.ErrLinkDenied:
+		return errors.Errorf("%s: the link expired or is invalid, ask for a new link with 'nerd dataset share'", fmt.Errorf(format, args...))
 	case errors.Cause(err) == transferstore.ErrObjectNotExists:
 		return errors.Errorf("%s: dataset data is not available, it might still be uploading, check back again later", fmt.Errorf(format, args...))
 	default:
@@ -14,8 +14,10 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	crd "github.com/nerdalize/nerd/crd/pkg/client/clientset/versioned"
+	"github.com/nerdalize/nerd/pkg/kubevisor"
 	transfer "github.com/nerdalize/nerd/pkg/transfer"
 	transferarchiver "github.com/nerdalize/nerd/pkg/transfer/archiver"
 	"github.com/nerdalize/nerd/svc"
@@ -36,6 +38,14 @@ type DevNullLogger struct{}
 //Debugf implementation
 func (l *DevNullLogger) Debugf(format string, args ...interface{}) {}
 
+//logWriter writes transfer progress events to the log file, such that slow mounts can be diagnosed
+type logWriter struct{}
+
+func (w logWriter) Write(p []byte) (n int, err error) {
+	log.Print(string(p))
+	return len(p), nil
+}
+
 //Operation is an action that can be performed with the flex volume.
 type Operation string
 
@@ -78,6 +88,18 @@ const WriteSpace = 2 * 1024 * 1024 * 1024
 //@TODO: Spend more time checking if they make sense and are secure
 const DirectoryPermissions = os.FileMode(0522)
 
+//Node-local cache of dataset objects, such that pods on the same node that mount the
+//same input download it once. It can be configured through the environment
+const (
+	EnvCacheDir          = "NERD_FLEX_CACHE_DIR"
+	EnvCacheCapacity     = "NERD_FLEX_CACHE_CAPACITY"
+	DefaultCacheDir      = "/var/lib/kubelet/nerd-flex-cache"
+	DefaultCacheCapacity = 20 * 1024 * 1024 * 1024
+)
+
+//ProgressInterval is how often progress of a transfer is written to the log file
+const ProgressInterval = 10 * time.Second
+
 //Relative paths used for flexvolume data
 const (
 	RelPathInput         = "input"
@@ -100,7 +122,9 @@ type Output struct {
 type MountOptions struct {
 	InputDataset  string `json:"input/dataset"`
 	OutputDataset string `json:"output/dataset"`
+	LogsDataset   string `json:"logs/dataset"`
 	Namespace     string `json:"kubernetes.io/pod.namespace"`
+	PodName       string `json:"kubernetes.io/pod.name"`
 }
 
 //Capabilities represents the supported features of a flex volume.
@@ -123,6 +147,8 @@ type datasetOpts struct {
 	Namespace     string
 	InputDataset  string
 	OutputDataset string
+	LogsDataset   string
+	PodName       string
 }
 
 //writeDatasetOpts writes dataset options to a JSON file.
@@ -135,6 +161,8 @@ func (volp *DatasetVolumes) writeDatasetOpts(path string, opts MountOptions) (*d
 
 	dsopts.InputDataset = opts.InputDataset
 	dsopts.OutputDataset = opts.OutputDataset
+	dsopts.LogsDataset = opts.LogsDataset
+	dsopts.PodName = opts.PodName
 
 	f, err := os.Create(path)
 	if err != nil {
@@ -217,11 +245,39 @@ func (volp *DatasetVolumes) destroyFSInFile(path string) error {
 }
 
 func (volp *DatasetVolumes) transferManager(kube *svc.Kube) (mgr transfer.Manager, err error) {
-	if mgr, err = transfer.NewKubeManager(kube); err != nil {
+	kmgr, err := transfer.NewKubeManager(kube)
+	if err != nil {
 		return nil, errors.Wrap(err, "failed to setup transfer manager")
 	}
 
-	return mgr, nil
+	cache, err := volp.diskCache()
+	if err != nil {
+		log.Printf("warning, downloading without node cache: %v", err)
+	} else if cache != nil {
+		kmgr.UseDiskCache(cache)
+	}
+
+	return kmgr, nil
+}
+
+//diskCache sets up the node-local object cache, it returns nil when the capacity is zero
+func (volp *DatasetVolumes) diskCache() (c *transfer.DiskCache, err error) {
+	dir, capacity := DefaultCacheDir, int64(DefaultCacheCapacity)
+	if v := os.Getenv(EnvCacheDir); v != "" {
+		dir = v
+	}
+
+	if v := os.Getenv(EnvCacheCapacity); v != "" {
+		if capacity, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.Wrapf(err, "invalid cache capacity '%s'", v)
+		}
+	}
+
+	if capacity <= 0 {
+		return nil, nil
+	}
+
+	return transfer.NewDiskCache(dir, capacity)
 }
 
 //provisionInput makes the specified input available at given path (input may be nil).
@@ -243,21 +299,33 @@ func (volp *DatasetVolumes) provisionInput(path, namespace, dataset string) erro
 		return errors.Wrap(err, "failed to setup dependencies")
 	}
 
-	mgr, err := volp.transferManager(svc.NewKube(di))
+	kube := svc.NewKube(di)
+	mgr, err := volp.transferManager(kube)
 	if err != nil {
 		return errors.Wrap(err, "failed to setup transfer manager")
 	}
 
 	ctx := context.TODO() //@TODO decide on a deadline for this
 
+	ds, err := kube.GetDataset(ctx, &svc.GetDatasetInput{Name: dataset})
+	if err != nil {
+		return errors.Wrap(err, "failed to get dataset")
+	}
+
+	if !ds.State.IsReady() {
+		return errors.Errorf("dataset is not ready, its state is '%s': %s", ds.State, ds.StateMessage)
+	}
+
 	h, err := mgr.Open(ctx, dataset)
 	if err != nil {
 		return errors.Wrap(err, "failed to open dataset")
 	}
 
 	defer h.Close()
-	err = h.Pull(ctx, path, transfer.NewDiscardReporter())
+	rep := transfer.NewJSONReporter(logWriter{}, ProgressInterval)
+	err = h.Pull(ctx, path, rep)
 	if err != nil {
+		rep.Error(err)
 		return errors.Wrap(err, "failed to download dataset")
 	}
 
@@ -399,7 +467,8 @@ func (volp *DatasetVolumes) handleOutput(path, namespace, dataset string) error
 	}
 
 	defer h.Close()
-	err = h.Push(ctx, path, transfer.NewDiscardReporter())
+	rep := transfer.NewJSONReporter(logWriter{}, ProgressInterval)
+	err = h.Push(ctx, path, rep)
 
 	// The output dataset being empty is a non-fatal unmount error
 	if err != nil && strings.Contains(err.Error(), transferarchiver.ErrEmptyDirectory.Error()) {
@@ -408,12 +477,82 @@ func (volp *DatasetVolumes) handleOutput(path, namespace, dataset string) error
 	}
 
 	if err != nil {
+		rep.Error(err)
 		return errors.Wrap(err, "failed to transfer dataset")
 	}
 
 	return nil
 }
 
+//handleLogs stores the logs of the pod's main container in the logs dataset. They are appended
+//as a file named after the attempt, the files of earlier attempts are left untouched
+func (volp *DatasetVolumes) handleLogs(namespace, pod, dataset string) error {
+	log.Printf("handling logs")
+
+	// Nothing to do
+	if dataset == "" {
+		return nil
+	}
+
+	di, err := NewDeps(namespace)
+	if err != nil {
+		return errors.Wrap(err, "failed to setup dependencies")
+	}
+
+	kube := svc.NewKube(di)
+	mgr, err := volp.transferManager(kube)
+	if err != nil {
+		return errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	ctx := context.TODO() //@TODO decide on a deadline for this
+
+	h, err := mgr.Open(ctx, dataset)
+
+	// If the user has deleted the dataset, then there is nothing to do
+	if err != nil {
+		log.Printf("warning, logs dataset no longer exists: %v\n", err)
+		return nil
+	}
+
+	defer h.Close()
+	dir, err := ioutil.TempDir("", "nerd-logs")
+	if err != nil {
+		return errors.Wrap(err, "failed to create logs directory")
+	}
+
+	defer os.RemoveAll(dir)
+	f, err := ioutil.TempFile(dir, "pod-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create logs file")
+	}
+
+	out, err := kube.FetchPodLogs(ctx, &svc.FetchPodLogsInput{Name: strings.TrimPrefix(pod, kubevisor.DefaultPrefix), Output: f})
+	f.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch logs")
+	}
+
+	if out.Attempt < 1 {
+		return errors.Errorf("pod '%s' is not an attempt at running a job", pod)
+	}
+
+	//the file is named after the attempt only now that it is known
+	err = os.Rename(f.Name(), filepath.Join(dir, svc.JobLogsFile(out.Attempt)))
+	if err != nil {
+		return errors.Wrap(err, "failed to move logs file")
+	}
+
+	rep := transfer.NewJSONReporter(logWriter{}, ProgressInterval)
+	err = h.Append(ctx, dir, rep)
+	if err != nil {
+		rep.Error(err)
+		return errors.Wrap(err, "failed to transfer logs")
+	}
+
+	return nil
+}
+
 // fetchAllowedSpace is a temporary solution so we can give more space to specific users on the public cluster
 func (volp *DatasetVolumes) fetchAllowedSpace(path, namespace string) (space int64, err error) {
 	log.Printf("fetching allowed space, path= [%s], namespace = [%s]", path, namespace)
@@ -491,6 +630,11 @@ func (volp *DatasetVolumes) Mount(kubeMountPath string, opts MountOptions) (err
 		return errors.Wrap(err, "failed to write volume database")
 	}
 
+	//the logs volume is not mounted into the container, there is nothing to set up
+	if isLogsOnly(dsopts) {
+		return nil
+	}
+
 	//+TODO create kube here and inject it in provisionInput and fetchAllowedSpace
 	//TODO create a context with a deadline
 	//Set up input
@@ -564,6 +708,11 @@ func (volp *DatasetVolumes) Mount(kubeMountPath string, opts MountOptions) (err
 	return nil
 }
 
+//isLogsOnly returns whether the volume only archives the logs of the pod, it has no input or output
+func isLogsOnly(dsopts *datasetOpts) bool {
+	return dsopts.LogsDataset != "" && dsopts.InputDataset == "" && dsopts.OutputDataset == ""
+}
+
 //Unmount the flex volume.
 func (volp *DatasetVolumes) Unmount(kubeMountPath string) (err error) {
 	// Upload any output
@@ -574,6 +723,18 @@ func (volp *DatasetVolumes) Unmount(kubeMountPath string) (err error) {
 		return nil
 	}
 
+	//logs are stored at most once, failing to do so should not keep the pod from terminating
+	if isLogsOnly(dsopts) {
+		if err = volp.handleLogs(dsopts.Namespace, dsopts.PodName, dsopts.LogsDataset); err != nil {
+			log.Printf("warning, failed to store logs: %v", err)
+		}
+
+		return errors.Wrap(
+			volp.deleteDatasetOpts(volp.getPath(kubeMountPath, RelPathOptions)),
+			"failed to delete dataset",
+		)
+	}
+
 	err = volp.handleOutput(kubeMountPath, dsopts.Namespace, dsopts.OutputDataset)
 	if err != nil {
 		if !strings.Contains(err.Error(), "dataset is too big") {
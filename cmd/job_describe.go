@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	flags "github.com/jessevdk/go-flags"
+	"github.com/mitchellh/cli"
+	"github.com/nerdalize/nerd/svc"
+)
+
+//JobDescribe command
+type JobDescribe struct {
+	Tail int64 `long:"tail" short:"t" description:"number of log lines to show at the end of the output" default:"10"`
+
+	*command
+}
+
+//JobDescribeFactory creates the command
+func JobDescribeFactory(ui cli.Ui) cli.CommandFactory {
+	cmd := &JobDescribe{}
+	cmd.command = createCommand(ui, cmd.Execute, cmd.Description, cmd.Usage, cmd, nil, flags.None, "nerd job describe")
+	return func() (cli.Command, error) {
+		return cmd, nil
+	}
+}
+
+//Execute runs the command
+func (cmd *JobDescribe) Execute(args []string) (err error) {
+	if len(args) < 1 {
+		return errShowUsage(fmt.Sprintf(MessageNotEnoughArguments, 1, ""))
+	} else if len(args) > 1 {
+		return errShowUsage(fmt.Sprintf(MessageTooManyArguments, 1, ""))
+	}
+
+	kopts := cmd.globalOpts.KubeOpts
+	deps, err := NewDeps(cmd.Logger(), kopts)
+	if err != nil {
+		return renderConfigError(err, "failed to configure")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, kopts.Timeout)
+	defer cancel()
+
+	kube := svc.NewKube(deps)
+	out, err := kube.DescribeJob(ctx, &svc.DescribeJobInput{Name: args[0], Tail: cmd.Tail})
+	if err != nil {
+		return renderServiceError(err, "failed to describe job")
+	}
+
+	var q *svc.ListQuotaItem
+	if qout, err := kube.ListQuotas(ctx, &svc.ListQuotasInput{}); err == nil && len(qout.Items) > 0 {
+		q = qout.Items[0]
+	}
+
+	item := &out.ListJobItem
+	rows := [][]string{
+		{"Name:", item.Name},
+		{"Image:", item.Image},
+		{"Command:", strings.Join(out.Command, " ")},
+		{"Arguments:", strings.Join(out.Args, " ")},
+		{"Memory:", renderMemory(item.Memory) + " GB"},
+		{"vCPU:", renderVCPU(item.VCPU)},
+		{"Retries:", fmt.Sprintf("%d", out.BackoffLimit)},
+		{"Logs dataset:", renderLogsDataset(item.LogsDataset)},
+		{"Created:", renderTime(item.CreatedAt)},
+		{"Started:", renderTime(item.ActiveAt)},
+		{"Completed:", renderTime(item.CompletedAt)},
+		{"Failed:", renderTime(item.FailedAt)},
+		{"Phase:", renderItemPhase(item)},
+		{"Details:", strings.Join(renderItemDetails(item, q), ",")},
+	}
+
+	if err = cmd.out.Table(nil, rows); err != nil {
+		return err
+	}
+
+	if len(out.Env) > 0 {
+		cmd.out.Info("\nEnvironment:")
+		rows = [][]string{}
+		for _, env := range out.Env {
+			v := env.Value
+			if env.Secret {
+				v = "******"
+			}
+
+			rows = append(rows, []string{"  " + env.Name, v})
+		}
+
+		if err = cmd.out.Table(nil, rows); err != nil {
+			return err
+		}
+	}
+
+	if len(out.Volumes) > 0 {
+		cmd.out.Info("")
+		rows = [][]string{}
+		for _, vol := range out.Volumes {
+			if vol.InputDataset != "" {
+				rows = append(rows, []string{"input", vol.InputDataset, vol.MountPath})
+			}
+
+			if vol.OutputDataset != "" {
+				rows = append(rows, []string{"output", vol.OutputDataset, vol.MountPath})
+			}
+		}
+
+		if err = cmd.out.Table([]string{"DATASETS", "NAME", "MOUNTED AT"}, rows); err != nil {
+			return err
+		}
+	}
+
+	if len(out.Attempts) > 0 {
+		cmd.out.Info("")
+		rows = [][]string{}
+		for i, a := range out.Attempts {
+			rows = append(rows, []string{
+				fmt.Sprintf("%d", i+1),
+				a.Name,
+				a.Node,
+				string(a.Details.Phase),
+				renderTime(a.CreatedAt),
+				renderTime(a.StartedAt),
+				renderTime(a.FinishedAt),
+				renderAttemptReason(a),
+			})
+		}
+
+		if err = cmd.out.Table([]string{"ATTEMPTS", "POD", "NODE", "PHASE", "CREATED", "STARTED", "FINISHED", "REASON"}, rows); err != nil {
+			return err
+		}
+	}
+
+	if len(out.Events) > 0 {
+		cmd.out.Info("")
+		rows = [][]string{}
+		for _, ev := range out.Events {
+			rows = append(rows, []string{ev.Type, ev.Reason, ev.Object, fmt.Sprintf("%d", ev.Count), humanize.Time(ev.LastAt), ev.Message})
+		}
+
+		if err = cmd.out.Table([]string{"EVENTS", "REASON", "OBJECT", "COUNT", "LAST SEEN", "MESSAGE"}, rows); err != nil {
+			return err
+		}
+	}
+
+	cmd.out.Info("\nLogs:")
+	lines := string(bytes.TrimSpace(out.Logs))
+	if len(lines) < 1 {
+		cmd.out.Info("-- no visible logs returned --")
+		return nil
+	}
+
+	cmd.out.Output(lines)
+	return nil
+}
+
+//renderTime shows how long ago 't' was, or a dash when it never happened
+func renderTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return fmt.Sprintf("%s (%s)", t.Format(time.RFC3339), humanize.Time(t))
+}
+
+//renderLogsDataset shows the dataset that archives the logs, or a dash when they are not archived
+func renderLogsDataset(name string) string {
+	if name == "" {
+		return "-"
+	}
+
+	return name
+}
+
+//renderAttemptReason explains why an attempt is waiting, could not be scheduled or terminated
+func renderAttemptReason(a svc.JobAttempt) string {
+	d := a.Details
+	switch {
+	case d.TerminatedReason != "":
+		reason := fmt.Sprintf("%s, exit code %d", d.TerminatedReason, d.TerminatedExitCode)
+		if d.TerminatedMessage != "" {
+			reason += ": " + d.TerminatedMessage
+		}
+
+		return reason
+	case d.WaitingReason != "":
+		return strings.TrimSuffix(d.WaitingReason+": "+d.WaitingMessage, ": ")
+	case d.UnschedulableReason != "":
+		return strings.TrimSuffix(d.UnschedulableReason+": "+d.UnschedulableMessage, ": ")
+	}
+
+	if a.Restarts > 0 {
+		return fmt.Sprintf("restarted %d time(s)", a.Restarts)
+	}
+
+	return "-"
+}
+
+// Description returns long-form help text
+func (cmd *JobDescribe) Description() string {
+	return cmd.Synopsis() + " Shows the image, arguments, environment with secret values masked, resources and datasets of the job, followed by every attempt at running it, related events and the end of its logs."
+}
+
+// Synopsis returns a one-line
+func (cmd *JobDescribe) Synopsis() string { return "Show the full details of a job." }
+
+// Usage shows usage
+func (cmd *JobDescribe) Usage() string { return "nerd job describe [OPTIONS] JOB" }
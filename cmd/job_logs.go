@@ -4,17 +4,32 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	flags "github.com/jessevdk/go-flags"
 	"github.com/mitchellh/cli"
 	"github.com/nerdalize/nerd/pkg/kubevisor"
+	"github.com/nerdalize/nerd/pkg/transfer"
 	"github.com/nerdalize/nerd/svc"
+	"github.com/pkg/errors"
 )
 
 //JobLogs command
 type JobLogs struct {
-	Tail int64 `long:"tail" short:"t" description:"only return the oldest N lines of the process logs"`
+	Tail       int64  `long:"tail" short:"t" description:"only return the oldest N lines of the process logs"`
+	Follow     bool   `long:"follow" short:"f" description:"keep streaming logs as they are produced until the job completed or failed, logs of retried attempts are streamed as well"`
+	Since      string `long:"since" description:"only return logs newer than a relative duration like 5s, 2m, or 3h"`
+	Timestamps bool   `long:"timestamps" description:"prefix each line of the logs with the time it was written"`
+	Attempt    int    `long:"attempt" description:"only return logs of the Nth attempt at running the job, including those of a restarted container"`
+	All        bool   `long:"all-attempts" description:"return logs of every attempt at running the job, oldest first"`
+	Page       int    `long:"page" description:"logs are returned in pages of 1MiB, show the Nth page" default:"1"`
+	Output     string `long:"output" short:"o" description:"save the complete logs to this file instead of showing them"`
 
 	*command
 }
@@ -42,17 +57,52 @@ func (cmd *JobLogs) Execute(args []string) (err error) {
 		return renderConfigError(err, "failed to configure")
 	}
 
+	var since time.Duration
+	if cmd.Since != "" {
+		if since, err = time.ParseDuration(cmd.Since); err != nil {
+			return errShowUsage(fmt.Sprintf("invalid duration '%s' for '--since': %v", cmd.Since, err))
+		}
+	}
+
+	if cmd.Attempt < 0 || cmd.Page < 1 {
+		return errShowUsage("'--attempt' and '--page' must be a positive number")
+	} else if cmd.Attempt > 0 && cmd.All {
+		return errShowUsage("'--attempt' and '--all-attempts' cannot be used together")
+	} else if cmd.Follow && (cmd.Attempt > 0 || cmd.All || cmd.Page > 1 || cmd.Output != "") {
+		return errShowUsage("'--follow' cannot be used with '--attempt', '--all-attempts', '--page' or '--output'")
+	}
+
+	kube := svc.NewKube(deps)
+	if cmd.Follow {
+		return cmd.follow(kube, args[0], since)
+	}
+
 	ctx := context.Background()
 	ctx, cancel := context.WithTimeout(ctx, kopts.Timeout)
 	defer cancel()
 
 	in := &svc.FetchJobLogsInput{
-		Name: args[0],
-		Tail: cmd.Tail,
+		Name:        args[0],
+		Tail:        cmd.Tail,
+		Since:       since,
+		Timestamps:  cmd.Timestamps,
+		Attempt:     cmd.Attempt,
+		AllAttempts: cmd.All,
+		Offset:      int64(cmd.Page-1) * kubevisor.MaxLogBytes,
 	}
 
-	kube := svc.NewKube(deps)
+	if cmd.Output != "" {
+		return cmd.save(ctx, kube, in)
+	}
+
+	//once the pods are gone the logs may have been archived in a dataset
 	out, err := kube.FetchJobLogs(ctx, in)
+	if kubevisor.IsNotExistsErr(err) || (err == nil && len(out.Data) < 1 && cmd.Page == 1) {
+		if ok, aerr := cmd.archived(ctx, kube, in, os.Stdout); ok || aerr != nil {
+			return renderServiceError(aerr, "failed to fetch archived job logs")
+		}
+	}
+
 	if err != nil {
 		return renderServiceError(err, "failed to fetch job logs")
 	}
@@ -64,15 +114,152 @@ func (cmd *JobLogs) Execute(args []string) (err error) {
 	}
 
 	cmd.out.Output(strings.TrimSpace(string(out.Data))) //trim trailing newline, which is re-added by the output function
-	if int64(len(out.Data)) == kubevisor.MaxLogBytes {
-		cmd.out.Info("-- logs are trimmed after this point --")
+	if out.More {
+		cmd.out.Infof("-- logs continue after this point, use '--page %d' to show more or '--output FILE' to save the complete logs --", cmd.Page+1)
+	}
+
+	return nil
+}
+
+//save writes the complete logs to the output file, it is removed again when fetching failed
+func (cmd *JobLogs) save(ctx context.Context, kube *svc.Kube, in *svc.FetchJobLogsInput) (err error) {
+	f, err := os.Create(cmd.Output)
+	if err != nil {
+		return errors.Wrap(err, "failed to create output file")
+	}
+
+	in.Output = f
+	_, err = kube.FetchJobLogs(ctx, in)
+	if fi, serr := f.Stat(); kubevisor.IsNotExistsErr(err) || (err == nil && serr == nil && fi.Size() == 0) {
+		if ok, aerr := cmd.archived(ctx, kube, in, f); ok || aerr != nil {
+			err = aerr
+		}
+	}
+
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = errors.Wrap(cerr, "failed to close output file")
+	}
+
+	if err != nil {
+		_ = os.Remove(cmd.Output)
+		return renderServiceError(err, "failed to save job logs")
+	}
+
+	cmd.out.Infof("Logs were saved to '%s'", cmd.Output)
+	return nil
+}
+
+//archived writes the logs that were stored in the logs dataset of the job, that is where they
+//are kept once the pods are gone. It returns false when no logs were archived
+func (cmd *JobLogs) archived(ctx context.Context, kube *svc.Kube, in *svc.FetchJobLogsInput, w io.Writer) (ok bool, err error) {
+	out, err := kube.GetJobLogsDataset(ctx, &svc.GetJobLogsDatasetInput{Name: in.Name})
+	if err != nil {
+		return false, err
+	}
+
+	dataset := out.Name
+	if dataset == "" {
+		return false, nil
+	}
+
+	mgr, err := transfer.NewKubeManager(kube)
+	if err != nil {
+		return true, errors.Wrap(err, "failed to setup transfer manager")
+	}
+
+	h, err := mgr.Open(ctx, dataset)
+	if err != nil {
+		return true, err
+	}
+
+	defer h.Close()
+	toc, err := h.Contents(ctx)
+	if err != nil {
+		return true, err
+	}
+
+	//attempts are archived when they finish, which is not necessarily in the order they were made
+	all := []int{}
+	for _, e := range toc.Entries {
+		if a, ok := svc.JobLogsAttempt(e.Path); ok {
+			all = append(all, a)
+		}
+	}
+
+	if len(all) < 1 {
+		return false, nil //no attempt finished yet
+	}
+
+	sort.Ints(all)
+	attempts := all[len(all)-1:]
+	if in.AllAttempts {
+		attempts = all
+	} else if in.Attempt > 0 {
+		if _, found := toc.Lookup(svc.JobLogsFile(in.Attempt)); !found {
+			return true, errors.Errorf("job '%s' has no archived logs of attempt %d, the logs of attempt(s) %v were archived", in.Name, in.Attempt, all)
+		}
+
+		attempts = []int{in.Attempt}
+	}
+
+	cmd.out.Infof("-- showing logs that were archived in dataset '%s' --", dataset)
+	for _, a := range attempts {
+		if in.AllAttempts {
+			fmt.Fprintf(w, "-- attempt %d --\n", a)
+		}
+
+		if err = h.ReadFile(ctx, svc.JobLogsFile(a), w); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
+//follow streams the logs until the job is done, it is not bound by the timeout of other
+//requests as jobs may run for a long time. It stops early on an interrupt
+func (cmd *JobLogs) follow(kube *svc.Kube, name string, since time.Duration) (err error) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	out, err := kube.FollowJobLogs(ctx, &svc.FollowJobLogsInput{
+		Name:       name,
+		Tail:       cmd.Tail,
+		Since:      since,
+		Timestamps: cmd.Timestamps,
+		Output:     os.Stdout,
+		Attempt: func(pod string) {
+			cmd.out.Infof("-- following logs of '%s' --", pod)
+		},
+	})
+
+	if err == context.Canceled {
+		return nil //interrupted by the user
+	} else if err != nil {
+		return renderServiceError(err, "failed to follow job logs")
+	}
+
+	if out.Attempts < 1 {
+		cmd.out.Info("-- no visible logs returned --")
 	}
 
 	return nil
 }
 
 // Description returns long-form help text
-func (cmd *JobLogs) Description() string { return cmd.Synopsis() }
+func (cmd *JobLogs) Description() string {
+	return cmd.Synopsis() + " Logs of the latest attempt at running the job are shown in pages of 1MiB, use '--attempt' or '--all-attempts' to show those of earlier attempts and '--output' to save the complete logs to a file." +
+		" When the pods of a job that was run with '--archive-logs' are gone, the logs that were archived in its logs dataset are shown instead." +
+		" With '--follow' logs are streamed as they are produced until the job completed or failed, when a failed attempt is retried the logs of the next attempt are streamed as well."
+}
 
 // Synopsis returns a one-line
 func (cmd *JobLogs) Synopsis() string { return "Return logs for a running job." }
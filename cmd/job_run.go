@@ -14,6 +14,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
 	"github.com/nerdalize/nerd/svc"
 )
 
@@ -27,6 +28,12 @@ type JobRun struct {
 	Outputs    []string `long:"output" description:"specify one or more output folders that will be stored as datasets after the job is finished using the following format: <DATASET_NAME>:<JOB_DIR>"`
 	Private    bool     `long:"private" description:"use this flag with a private image, a prompt will ask for your username and password of the repository that stores the image. If NERD_IMAGE_USERNAME and/or NERD_IMAGE_PASSWORD environment variables are set, those values are used instead."`
 	CleanCreds bool     `long:"clean-creds" description:"to be used with the '--private' flag, a prompt will ask again for your image repository username and password. If NERD_IMAGE_USERNAME and/or NERD_IMAGE_PASSWORD environment variables are provided, they will be used as values to update the secret."`
+
+	ArchiveLogs   bool   `long:"archive-logs" description:"store the logs of each attempt at running the job in a new dataset, such that they can be shown after the pods are gone"`
+	LogsDataset   string `long:"logs-dataset" description:"store the logs of each attempt at running the job in this dataset, it is created when it doesn't exist. An existing dataset may only hold the logs of other jobs"`
+	NoArchiveLogs bool   `long:"no-archive-logs" description:"don't store the logs of the job in a dataset, even when the namespace does so by default"`
+
+	ProgressOpts
 	*command
 }
 
@@ -106,6 +113,14 @@ func (cmd *JobRun) Execute(args []string) (err error) {
 	if err != nil {
 		return err
 	}
+	err = checkLogsName(cmd.LogsDataset, cmd.Inputs, cmd.Outputs)
+	if err != nil {
+		return err
+	}
+
+	if cmd.NoArchiveLogs && (cmd.ArchiveLogs || cmd.LogsDataset != "") {
+		return errShowUsage("'--no-archive-logs' cannot be used with '--archive-logs' or '--logs-dataset'")
+	}
 
 	//setup job arguments
 	jargs := []string{}
@@ -155,7 +170,8 @@ func (cmd *JobRun) Execute(args []string) (err error) {
 				return cmd.rollbackDatasets(ctx, mgr, inputs, outputs, errors.Wrap(err, "failed to turn local dataset path into absolute path"))
 			}
 
-			h.handle, err = mgr.Create(ctx, "", *sto, *sta)
+			var resumed bool
+			h.handle, resumed, err = createUpload(ctx, mgr, deps.Namespace(), parts[0], "", *sto, *sta)
 			if err != nil {
 				return renderServiceError(
 					cmd.rollbackDatasets(ctx, mgr, inputs, outputs, err),
@@ -163,15 +179,37 @@ func (cmd *JobRun) Execute(args []string) (err error) {
 				)
 			}
 
+			if resumed {
+				cmd.out.Infof("Resuming earlier upload to input dataset: '%s'", h.handle.Name())
+			}
+
 			h.newDs = true
-			err = h.handle.Push(ctx, parts[0], &progressBarReporter{})
+			rep := cmd.Reporter()
+			stored := &storeCounter{Reporter: rep}
+			err = h.handle.Push(ctx, parts[0], stored)
 			if err != nil {
+				reportError(rep, err)
+				if sta.TarArchiverPartSize > 0 && (resumed || stored.Stored() > 0) {
+					//keep the dataset such that running the same command again resumes the upload
+					h.handle.Close()
+					cmd.out.Infof("Upload did not complete, run the same command again to resume it")
+					return renderServiceError(
+						cmd.rollbackDatasets(ctx, mgr, inputs, outputs, err),
+						"failed to upload dataset",
+					)
+				}
+
+				transfer.RemoveUploadState(deps.Namespace(), parts[0]) //nothing was stored that can be resumed
 				return renderServiceError(
 					cmd.rollbackDatasets(ctx, mgr, append(inputs, h), outputs, err),
 					"failed to upload dataset",
 				)
 			}
 
+			if err = transfer.RemoveUploadState(deps.Namespace(), parts[0]); err != nil {
+				return cmd.rollbackDatasets(ctx, mgr, append(inputs, h), outputs, errors.Wrap(err, "failed to clean up upload state"))
+			}
+
 			cmd.out.Infof("Uploaded input dataset: '%s'", h.handle.Name())
 		} else { //open an existing dataset
 			h.handle, err = mgr.Open(ctx, parts[0])
@@ -182,6 +220,22 @@ func (cmd *JobRun) Execute(args []string) (err error) {
 				)
 			}
 			h.newDs = false
+
+			//datasets that are still uploading or failed to upload cannot be mounted
+			var ds *svc.GetDatasetOutput
+			ds, err = kube.GetDataset(ctx, &svc.GetDatasetInput{Name: h.handle.Name()})
+			if err != nil {
+				h.handle.Close()
+				return renderServiceError(
+					cmd.rollbackDatasets(ctx, mgr, inputs, outputs, err),
+					"failed to get dataset '%s'", parts[0],
+				)
+			}
+
+			if !ds.State.IsReady() {
+				h.handle.Close()
+				return cmd.rollbackDatasets(ctx, mgr, inputs, outputs, errDatasetNotReady(ds))
+			}
 		}
 
 		//add handler for job mapping
@@ -253,6 +307,49 @@ func (cmd *JobRun) Execute(args []string) (err error) {
 		vol.OutputDataset = h.handle.Name()
 	}
 
+	//the logs are archived when asked for, or when the namespace does so by default. Users that
+	//may not read their namespace get no defaults
+	archive := cmd.ArchiveLogs || cmd.LogsDataset != ""
+	if !archive && !cmd.NoArchiveLogs {
+		if defs, derr := kube.GetJobDefaults(ctx, &svc.GetJobDefaultsInput{}); derr == nil {
+			archive = defs.ArchiveLogs
+		}
+	}
+
+	logs := []dsHandle{}
+	if archive {
+		var h dsHandle
+		if cmd.LogsDataset != "" {
+			h.handle, err = mgr.Open(ctx, cmd.LogsDataset)
+			if err == nil {
+				if err = checkLogsDataset(ctx, kube, h.handle); err != nil {
+					h.handle.Close()
+					return renderServiceError(
+						cmd.rollbackDatasets(ctx, mgr, inputs, outputs, err),
+						"failed to use logs dataset",
+					)
+				}
+			}
+		}
+
+		if cmd.LogsDataset == "" || err != nil {
+			//the logs of each attempt are appended as a layer, which only tar archives support
+			h.newDs = true
+			h.handle, err = mgr.Create(ctx, cmd.LogsDataset, *sto, transferarchiver.ArchiverOptions{Type: transferarchiver.ArchiverTypeTar})
+			if err != nil {
+				return renderServiceError(
+					cmd.rollbackDatasets(ctx, mgr, inputs, outputs, err),
+					"failed to create logs dataset",
+				)
+			}
+
+			cmd.out.Infof("Setup empty logs dataset: '%s'", h.handle.Name())
+		}
+
+		logs = append(logs, h)
+		defer h.handle.Close()
+	}
+
 	//continue with actuall creating the job
 	in := &svc.RunJobInput{
 		Image:  args[0],
@@ -269,7 +366,7 @@ func (cmd *JobRun) Execute(args []string) (err error) {
 		}
 		image, project, registry, tag := svc.ExtractRegistry(in.Image)
 		for _, secret := range secrets.Items {
-			if strings.HasPrefix(in.Image, secret.Details.Image) {
+			if secret.Details.Image != "" && strings.HasPrefix(in.Image, secret.Details.Image) { //other secrets, e.g for stores, have no image
 				if cmd.CleanCreds {
 					username, password, err := cmd.getCredentials(registry)
 					if err != nil {
@@ -308,13 +405,17 @@ func (cmd *JobRun) Execute(args []string) (err error) {
 		in.Volumes = append(in.Volumes, *vol)
 	}
 
+	for _, h := range logs {
+		in.LogsDataset = h.handle.Name()
+	}
+
 	out, err := kube.RunJob(ctx, in)
 	if err != nil {
-		cmd.rollbackDatasets(ctx, mgr, inputs, outputs, nil)
+		cmd.rollbackDatasets(ctx, mgr, inputs, append(outputs, logs...), nil)
 		return renderServiceError(err, "failed to run job")
 	}
 
-	err = updateDatasets(ctx, kube, inputs, outputs, out.Name)
+	err = updateDatasets(ctx, kube, inputs, outputs, logs, out.Name)
 	if err != nil {
 		return err
 	}
@@ -324,6 +425,15 @@ func (cmd *JobRun) Execute(args []string) (err error) {
 	return nil
 }
 
+//errDatasetNotReady explains why a dataset cannot be used as a job input
+func errDatasetNotReady(ds *svc.GetDatasetOutput) error {
+	if ds.StateMessage != "" {
+		return fmt.Errorf("dataset '%s' cannot be used as input, its state is '%s': %s", ds.Name, ds.State, ds.StateMessage)
+	}
+
+	return fmt.Errorf("dataset '%s' cannot be used as input, its state is '%s'", ds.Name, ds.State)
+}
+
 func checkResources(memory, vcpu string) error {
 	if memory != "" {
 		m, err := strconv.ParseFloat(memory, 64)
@@ -383,7 +493,7 @@ func (cmd *JobRun) getCredentials(registry string) (username, password string, e
 	return username, password, err
 }
 
-func updateDatasets(ctx context.Context, kube *svc.Kube, inputs, outputs []dsHandle, name string) error {
+func updateDatasets(ctx context.Context, kube *svc.Kube, inputs, outputs, logs []dsHandle, name string) error {
 	//add job to each dataset's InputFor
 	for _, input := range inputs {
 		_, err := kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{Name: input.handle.Name(), InputFor: name})
@@ -398,6 +508,13 @@ func updateDatasets(ctx context.Context, kube *svc.Kube, inputs, outputs []dsHan
 			return err
 		}
 	}
+	//add job to each dataset's LogsOf
+	for _, l := range logs {
+		_, err := kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{Name: l.handle.Name(), LogsOf: name})
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -414,8 +531,56 @@ func compareNames(inputs, outputs []string) error {
 	return nil
 }
 
+//checkLogsName returns an error when the logs dataset is also used as an input or output of the job
+func checkLogsName(logs string, inputs, outputs []string) error {
+	if logs == "" {
+		return nil
+	}
+
+	for _, spec := range append(append([]string{}, inputs...), outputs...) {
+		if strings.Split(spec, ":")[0] == logs {
+			return errors.Errorf("dataset '%s' cannot hold the logs of the job as it is also used as its input or output", logs)
+		}
+	}
+
+	return nil
+}
+
+//checkLogsDataset returns an error when an existing dataset cannot hold the logs of the job: the
+//logs are appended to it and it may only hold the logs of earlier jobs
+func checkLogsDataset(ctx context.Context, kube *svc.Kube, h transfer.Handle) error {
+	ds, err := kube.GetDataset(ctx, &svc.GetDatasetInput{Name: h.Name()})
+	if err != nil {
+		return err
+	}
+
+	if ds.ArchiverOptions.Type != transferarchiver.ArchiverTypeTar {
+		return errors.Errorf("dataset '%s' cannot hold the logs of the job as its %s archive cannot be appended to", h.Name(), ds.ArchiverOptions.Type)
+	}
+
+	if ds.Size == 0 {
+		return nil
+	}
+
+	toc, err := h.Contents(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range toc.Entries {
+		if _, ok := svc.JobLogsAttempt(e.Path); !ok && !e.Mode.IsDir() {
+			return errors.Errorf("dataset '%s' cannot hold the logs of the job as it holds other files, such as '%s'", h.Name(), e.Path)
+		}
+	}
+
+	return nil
+}
+
 // Description returns long-form help text
-func (cmd *JobRun) Description() string { return cmd.Synopsis() }
+func (cmd *JobRun) Description() string {
+	return cmd.Synopsis() + " With '--archive-logs' or '--logs-dataset' the logs of each attempt at running the job are stored in a dataset when the attempt finished, 'nerd job logs' shows them from there once the pods are gone." +
+		" Namespaces with the '" + svc.JobDefaultsArchiveLogsAnnotation + "=true' annotation do so for every job, unless '--no-archive-logs' is used."
+}
 
 // Synopsis returns a one-line
 func (cmd *JobRun) Synopsis() string { return "Run a job on your compute cluster." }
@@ -1,15 +1,19 @@
 package cmd
 
 import (
+	"encoding/json"
 	"os"
 	"time"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/go-playground/validator"
+	homedir "github.com/mitchellh/go-homedir"
 	crd "github.com/nerdalize/nerd/crd/pkg/client/clientset/versioned"
 	"github.com/nerdalize/nerd/pkg/kubeconfig"
 	"github.com/nerdalize/nerd/pkg/populator"
 	transfer "github.com/nerdalize/nerd/pkg/transfer"
 	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/config"
 	"github.com/nerdalize/nerd/pkg/transfer/store"
 	"github.com/nerdalize/nerd/svc"
 	"github.com/pkg/errors"
@@ -32,6 +36,18 @@ type TransferOpts struct {
 	S3SecretKey    string `long:"s3-secret-key" description:"secret key for auth with the storage backend"`
 	S3SessionToken string `long:"s3-session-token" description:"temporary auth token for the storage backend"`
 	S3Prefix       string `long:"s3-prefix" description:"store this dataset under a specific prefix"`
+	PartSize       string `long:"part-size" description:"split the dataset archive into parts of this size such that large datasets never go through a single object, use '0' to store a single archive" default:"512MB"`
+	SSE            string `long:"sse" description:"encrypt stored objects server side with 'AES256' or 'aws:kms'"`
+	SSEKMSKeyID    string `long:"sse-kms-key-id" description:"id of the KMS key used for 'aws:kms' server side encryption, the account default is used when it is empty"`
+	StorageClass   string `long:"storage-class" description:"storage class of stored objects, eg. 'STANDARD_IA' for cheaper infrequent access"`
+	ShareParts     bool   `long:"share-parts" description:"store parts in a pool that is shared with other datasets in the bucket, identical parts are then stored only once"`
+	HTTPURL        string `long:"http-url" description:"store the dataset on this HTTP or WebDAV server instead of S3"`
+	HTTPSecret     string `long:"http-credentials-secret" description:"name of a secret with a 'username' and 'password' for basic auth, or a 'token' for bearer auth, with the HTTP server"`
+	ArchiveFormat  string `long:"archive-format" description:"format of the dataset archive, 'zip' archives can be opened on any platform but are never split into parts, 'file' stores every file as a separate object such that unchanged files are not uploaded again and files can be downloaded separately" default:"tar"`
+	ArchiveConfig  string `long:"archive-config" description:"options of the archive format as a JSON object with a 'version' and 'data' field, for the standard formats these take precedence over the other flags"`
+	StoreType      string `long:"store-type" description:"type of store that keeps the dataset" default:"s3"`
+	StoreConfig    string `long:"store-config" description:"options of the store as a JSON object with a 'version' and 'data' field, for the standard stores these take precedence over the other flags"`
+	FSDir          string `long:"fs-dir" description:"directory that keeps the objects of datasets when the store type is 'fs'"`
 }
 
 //TransferManager creates a transfermanager using the command line options
@@ -50,14 +66,170 @@ func (opts TransferOpts) TransferManager(kube *svc.Kube) (mgr transfer.Manager,
 		S3StoreSecretKey: opts.S3SecretKey,
 		S3SessionToken:   opts.S3SessionToken,
 		S3StorePrefix:    opts.S3Prefix,
+
+		S3StoreServerSideEncryption: opts.SSE,
+		S3StoreSSEKMSKeyID:          opts.SSEKMSKeyID,
+		S3StoreStorageClass:         opts.StorageClass,
+	}
+
+	if opts.HTTPURL != "" {
+		sto = &transferstore.StoreOptions{
+			Type:                       transferstore.StoreTypeHTTP,
+			HTTPStoreURL:               opts.HTTPURL,
+			HTTPStoreCredentialsSecret: opts.HTTPSecret,
+		}
+	}
+
+	if opts.StoreType != "" && opts.StoreType != string(transferstore.StoreTypeS3) && opts.HTTPURL == "" {
+		sto = &transferstore.StoreOptions{Type: transferstore.StoreType(opts.StoreType), FSStoreDir: opts.FSDir}
+	}
+
+	if !storeRegistered(sto.Type) {
+		return nil, nil, nil, errors.Errorf("unsupported store type '%s', supported types are: %v", sto.Type, transfer.StoreTypes())
+	}
+
+	if opts.StoreConfig != "" {
+		sto.Config = &transferconfig.Config{}
+		if err = json.Unmarshal([]byte(opts.StoreConfig), sto.Config); err != nil {
+			return nil, nil, nil, errors.Wrap(err, "invalid store config")
+		}
+
+		if err = sto.LoadConfig(); err != nil {
+			return nil, nil, nil, errors.Wrap(err, "invalid store config")
+		}
 	}
+
+	if sto.Type == transferstore.StoreTypeFS && sto.FSStoreDir == "" {
+		return nil, nil, nil, errors.New("the 'fs' store requires a directory, configure it with --fs-dir")
+	}
+
+	if transferstore.StorageClassArchived(opts.StorageClass) {
+		return nil, nil, nil, errors.Errorf("storage class '%s' requires restores before content can be read, use 'nerd dataset archive' instead", opts.StorageClass)
+	}
+
+	partSize, err := humanize.ParseBytes(opts.PartSize)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "invalid part size '%s'", opts.PartSize)
+	}
+
 	sta = &transferarchiver.ArchiverOptions{
-		Type: transferarchiver.ArchiverTypeTar,
+		Type:                transferarchiver.ArchiverTypeTar,
+		TarArchiverPartSize: int64(partSize),
+	}
+
+	if opts.ShareParts {
+		sta.TarArchiverPoolPrefix = "pool/"
+	}
+
+	if opts.ArchiveFormat != string(transferarchiver.ArchiverTypeTar) {
+		if opts.ShareParts {
+			return nil, nil, nil, errors.Errorf("%s archives are never split into parts that can be shared", opts.ArchiveFormat)
+		}
+
+		sta.Type = transferarchiver.ArchiverType(opts.ArchiveFormat)
+		sta.TarArchiverPartSize = 0
+	}
+
+	if !archiverRegistered(sta.Type) {
+		return nil, nil, nil, errors.Errorf("unsupported archive format '%s', supported formats are: %v", sta.Type, transfer.ArchiverTypes())
+	}
+
+	if opts.ArchiveConfig != "" {
+		sta.Config = &transferconfig.Config{}
+		if err = json.Unmarshal([]byte(opts.ArchiveConfig), sta.Config); err != nil {
+			return nil, nil, nil, errors.Wrap(err, "invalid archive config")
+		}
+
+		if err = sta.LoadConfig(); err != nil {
+			return nil, nil, nil, errors.Wrap(err, "invalid archive config")
+		}
 	}
 
 	return mgr, sto, sta, nil
 }
 
+//storeRegistered returns whether stores of type 'typ' can be created
+func storeRegistered(typ transferstore.StoreType) bool {
+	for _, t := range transfer.StoreTypes() {
+		if t == typ {
+			return true
+		}
+	}
+
+	return false
+}
+
+//archiverRegistered returns whether archivers of type 'typ' can be created
+func archiverRegistered(typ transferarchiver.ArchiverType) bool {
+	for _, t := range transfer.ArchiverTypes() {
+		if t == typ {
+			return true
+		}
+	}
+
+	return false
+}
+
+//ProgressOpts hold CLI options for how transfer progress is shown
+type ProgressOpts struct {
+	Progress string `long:"progress" description:"show transfer progress as a progress 'bar', or as 'json' events on standard error with one event per line" default:"bar" choice:"bar" choice:"json"`
+}
+
+//Reporter creates the transfer reporter that was selected with the command line options
+func (opts ProgressOpts) Reporter() transfer.Reporter {
+	if opts.Progress == "json" {
+		return transfer.NewJSONReporter(os.Stderr, time.Second)
+	}
+
+	return &progressBarReporter{}
+}
+
+//errorReporter is implemented by reporters that record the error that failed a transfer
+type errorReporter interface {
+	Error(err error)
+}
+
+//reportError informs the reporter of the error that failed the transfer and returns it
+func reportError(rep transfer.Reporter, err error) error {
+	if er, ok := rep.(errorReporter); ok && err != nil {
+		er.Error(err)
+	}
+
+	return err
+}
+
+//CacheOpts hold CLI options for the local cache of downloaded datasets
+type CacheOpts struct {
+	CacheDir     string `long:"cache-dir" env:"NERD_CACHE_DIR" description:"directory that keeps downloaded datasets such that unchanged datasets are not downloaded again" default:"~/.nerd/cache"`
+	CacheMaxSize string `long:"cache-max-size" description:"remove the least recently used datasets from the cache when it grows larger than this, datasets that are larger themselves are downloaded without the cache" default:"10GB"`
+	CacheLink    bool   `long:"cache-link" description:"hardlink files from the cache instead of copying them, downloaded files must then never be modified"`
+	NoCache      bool   `long:"no-cache" description:"download datasets without using the cache"`
+}
+
+//Cache creates the local dataset cache using the command line options, it returns nil when
+//the cache is disabled
+func (opts CacheOpts) Cache() (c *transfer.Cache, err error) {
+	if opts.NoCache {
+		return nil, nil
+	}
+
+	maxSize, err := humanize.ParseBytes(opts.CacheMaxSize)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid cache size '%s'", opts.CacheMaxSize)
+	}
+
+	dir, err := homedir.Expand(opts.CacheDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to expand home directory in cache directory")
+	}
+
+	if c, err = transfer.NewCache(dir, int64(maxSize), opts.CacheLink); err != nil {
+		return nil, errors.Wrap(err, "failed to setup cache")
+	}
+
+	return c, nil
+}
+
 //KubeOpts can be used to create a Kubernetes service
 type KubeOpts struct {
 	KubeConfig string        `long:"kubeconfig" description:"file at which Nerd will look for Kubernetes credentials" env:"KUBECONFIG" default-mask:"~/.kube/config"`
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -53,3 +54,10 @@ func (o *Output) Table(header []string, rows [][]string) error {
 
 	return w.Flush()
 }
+
+//JSON will print 'v' as indented json, for consumption by other programs
+func (o *Output) JSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -88,6 +88,7 @@ func NewController(
 			key, err := cache.MetaNamespaceKeyFunc(new)
 			if err == nil {
 				queue.Add(key)
+				eventHandler.ObjectUpdated(old, new)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
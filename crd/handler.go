@@ -5,7 +5,15 @@ import (
 
 	"github.com/golang/glog"
 	datasetsv1 "github.com/nerdalize/nerd/crd/pkg/apis/stable.nerdalize.com/v1"
+	listers "github.com/nerdalize/nerd/crd/pkg/client/listers/stable.nerdalize.com/v1"
 	transferv2 "github.com/nerdalize/nerd/pkg/transfer"
+	transferstore "github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/nerdalize/nerd/svc"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
 type glogReporter struct{}
@@ -23,7 +31,75 @@ type Handler interface {
 }
 
 // S3AWS handler implements Handler interface
-type S3AWS struct{}
+type S3AWS struct {
+	kube     kubernetes.Interface
+	datasets listers.DatasetLister
+	gc       *transferv2.PoolGC
+}
+
+// NewS3AWS creates a handler that garbage collects pooled parts, references
+// to them are counted using the datasets in 'datasets'
+func NewS3AWS(kube kubernetes.Interface, datasets listers.DatasetLister, gc *transferv2.PoolGC) *S3AWS {
+	s := &S3AWS{kube: kube, datasets: datasets, gc: gc}
+	if gc != nil {
+		gc.UseStoreFactory(s.createStore)
+	}
+
+	return s
+}
+
+// createStore sets up the store of a dataset in namespace 'ns', credentials
+// that are referenced by a secret are read from that namespace
+func (s *S3AWS) createStore(ns string, sto transferstore.StoreOptions) (transferv2.Store, error) {
+	for _, name := range []string{sto.S3StoreCredentialsSecret, sto.HTTPStoreCredentialsSecret} {
+		if name == "" {
+			continue
+		}
+
+		secret, err := s.kube.CoreV1().Secrets(ns).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get store credentials from secret '%s'", name)
+		}
+
+		if name == sto.S3StoreCredentialsSecret {
+			sto.S3StoreAccessKey = string(secret.Data[svc.StoreSecretAccessKey])
+			sto.S3StoreSecretKey = string(secret.Data[svc.StoreSecretSecretKey])
+			sto.S3SessionToken = string(secret.Data[svc.StoreSecretSessionToken])
+		}
+
+		if name == sto.HTTPStoreCredentialsSecret {
+			sto.HTTPStoreUsername = string(secret.Data[svc.StoreSecretUsername])
+			sto.HTTPStorePassword = string(secret.Data[svc.StoreSecretPassword])
+			sto.HTTPStoreToken = string(secret.Data[svc.StoreSecretToken])
+		}
+	}
+
+	return transferv2.CreateStore(sto)
+}
+
+// sweep removes pooled parts that no dataset references anymore, pools are
+// listed at most once per grace period
+func (s *S3AWS) sweep() {
+	if s.gc == nil || !s.gc.Due() {
+		return
+	}
+
+	datasets, err := s.datasets.List(labels.Everything())
+	if err != nil {
+		glog.Errorf("failed to list datasets for garbage collection: %v", err)
+		return
+	}
+
+	//@TODO decide on the timeout of the sweep
+	swept, err := s.gc.Sweep(context.TODO(), transferv2.DatasetPoolRefs(datasets), &glogReporter{})
+	if err != nil {
+		glog.Errorf("failed to sweep pooled parts: %v", err)
+	}
+
+	if len(swept) > 0 {
+		glog.Infof("Removed %d unreferenced pooled parts", len(swept))
+	}
+}
 
 // ObjectCreated will be called each time an object is created
 func (s *S3AWS) ObjectCreated(obj interface{}) {
@@ -35,14 +111,18 @@ func (s *S3AWS) ObjectCreated(obj interface{}) {
 // ObjectDeleted will be called each time an object is deleted
 // If the object is a dataset, the corresponding dataset will be removed from s3
 func (s *S3AWS) ObjectDeleted(obj interface{}, key string) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj //the delete was missed while the watch was down
+	}
+
 	if dataset, ok := obj.(*datasetsv1.Dataset); ok {
-		store, err := transferv2.CreateStore(dataset.Spec.StoreOptions)
+		store, err := s.createStore(dataset.Namespace, dataset.Spec.StoreOptions)
 		if err != nil {
-			glog.Errorf("failed to create store with options '%#v': %v", dataset.Spec.StoreOptions, err)
+			glog.Errorf("failed to create store for dataset '%s': %v", key, err)
 			return
 		}
 
-		archiver, err := transferv2.CreateArchiver(dataset.Spec.ArchiverOptions)
+		archiver, err := transferv2.CreateArchiver(dataset.Spec.ArchiverOptions, store)
 		if err != nil {
 			glog.Errorf("failed to create archiver with options '%#v': %v", dataset.Spec.ArchiverOptions, err)
 			return
@@ -61,13 +141,26 @@ func (s *S3AWS) ObjectDeleted(obj interface{}, key string) {
 			return
 		}
 
+		//pooled parts are not cleared, they might be used by other datasets. The
+		//pool is tracked such that it is swept when no other dataset uses it
+		if pool, ok := transferv2.DatasetPool(dataset); ok && s.gc != nil {
+			s.gc.Track(pool)
+			s.sweep()
+		}
+
 		glog.Infof("Dataset deleted %s from namespace %s", dataset.Name, dataset.Namespace)
 	}
 }
 
 // ObjectUpdated will be called each time an object is updated
+// Periodic resyncs also call this so unreferenced pooled parts are swept
+// after their grace period
 func (s *S3AWS) ObjectUpdated(oldObj, newObj interface{}) {
-	if dataset, ok := newObj.(*datasetsv1.Dataset); ok {
-		glog.Infof("New dataset updated %s from namespace %s", dataset.Name, dataset.Namespace)
+	dataset, ok := newObj.(*datasetsv1.Dataset)
+	if !ok {
+		return
 	}
+
+	s.sweep()
+	glog.Infof("New dataset updated %s from namespace %s", dataset.Name, dataset.Namespace)
 }
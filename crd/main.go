@@ -5,16 +5,19 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 
 	clientset "github.com/nerdalize/nerd/crd/pkg/client/clientset/versioned"
 	informers "github.com/nerdalize/nerd/crd/pkg/client/informers/externalversions"
 	"github.com/nerdalize/nerd/crd/pkg/signals"
+	transferv2 "github.com/nerdalize/nerd/pkg/transfer"
 )
 
 var (
 	masterURL  string
 	kubeconfig string
+	poolGrace  time.Duration
 )
 
 func main() {
@@ -34,8 +37,17 @@ func main() {
 		glog.Fatalf("Error building dataset clientset: %s", err.Error())
 	}
 
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		glog.Fatalf("Error building kubernetes clientset: %s", err.Error())
+	}
+
 	datasetInformerFactory := informers.NewSharedInformerFactory(datasetClient, time.Second*30)
-	eventHandler := new(S3AWS)
+	eventHandler := NewS3AWS(
+		kubeClient,
+		datasetInformerFactory.Nerdalize().V1().Datasets().Lister(),
+		transferv2.NewPoolGC(poolGrace),
+	)
 
 	controller := NewController(datasetClient, datasetInformerFactory, eventHandler)
 
@@ -47,4 +59,5 @@ func main() {
 func init() {
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	flag.DurationVar(&poolGrace, "pool-grace", 10*time.Minute, "How long unreferenced pooled parts are kept before they are garbage collected.")
 }
@@ -27,6 +27,53 @@ type DatasetSpec struct {
 	Size       uint64            `json:"size"`
 	InputFor   []string          `json:"input"`
 	OutputFrom []string          `json:"output"`
+	LogsOf     []string          `json:"logs,omitempty"`
+
+	State        DatasetState `json:"state,omitempty"`
+	StateMessage string       `json:"stateMessage,omitempty"`
+
+	// Appending counts the layers that are being appended, the parts they store are not
+	// recorded in Digests until they are complete
+	Appending int `json:"appending,omitempty"`
+
+	// Digests maps the key of each stored object to the hex encoded SHA-256 digest of
+	// its content as it was pushed, it allows content to be verified when it is pulled
+	Digests map[string]string `json:"digests,omitempty"`
+}
+
+// DatasetState describes whether the content of a dataset can be used
+type DatasetState string
+
+const (
+	// DatasetStateCreating is set when the resource exists but no content was pushed yet
+	DatasetStateCreating DatasetState = "Creating"
+
+	// DatasetStateUploading is set while content is being pushed
+	DatasetStateUploading DatasetState = "Uploading"
+
+	// DatasetStateReady is set when all content was pushed successfully
+	DatasetStateReady DatasetState = "Ready"
+
+	// DatasetStateFailed is set when pushing content failed, the message explains why
+	DatasetStateFailed DatasetState = "Failed"
+
+	// DatasetStateCleared is set when the content was removed, the dataset is empty until content is pushed again
+	DatasetStateCleared DatasetState = "Cleared"
+)
+
+// IsReady returns whether the dataset content can be used, datasets that were
+// created before states were recorded have no state and are considered ready
+func (s DatasetState) IsReady() bool {
+	return s == "" || s == DatasetStateReady
+}
+
+// String returns the state for display purposes
+func (s DatasetState) String() string {
+	if s == "" {
+		return string(DatasetStateReady)
+	}
+
+	return string(s)
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -21,6 +21,9 @@ limitations under the License.
 package v1
 
 import (
+	json "encoding/json"
+
+	transferconfig "github.com/nerdalize/nerd/pkg/transfer/config"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -90,7 +93,32 @@ func (in *DatasetList) DeepCopyObject() runtime.Object {
 func (in *DatasetSpec) DeepCopyInto(out *DatasetSpec) {
 	*out = *in
 	out.StoreOptions = in.StoreOptions
+	if in.StoreOptions.Config != nil {
+		in, out := &in.StoreOptions.Config, &out.StoreOptions.Config
+		*out = new(transferconfig.Config)
+		**out = **in
+		if (*in).Data != nil {
+			in, out := &(*in).Data, &(*out).Data
+			*out = make(json.RawMessage, len(*in))
+			copy(*out, *in)
+		}
+	}
 	out.ArchiverOptions = in.ArchiverOptions
+	if in.ArchiverOptions.TarArchiverLayers != nil {
+		in, out := &in.ArchiverOptions.TarArchiverLayers, &out.ArchiverOptions.TarArchiverLayers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ArchiverOptions.Config != nil {
+		in, out := &in.ArchiverOptions.Config, &out.ArchiverOptions.Config
+		*out = new(transferconfig.Config)
+		**out = **in
+		if (*in).Data != nil {
+			in, out := &(*in).Data, &(*out).Data
+			*out = make(json.RawMessage, len(*in))
+			copy(*out, *in)
+		}
+	}
 	if in.Options != nil {
 		in, out := &in.Options, &out.Options
 		*out = make(map[string]string, len(*in))
@@ -108,6 +136,18 @@ func (in *DatasetSpec) DeepCopyInto(out *DatasetSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.LogsOf != nil {
+		in, out := &in.LogsOf, &out.LogsOf
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Digests != nil {
+		in, out := &in.Digests, &out.Digests
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -37,23 +37,37 @@ func create() *cli.CLI {
 	c := &cli.CLI{
 		Name:           name,
 		Args:           args,
-		HiddenCommands: []string{},
+		HiddenCommands: []string{"dataset fetch"},
 		Commands: map[string]cli.CommandFactory{
-			"version":          cmd.VersionFactory(version, commit, ui),
-			"login":            cmd.LoginFactory(ui),
-			"dataset":          cmd.DatasetFactory(ui),
-			"dataset upload":   cmd.DatasetUploadFactory(ui),
-			"dataset download": cmd.DatasetDownloadFactory(ui),
-			"dataset list":     cmd.DatasetListFactory(ui),
-			"dataset delete":   cmd.DatasetDeleteFactory(ui),
-			"job":              cmd.JobFactory(ui),
-			"job run":          cmd.JobRunFactory(ui),
-			"job list":         cmd.JobListFactory(ui),
-			"job logs":         cmd.JobLogsFactory(ui),
-			"job delete":       cmd.JobDeleteFactory(ui),
-			"cluster":          cmd.ClusterFactory(ui),
-			"cluster list":     cmd.ClusterListFactory(ui),
-			"cluster use":      cmd.ClusterUseFactory(ui),
+			"version":            cmd.VersionFactory(version, commit, ui),
+			"login":              cmd.LoginFactory(ui),
+			"dataset":            cmd.DatasetFactory(ui),
+			"dataset upload":     cmd.DatasetUploadFactory(ui),
+			"dataset download":   cmd.DatasetDownloadFactory(ui),
+			"dataset list":       cmd.DatasetListFactory(ui),
+			"dataset delete":     cmd.DatasetDeleteFactory(ui),
+			"dataset ls":         cmd.DatasetLsFactory(ui),
+			"dataset cat":        cmd.DatasetCatFactory(ui),
+			"dataset diff":       cmd.DatasetDiffFactory(ui),
+			"dataset import-ref": cmd.DatasetImportRefFactory(ui),
+			"dataset create":     cmd.DatasetCreateFactory(ui),
+			"dataset fetch":      cmd.DatasetFetchFactory(ui),
+			"dataset verify":     cmd.DatasetVerifyFactory(ui),
+			"dataset archive":    cmd.DatasetArchiveFactory(ui),
+			"dataset restore":    cmd.DatasetRestoreFactory(ui),
+			"dataset share":      cmd.DatasetShareFactory(ui),
+			"job":                cmd.JobFactory(ui),
+			"job run":            cmd.JobRunFactory(ui),
+			"job list":           cmd.JobListFactory(ui),
+			"job logs":           cmd.JobLogsFactory(ui),
+			"job describe":       cmd.JobDescribeFactory(ui),
+			"job delete":         cmd.JobDeleteFactory(ui),
+			"cache":              cmd.CacheFactory(ui),
+			"cache ls":           cmd.CacheLsFactory(ui),
+			"cache prune":        cmd.CachePruneFactory(ui),
+			"cluster":            cmd.ClusterFactory(ui),
+			"cluster list":       cmd.ClusterListFactory(ui),
+			"cluster use":        cmd.ClusterUseFactory(ui),
 		},
 	}
 
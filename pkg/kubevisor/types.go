@@ -1,6 +1,8 @@
 package kubevisor
 
 import (
+	"time"
+
 	crd "github.com/nerdalize/nerd/crd/pkg/client/clientset/versioned"
 	apiext "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/client-go/kubernetes"
@@ -46,10 +48,23 @@ var (
 	//ResourceTypeDaemonsets is used for daemonset management
 	ResourceTypeDaemonsets = ResourceType("daemonsets")
 
+	//ResourceTypeNamespaces is used to read namespace wide defaults
+	ResourceTypeNamespaces = ResourceType("namespaces")
+
 	//ResourceTypeCustomResourceDefinition is used for crd management
 	ResourceTypeCustomResourceDefinition = ResourceType("customresourcedefinitions")
 )
 
+//LogOptions select which logs of a container are fetched
+type LogOptions struct {
+	Tail       int64     //only the last lines, all lines when zero
+	Since      time.Time //only lines after this time, all lines when zero
+	Timestamps bool      //prefix each line with the time it was written
+	Follow     bool      //keep streaming until the container stops
+	Previous   bool      //the logs of the previous container, if the container was restarted
+	Limit      int64     //return at most this many bytes, all logs when zero
+}
+
 //ManagedNames allows for Nerd to transparently manage resources based on names and there prefixes
 type ManagedNames interface {
 	GetName() string
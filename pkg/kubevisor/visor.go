@@ -21,7 +21,7 @@ import (
 )
 
 var (
-	//MaxLogBytes determines how much logs we're gonna return, we cap it hard at this point
+	//MaxLogBytes determines how much logs we're gonna return at once, larger logs are returned in pages
 	MaxLogBytes = int64(1024 * 1024) //1MiB
 	//DefaultPrefix is used to identify a job created by the cli
 	DefaultPrefix = "nlz-nerd"
@@ -45,6 +45,11 @@ func NewVisor(ns, prefix string, api kubernetes.Interface, crd crd.Interface, ap
 	return &Visor{prefix, ns, api, crd, apiext, logs}
 }
 
+//Namespace returns the namespace in which resources are managed
+func (k *Visor) Namespace() string {
+	return k.ns
+}
+
 func (k *Visor) hasPrefix(n string) bool {
 	return strings.HasPrefix(n, k.prefix)
 }
@@ -68,7 +73,7 @@ func (k *Visor) GetResource(ctx context.Context, t ResourceType, v ManagedNames,
 	switch t {
 	case ResourceTypeJobs:
 		c = k.api.BatchV1().RESTClient()
-	case ResourceTypeSecrets:
+	case ResourceTypePods, ResourceTypeSecrets:
 		c = k.api.CoreV1().RESTClient()
 	case ResourceTypeDatasets:
 		c = k.crd.NerdalizeV1().RESTClient()
@@ -111,6 +116,8 @@ func (k *Visor) GetClusterResource(ctx context.Context, t ResourceType, v Manage
 		c = k.apiext.ApiextensionsV1beta1().RESTClient()
 	case ResourceTypeRoles, ResourceTypeRoleBindings, ResourceTypeClusterRoles, ResourceTypeClusterRoleBindings:
 		c = k.api.RbacV1().RESTClient()
+	case ResourceTypeNamespaces:
+		c = k.api.CoreV1().RESTClient()
 	default:
 		return errors.Errorf("unknown Kubernetes resource type provided: '%s'", t)
 	}
@@ -165,18 +172,29 @@ func (k *Visor) DeleteResource(ctx context.Context, t ResourceType, name string)
 }
 
 //FetchLogs will read logs from container with name 'cname' from pod 'pname' and write it to writer 'w'
-func (k *Visor) FetchLogs(ctx context.Context, tail int64, w io.Writer, cname, pname string) (err error) {
-	taill := &tail
-	if tail < 1 {
-		taill = nil
+func (k *Visor) FetchLogs(ctx context.Context, opts LogOptions, w io.Writer, cname, pname string) (err error) {
+	popts := &corev1.PodLogOptions{
+		Container:  cname,
+		Timestamps: opts.Timestamps,
+		Follow:     opts.Follow,
+		Previous:   opts.Previous,
+	}
+
+	if opts.Tail > 0 {
+		popts.TailLines = &opts.Tail //can be nil if we dont want to tail
+	}
+
+	if !opts.Since.IsZero() {
+		since := metav1.NewTime(opts.Since)
+		popts.SinceTime = &since
+	}
+
+	if opts.Limit > 0 {
+		popts.LimitBytes = &opts.Limit
 	}
 
 	pname = k.applyPrefix(pname)
-	req := k.api.CoreV1().Pods(k.ns).GetLogs(pname, &corev1.PodLogOptions{
-		Container:  cname,
-		TailLines:  taill, //can be nil if we dont want to tail
-		LimitBytes: &MaxLogBytes,
-	})
+	req := k.api.CoreV1().Pods(k.ns).GetLogs(pname, popts)
 
 	req = req.Context(ctx)
 	rc, err := req.Stream()
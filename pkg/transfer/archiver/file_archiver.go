@@ -0,0 +1,559 @@
+package transferarchiver
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	slashpath "path"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	//FileArchiverManifestKey configures the key of the manifest that lists the files of the dataset
+	FileArchiverManifestKey = "manifest.json"
+
+	//FileArchiverFilesPrefix configures the prefix, below the key prefix, of the file objects
+	FileArchiverFilesPrefix = "files/"
+
+	//FileArchiverConcurrency is the number of files that are downloaded at the same time
+	FileArchiverConcurrency = 8
+)
+
+//ObjectGetter reads objects as a whole and in ranges
+type ObjectGetter interface {
+	ObjectReader
+	Get(ctx context.Context, k string, w io.WriterAt) error
+}
+
+//FileArchiver stores every file as a separate object that is named after its content, the
+//manifest is a table of contents that maps paths to these objects. Files that did not change
+//are not uploaded again and subsets of the dataset can be downloaded in parallel
+type FileArchiver struct {
+	keyPrefix string
+	sizeLimit int64
+	objects   ObjectGetter
+}
+
+//NewFileArchiver will setup the file archiver, 'objects' is used to download files in parallel
+func NewFileArchiver(opts ArchiverOptions, objects ObjectGetter) (a *FileArchiver, err error) {
+	a = &FileArchiver{keyPrefix: opts.KeyPrefix, sizeLimit: opts.SizeLimit, objects: objects}
+	if a.keyPrefix != "" && !strings.HasSuffix(a.keyPrefix, "/") {
+		return nil, errors.Errorf("archiver key prefix must end with a forward slash")
+	}
+
+	if opts.TarArchiverPartSize > 0 || opts.TarArchiverPoolPrefix != "" || len(opts.TarArchiverLayers) > 0 {
+		return nil, errors.Errorf("file archives cannot be split into parts or layers")
+	}
+
+	if a.objects == nil {
+		return nil, errors.New("file archiver requires a store to read objects from")
+	}
+
+	return a, nil
+}
+
+//Options returns the options the archiver can be setup with again
+func (a *FileArchiver) Options() ArchiverOptions {
+	return ArchiverOptions{
+		Type:      ArchiverTypeFile,
+		KeyPrefix: a.keyPrefix,
+		SizeLimit: a.sizeLimit,
+	}
+}
+
+func (a *FileArchiver) manifestKey() string {
+	return slashpath.Join(a.keyPrefix, FileArchiverManifestKey)
+}
+
+//fileKey returns the key of a file with digest 'sum'
+func (a *FileArchiver) fileKey(sum string) string {
+	return slashpath.Join(a.keyPrefix, FileArchiverFilesPrefix, sum)
+}
+
+//Index calls 'fn' for the manifest, the file objects are listed by it and are returned by PartIndex
+func (a *FileArchiver) Index(fn func(k string) error) error {
+	return fn(a.manifestKey())
+}
+
+//PartIndex calls 'fn' for every file object that is listed in the manifest, which 'get' is called for
+func (a *FileArchiver) PartIndex(ctx context.Context, get func(k string, w io.WriterAt) error, fn func(k string) error) error {
+	toc, err := a.Contents(ctx, get)
+	if errors.Cause(err) == transferstore.ErrObjectNotExists {
+		return nil //nothing was pushed yet, or the upload was interrupted before the manifest
+	} else if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, e := range toc.Entries {
+		if e.Key == "" || seen[e.Key] {
+			continue //files with the same content share an object
+		}
+
+		seen[e.Key] = true
+		if err = fn(e.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Immutable returns whether the object at 'k' is named after its content
+func (a *FileArchiver) Immutable(k string) bool {
+	return strings.HasPrefix(k, slashpath.Join(a.keyPrefix, FileArchiverFilesPrefix)+"/")
+}
+
+//ParallelGets returns true, files are downloaded in parallel and their progress is
+//reported while unarchiving
+func (a *FileArchiver) ParallelGets() bool {
+	return true
+}
+
+//Metadata returns whether the object at 'k' only describes the content
+func (a *FileArchiver) Metadata(k string) bool {
+	return k == a.manifestKey()
+}
+
+//Contents returns the manifest
+func (a *FileArchiver) Contents(ctx context.Context, fn func(k string, w io.WriterAt) error) (toc *TOC, err error) {
+	buf := &writeAtBuffer{}
+	if err = fn(a.manifestKey(), buf); err != nil {
+		return nil, errors.Wrap(err, "failed to get manifest")
+	}
+
+	return decodeTOC(bytes.NewReader(buf.buf))
+}
+
+//Archive will call 'fn' for every file in the directory at 'path' and then for the manifest
+func (a *FileArchiver) Archive(ctx context.Context, path string, rep Reporter, fn func(k string, r io.ReadSeeker, nbytes int64) error) (err error) {
+	if err = checkValidDir(path); err != nil {
+		return err
+	}
+
+	var total int64
+	if err = walkDir(path, func(p, rel string, fi os.FileInfo) error {
+		if fi.Mode().IsRegular() {
+			total += fi.Size()
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "failed to index filesystem")
+	}
+
+	//files never go through a single temporary file or object, they are only limited
+	//when a limit is configured explicitely
+	if a.sizeLimit > 0 && total > a.sizeLimit {
+		return errors.Errorf(ErrDatasetTooLarge, humanize.Bytes(uint64(a.sizeLimit)))
+	}
+
+	toc := &TOC{}
+	inc := rep.StartArchivingProgress(path, total)
+	if err = walkDir(path, func(p, rel string, fi os.FileInfo) error {
+		e := TOCEntry{
+			Path:    strings.Join(strings.Split(rel, string(filepath.Separator)), TarArchiverPathSeparator),
+			Mode:    fi.Mode(),
+			ModTime: fi.ModTime(),
+		}
+
+		if !fi.Mode().IsRegular() {
+			if fi.IsDir() {
+				toc.Entries = append(toc.Entries, e)
+			}
+
+			return nil //symlinks are not supported in datasets
+		}
+
+		//the digest names the object so it is known before the file is stored
+		sum, err := hashFile(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return errors.Wrap(err, "failed to open file for archiving")
+		}
+
+		defer f.Close()
+		e.Size, e.SHA256, e.Key = fi.Size(), sum, a.fileKey(sum)
+		if err = fn(e.Key, f, e.Size); err != nil {
+			return err
+		}
+
+		toc.Entries = append(toc.Entries, e)
+		inc(e.Size)
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "failed to perform filesystem walk")
+	}
+
+	rep.StopArchivingProgress()
+	return a.finish(toc, fn)
+}
+
+//ArchiveTar will read a tar stream from 'r' and call 'fn' for each of its files and then for
+//the manifest. Entry names are normalized and the same size limit as for directories applies
+func (a *FileArchiver) ArchiveTar(ctx context.Context, r io.Reader, rep Reporter, fn func(k string, r io.ReadSeeker, nbytes int64) error) (err error) {
+	tmpf, err := ioutil.TempFile("", "file_archiver_")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary file")
+	}
+
+	defer os.Remove(tmpf.Name())
+	defer tmpf.Close()
+	inc := rep.StartArchivingProgress(tmpf.Name(), 0) //total is unknown for streams
+
+	var total int64
+	toc := &TOC{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "failed to read next header")
+		}
+
+		name := slashpath.Clean(strings.TrimLeft(hdr.Name, TarArchiverPathSeparator))
+		if name == "." {
+			continue //the root of the stream, our archives don't include it
+		}
+
+		if name == ".." || strings.HasPrefix(name, "../") {
+			return errors.Errorf("tar entry '%s' points outside of the dataset", hdr.Name)
+		}
+
+		e := TOCEntry{Path: name, Mode: hdr.FileInfo().Mode(), ModTime: hdr.ModTime}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			toc.Entries = append(toc.Entries, e)
+			continue
+		case tar.TypeReg, tar.TypeRegA:
+		case tar.TypeXGlobalHeader:
+			continue //metadata for the whole stream, e.g. from 'git archive'
+		case tar.TypeSymlink, tar.TypeLink:
+			return errors.Errorf("tar entry '%s' is a link, links are not supported in datasets", hdr.Name)
+		default:
+			return errors.Errorf("tar entry '%s' is not a regular file or directory", hdr.Name)
+		}
+
+		total += hdr.Size
+		if a.sizeLimit > 0 && total > a.sizeLimit {
+			return errors.Errorf(ErrDatasetTooLarge, humanize.Bytes(uint64(a.sizeLimit)))
+		}
+
+		//the stream cannot be read twice, the file is buffered while its digest is determined
+		if err = tmpf.Truncate(0); err != nil {
+			return errors.Wrap(err, "failed to truncate temporary file")
+		}
+
+		if _, err = tmpf.Seek(0, 0); err != nil {
+			return errors.Wrap(err, "failed to seek to beginning of file")
+		}
+
+		h := sha256.New()
+		if e.Size, err = Copy(ctx, io.MultiWriter(tmpf, h), tr); err != nil {
+			return errors.Wrap(err, "failed to copy file content to temporary file")
+		}
+
+		if _, err = tmpf.Seek(0, 0); err != nil {
+			return errors.Wrap(err, "failed to seek to beginning of file")
+		}
+
+		e.SHA256 = hex.EncodeToString(h.Sum(nil))
+		e.Key = a.fileKey(e.SHA256)
+		if err = fn(e.Key, tmpf, e.Size); err != nil {
+			return err
+		}
+
+		toc.Entries = append(toc.Entries, e)
+		inc(e.Size)
+	}
+
+	if len(toc.Entries) == 0 {
+		return ErrEmptyDirectory
+	}
+
+	rep.StopArchivingProgress()
+	return a.finish(toc, fn)
+}
+
+//finish calls 'fn' for the manifest, it is stored last such that the dataset keeps its
+//previous content until all files are stored
+func (a *FileArchiver) finish(toc *TOC, fn func(k string, r io.ReadSeeker, nbytes int64) error) error {
+	tocd, err := json.Marshal(toc)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode manifest")
+	}
+
+	return fn(a.manifestKey(), bytes.NewReader(tocd), int64(len(tocd)))
+}
+
+//Append is not supported, files are replaced by pushing the dataset again which only
+//uploads files that changed
+func (a *FileArchiver) Append(ctx context.Context, path string, rep Reporter, get func(k string, w io.WriterAt) error, fn func(k string, r io.ReadSeeker, nbytes int64) error) (layer string, err error) {
+	return "", ErrAppendNotSupported
+}
+
+//Unarchive will call 'fn' for the manifest and all files to download them into the directory at 'path'
+func (a *FileArchiver) Unarchive(ctx context.Context, path string, rep Reporter, fn func(k string, w io.WriterAt) error) error {
+	return a.UnarchivePaths(ctx, path, nil, rep, fn)
+}
+
+//UnarchivePaths will call 'fn' for the manifest and for the files at or below the (slash
+//separated) 'paths' to download them into the directory at 'path'. Files are downloaded in
+//parallel so 'fn' is called concurrently
+func (a *FileArchiver) UnarchivePaths(ctx context.Context, path string, paths []string, rep Reporter, fn func(k string, w io.WriterAt) error) error {
+	err := checkTargetDir(path)
+	if err != nil {
+		return err
+	}
+
+	toc, err := a.Contents(ctx, fn)
+	if err != nil {
+		return err
+	}
+
+	entries := toc.Entries
+	if paths != nil {
+		if entries, err = toc.ListPaths(paths); err != nil {
+			return err
+		}
+	}
+
+	//directories are created up front such that files can be written in any order. Files
+	//with the same content share an object, it is downloaded once and copied to the others
+	files, copies := []TOCEntry{}, []TOCEntry{}
+	first := map[string]TOCEntry{}
+	var total int64
+	for _, e := range entries {
+		target := entryPath(path, e)
+		if e.Mode.IsDir() {
+			if err = os.MkdirAll(target, e.Mode.Perm()|0700); err != nil {
+				return errors.Wrap(err, "failed to create directory")
+			}
+
+			continue
+		}
+
+		if err = os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+			return errors.Wrap(err, "failed to create directory")
+		}
+
+		total += e.Size
+		if _, ok := first[e.Key]; ok {
+			copies = append(copies, e)
+			continue
+		}
+
+		first[e.Key] = e
+		files = append(files, e)
+	}
+
+	prog := newProgress(rep.StartUnarchivingProgress(path, total, zeroReader{}))
+	defer rep.StopUnarchivingProgress()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	work := make(chan TOCEntry)
+	errs := make(chan error, FileArchiverConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < FileArchiverConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range work {
+				if err := a.download(ctx, path, e, fn); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+
+				prog.add(e.Size)
+			}
+		}()
+	}
+
+	func() {
+		defer close(work)
+		for _, e := range files {
+			select {
+			case work <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	if err = <-errs; err != nil {
+		return err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range copies {
+		if err = copyEntry(entryPath(path, first[e.Key]), entryPath(path, e), e.Mode.Perm()); err != nil {
+			return err
+		}
+
+		prog.add(e.Size)
+	}
+
+	return nil
+}
+
+//entryPath returns where the entry 'e' is written to below 'path'
+func entryPath(path string, e TOCEntry) string {
+	return filepath.Join(append([]string{path}, strings.Split(e.Path, TarArchiverPathSeparator)...)...)
+}
+
+//copyEntry writes a copy of the downloaded file at 'src' to a new file at 'dst'
+func copyEntry(src, dst string, perm os.FileMode) (err error) {
+	sf, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "failed to open downloaded file")
+	}
+
+	defer sf.Close()
+	df, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return errors.Wrap(err, "failed to open new file")
+	}
+
+	defer df.Close()
+	if _, err = io.Copy(df, sf); err != nil {
+		return errors.Wrap(err, "failed to copy downloaded file")
+	}
+
+	if err = df.Close(); err != nil {
+		return errors.Wrap(err, "failed to close new file")
+	}
+
+	return nil
+}
+
+//download writes the file of entry 'e' to its location below 'path' using 'fn' and checks its digest
+func (a *FileArchiver) download(ctx context.Context, path string, e TOCEntry, fn func(k string, w io.WriterAt) error) (err error) {
+	f, err := os.OpenFile(entryPath(path, e), os.O_RDWR|os.O_CREATE|os.O_EXCL, e.Mode.Perm())
+	if err != nil {
+		return errors.Wrap(err, "failed to open new file")
+	}
+
+	defer f.Close()
+	if err = fn(e.Key, f); err != nil {
+		return errors.Wrapf(err, "failed to download '%s'", e.Path)
+	}
+
+	if e.SHA256 == "" {
+		return nil
+	}
+
+	h := sha256.New()
+	if _, err = io.Copy(h, io.NewSectionReader(f, 0, e.Size+1)); err != nil {
+		return errors.Wrap(err, "failed to read back downloaded file")
+	}
+
+	if hex.EncodeToString(h.Sum(nil)) != e.SHA256 {
+		return errors.Wrapf(ErrDigestMismatch, "file '%s'", e.Path)
+	}
+
+	return nil
+}
+
+//UnarchiveTar will call 'fn' for the manifest and write all files as a single tar stream to 'w'
+func (a *FileArchiver) UnarchiveTar(ctx context.Context, w io.Writer, rep Reporter, fn func(k string, w io.WriterAt) error) error {
+	toc, err := a.Contents(ctx, fn)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, e := range toc.Entries {
+		total += e.Size
+	}
+
+	prog := newProgress(rep.StartUnarchivingProgress(a.manifestKey(), total, zeroReader{}))
+	defer rep.StopUnarchivingProgress()
+
+	tw := tar.NewWriter(w)
+	for _, e := range toc.Entries {
+		hdr := &tar.Header{Name: e.Path, Mode: int64(e.Mode.Perm()), ModTime: e.ModTime, Typeflag: tar.TypeDir}
+		if e.Mode.IsRegular() {
+			hdr.Typeflag, hdr.Size = tar.TypeReg, e.Size
+		}
+
+		if err = tw.WriteHeader(hdr); err != nil {
+			return errors.Wrap(err, "failed to write tar header")
+		}
+
+		if !e.Mode.IsRegular() {
+			continue
+		}
+
+		h := sha256.New()
+		if err = a.objects.GetRange(ctx, e.Key, 0, e.Size, io.MultiWriter(tw, h)); err != nil {
+			return errors.Wrapf(err, "failed to copy '%s' to tar stream", e.Path)
+		}
+
+		if e.SHA256 != "" && hex.EncodeToString(h.Sum(nil)) != e.SHA256 {
+			return errors.Wrapf(ErrDigestMismatch, "file '%s'", e.Path)
+		}
+
+		prog.add(e.Size)
+	}
+
+	if err = tw.Close(); err != nil {
+		return errors.Wrap(err, "failed to finish tar stream")
+	}
+
+	return nil
+}
+
+//progress advances a progress reader from multiple goroutines, the reporter only tracks
+//bytes that are read from it
+type progress struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func newProgress(r io.Reader) *progress { return &progress{r: r} }
+
+func (p *progress) add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, _ = io.CopyN(ioutil.Discard, p.r, n)
+}
+
+//zeroReader reads zeros endlessly
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (n int, err error) {
+	for i := range p {
+		p[i] = 0
+	}
+
+	return len(p), nil
+}
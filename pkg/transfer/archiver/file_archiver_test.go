@@ -0,0 +1,188 @@
+package transferarchiver_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+)
+
+//putCountingStore counts the objects that are stored
+type putCountingStore struct {
+	*transferstore.FSStore
+	mu   sync.Mutex
+	puts int
+}
+
+func (s *putCountingStore) Put(ctx context.Context, k string, r io.ReadSeeker) error {
+	s.mu.Lock()
+	s.puts++
+	s.mu.Unlock()
+	return s.FSStore.Put(ctx, k, r)
+}
+
+func TestFileArchiver(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, err := ioutil.TempDir("", "file_archiver_tests_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	fs, err := transferstore.NewFSStore(transferstore.StoreOptions{FSStoreDir: filepath.Join(dir, "objects")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &putCountingStore{FSStore: fs}
+	src := filepath.Join(dir, "src")
+	for i := 0; i < 20; i++ {
+		sub := filepath.Join(src, fmt.Sprintf("shard-%d", i%4))
+		if err = os.MkdirAll(sub, 0777); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = ioutil.WriteFile(filepath.Join(sub, fmt.Sprintf("img-%d.bin", i)), bytes.Repeat([]byte{byte(i)}, 1000+i), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, err := transfer.CreateArchiver(transferarchiver.ArchiverOptions{Type: transferarchiver.ArchiverTypeFile, KeyPrefix: "my-prefix/"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := transfer.CreateStdHandle("my-dataset", store, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Push(ctx, src, rep); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.puts != 21 {
+		t.Fatalf("expected every file and the manifest to be stored, got: %d objects", store.puts)
+	}
+
+	t.Run("re-push only stores changed files", func(t *testing.T) {
+		if err := ioutil.WriteFile(filepath.Join(src, "shard-0", "img-0.bin"), []byte("changed"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		store.puts = 0
+		if err := h.Push(ctx, src, rep); err != nil {
+			t.Fatal(err)
+		}
+
+		if store.puts != 2 {
+			t.Fatalf("expected only the changed file and the manifest to be stored, got: %d objects", store.puts)
+		}
+
+		var n int
+		if err := fs.List(ctx, "my-prefix/files/", func(k string, size int64, modTime time.Time) error {
+			n++
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 20 {
+			t.Fatalf("expected the replaced file to be removed, got: %d file objects", n)
+		}
+	})
+
+	t.Run("pull a subset", func(t *testing.T) {
+		dst := filepath.Join(dir, "subset")
+		if err := h.PullPaths(ctx, dst, []string{"shard-1", "shard-0/img-0.bin"}, rep); err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := ioutil.ReadFile(filepath.Join(dst, "shard-0", "img-0.bin"))
+		if err != nil || string(d) != "changed" {
+			t.Fatalf("unexpected content: %q, %v", d, err)
+		}
+
+		fis, err := ioutil.ReadDir(filepath.Join(dst, "shard-1"))
+		if err != nil || len(fis) != 5 {
+			t.Fatalf("expected all files of the shard, got: %d, %v", len(fis), err)
+		}
+
+		if _, err = os.Stat(filepath.Join(dst, "shard-2")); !os.IsNotExist(err) {
+			t.Fatalf("expected other shards not to be downloaded, got: %v", err)
+		}
+	})
+
+	t.Run("pull everything and read a single file", func(t *testing.T) {
+		dst := filepath.Join(dir, "all")
+		if err := h.Pull(ctx, dst, rep); err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := ioutil.ReadFile(filepath.Join(dst, "shard-3", "img-19.bin"))
+		if err != nil || len(d) != 1019 {
+			t.Fatalf("unexpected content: %d bytes, %v", len(d), err)
+		}
+
+		buf := &bytes.Buffer{}
+		if err = h.ReadFile(ctx, "shard-3/img-19.bin", buf); err != nil || buf.Len() != 1019 {
+			t.Fatalf("unexpected content: %d bytes, %v", buf.Len(), err)
+		}
+	})
+
+	t.Run("refuse links and devices in a tar stream", func(t *testing.T) {
+		for typ, msg := range map[byte]string{
+			tar.TypeSymlink: "links are not supported",
+			tar.TypeChar:    "not a regular file or directory",
+		} {
+			buf := bytes.NewBuffer(nil)
+			tw := tar.NewWriter(buf)
+			if err := tw.WriteHeader(&tar.Header{Name: "entry", Typeflag: typ, Linkname: "shard-0/img-0.bin", Mode: 0777}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			err := a.ArchiveTar(ctx, buf, rep, func(k string, r io.ReadSeeker, nbytes int64) error {
+				return nil
+			})
+			if err == nil || !strings.Contains(err.Error(), msg) {
+				t.Fatalf("expected tar entry of type %q to be refused, got: %v", typ, err)
+			}
+		}
+	})
+
+	t.Run("clear removes all objects", func(t *testing.T) {
+		if err := h.Clear(ctx, rep); err != nil {
+			t.Fatal(err)
+		}
+
+		var n int
+		if err := fs.List(ctx, "my-prefix/", func(k string, size int64, modTime time.Time) error {
+			n++
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 0 {
+			t.Fatalf("expected no objects to remain, got: %d", n)
+		}
+	})
+}
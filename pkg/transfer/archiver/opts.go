@@ -1,18 +1,139 @@
 package transferarchiver
 
+import (
+	"encoding/json"
+
+	"github.com/nerdalize/nerd/pkg/transfer/config"
+	"github.com/pkg/errors"
+)
+
 //ArchiverType determines what type the object store will be
 type ArchiverType string
 
 const (
 	//ArchiverTypeTar uses the tar archiving format
 	ArchiverTypeTar ArchiverType = "tar"
+
+	//ArchiverTypeRef treats existing objects under a key prefix as the files of a dataset
+	ArchiverTypeRef ArchiverType = "ref"
+
+	//ArchiverTypeZip uses the zip archiving format, files are stored without compression
+	ArchiverTypeZip ArchiverType = "zip"
+
+	//ArchiverTypeFile stores every file as a separate object
+	ArchiverTypeFile ArchiverType = "file"
 )
 
-//ArchiverOptions contain options for all stores
+//ConfigVersion is the version of the config that the standard archivers keep their options in.
+//Version 1 kept them as fields next to the type, these are still read
+const ConfigVersion = 2
+
+//standard returns whether the archiver is implemented in this repository
+func (typ ArchiverType) standard() bool {
+	return typ == ArchiverTypeTar || typ == ArchiverTypeRef || typ == ArchiverTypeZip || typ == ArchiverTypeFile
+}
+
+//ArchiverOptions contain options for all archivers, the standard archivers keep them in the
+//fields which are stored as a versioned config
 type ArchiverOptions struct {
-	Type ArchiverType `json:"type"`
+	Type ArchiverType `json:"type,omitempty"`
+
+	//KeyPrefix is the prefix below which the archiver keeps the objects of the dataset, it
+	//is chosen by the manager for archivers of every type
+	KeyPrefix string `json:"keyPrefix"`
 
-	TarArchiverKeyPrefix string `json:"keyPrefix"`
+	TarArchiverLayers    []string `json:"layers,omitempty"`
+	TarArchiverPartSize  int64    `json:"partSize,omitempty"`
+	RefArchiverKeyPrefix string   `json:"refKeyPrefix,omitempty"`
+
+	//TarArchiverPoolPrefix stores parts below this prefix instead of the key prefix, datasets
+	//that share the pool store identical parts only once. Parts are ranges of the tar stream so
+	//they are only identical when their files have the same paths, modes, modification times
+	//and content, and the files before them are the same such that parts are cut at the same place
+	TarArchiverPoolPrefix string `json:"poolPrefix,omitempty"`
 
 	SizeLimit int64 `json:"sizeLimit"`
+
+	//Config holds the options of archivers that are registered outside of this repository, for
+	//the standard archivers it is decoded into the fields above by LoadConfig
+	Config *transferconfig.Config `json:"config,omitempty"`
+}
+
+//plainArchiverOptions is encoded without the versioned config
+type plainArchiverOptions ArchiverOptions
+
+//MarshalJSON encodes the options of the standard archivers as a versioned config
+func (o ArchiverOptions) MarshalJSON() ([]byte, error) {
+	if !o.Type.standard() {
+		return json.Marshal(plainArchiverOptions(o))
+	}
+
+	cfg, err := o.VersionedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		Type   ArchiverType           `json:"type"`
+		Config *transferconfig.Config `json:"config"`
+	}{o.Type, cfg})
+}
+
+//VersionedConfig returns the config that the archiver is created from, the options of the standard
+//archivers are encoded into it from the fields
+func (o ArchiverOptions) VersionedConfig() (*transferconfig.Config, error) {
+	if !o.Type.standard() {
+		return o.Config, nil
+	}
+
+	p := plainArchiverOptions(o)
+	p.Type, p.Config = "", nil
+	return transferconfig.New(ConfigVersion, p)
+}
+
+//UnmarshalJSON decodes the options, options of the standard archivers that were encoded
+//as fields before they were versioned are read as well
+func (o *ArchiverOptions) UnmarshalJSON(d []byte) error {
+	var p plainArchiverOptions
+	if err := json.Unmarshal(d, &p); err != nil {
+		return err
+	}
+
+	*o = ArchiverOptions(p)
+	return o.LoadConfig()
+}
+
+//LoadConfig decodes the config of a standard archiver into the fields of the options, options
+//in the config take precedence over the fields
+func (o *ArchiverOptions) LoadConfig() error {
+	if !o.Type.standard() || o.Config == nil {
+		return nil
+	}
+
+	if o.Config.Version != ConfigVersion {
+		return errors.Errorf("unsupported config version %d for archiver '%s'", o.Config.Version, o.Type)
+	}
+
+	p := plainArchiverOptions(*o)
+	if err := o.Config.Decode(&p); err != nil {
+		return err
+	}
+
+	p.Type, p.Config = o.Type, nil
+	*o = ArchiverOptions(p)
+	return nil
+}
+
+//DecodeArchiverOptions returns the options of a standard archiver of type 'typ' that are decoded from 'cfg'
+func DecodeArchiverOptions(typ ArchiverType, cfg *transferconfig.Config) (opts ArchiverOptions, err error) {
+	if !typ.standard() {
+		return opts, errors.Errorf("archiver '%s' is not a standard archiver", typ)
+	}
+
+	opts = ArchiverOptions{Type: typ, Config: cfg}
+	if err = opts.LoadConfig(); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
 }
@@ -0,0 +1,260 @@
+package transferarchiver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	slashpath "path"
+
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	//TarArchiverIndexKey configures the key of the object that lists the parts of a split archive
+	TarArchiverIndexKey = "parts.json"
+
+	//TarArchiverPartsDir configures the key prefix, below the archive prefix, under which parts are stored
+	TarArchiverPartsDir = "parts"
+
+	//DefaultPartSize is the size of parts when an archive is split without specifying one
+	DefaultPartSize = int64(512 * 1000 * 1000)
+
+	//tarBlockSize is the size of a tar header, contents are padded to a multiple of it
+	tarBlockSize = int64(512)
+)
+
+//partEntry describes a single part of a split archive, parts are named after the
+//digest of their content
+type partEntry struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+//partIndex lists the parts that, concatenated in order, form the tar archive
+type partIndex struct {
+	Parts []partEntry `json:"parts"`
+}
+
+//isPartKey returns whether 'k' names a part of a split archive
+func isPartKey(k string) bool {
+	return slashpath.Base(slashpath.Dir(k)) == TarArchiverPartsDir
+}
+
+//IsPoolKey returns whether 'k' names a part in the shared pool at prefix 'pool'
+func IsPoolKey(pool, k string) bool {
+	return pool != "" && strings.HasPrefix(k, pool) && isPartKey(k)
+}
+
+//maybeCut stores the current part when writing the entry with 'hdr' would grow it
+//beyond the part size. Entries are never split such that each can be read from a single part
+func (tw *tocWriter) maybeCut(ctx context.Context, size int64) error {
+	if tw.partSize <= 0 {
+		return nil
+	}
+
+	//write the padding of the previous entry such that the part ends on an entry boundary
+	if err := tw.Flush(); err != nil {
+		return errors.Wrap(err, "failed to flush tar writer to disk")
+	}
+
+	pos, err := tw.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return errors.Wrap(err, "failed to determine part size")
+	}
+
+	if pos == 0 || pos+tarBlockSize+size <= tw.partSize {
+		return nil
+	}
+
+	return tw.cut(ctx)
+}
+
+//cut stores the current part under a key that is derived from its content and starts a new
+//one in the same file. Table of contents entries that were written since the last cut are
+//updated to point to the stored part
+func (tw *tocWriter) cut(ctx context.Context) error {
+	size, err := tw.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return errors.Wrap(err, "failed to determine part size")
+	}
+
+	if _, err = tw.f.Seek(0, 0); err != nil {
+		return errors.Wrap(err, "failed to seek to the beginning of file")
+	}
+
+	h := sha256.New()
+	if _, err = Copy(ctx, h, io.LimitReader(tw.f, size)); err != nil {
+		return errors.Wrap(err, "failed to hash part")
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	prefix := tw.prefix
+	if tw.pool != "" {
+		prefix = tw.pool
+	}
+
+	k := slashpath.Join(prefix, TarArchiverPartsDir, sum)
+	for i := tw.pending; i < len(tw.toc.Entries); i++ {
+		tw.toc.Entries[i].Key = k
+	}
+
+	tw.pending = len(tw.toc.Entries)
+	if _, err = tw.f.Seek(0, 0); err != nil {
+		return errors.Wrap(err, "failed to seek to the beginning of file")
+	}
+
+	if err = tw.put(k, tw.f, size); err != nil {
+		return err
+	}
+
+	tw.parts.Parts = append(tw.parts.Parts, partEntry{Key: k, Size: size, SHA256: sum})
+	if err = tw.f.Truncate(0); err != nil {
+		return errors.Wrap(err, "failed to truncate part file")
+	}
+
+	if _, err = tw.f.Seek(0, 0); err != nil {
+		return errors.Wrap(err, "failed to seek to the beginning of file")
+	}
+
+	return nil
+}
+
+//readParts retrieves the index of the split archive at 'prefix'
+func readParts(prefix string, fn func(k string, w io.WriterAt) error) (idx *partIndex, err error) {
+	buf := &writeAtBuffer{}
+	if err = fn(slashpath.Join(prefix, TarArchiverIndexKey), buf); err != nil {
+		return nil, errors.Wrap(err, "failed to get part index")
+	}
+
+	idx = &partIndex{}
+	if err = json.Unmarshal(buf.buf, idx); err != nil {
+		return nil, errors.Wrap(err, "failed to decode part index")
+	}
+
+	return idx, nil
+}
+
+//partReader reads the parts of a split archive in order as a single stream, each part is
+//downloaded to a temporary file only when the previous one has been read completely
+type partReader struct {
+	parts []partEntry
+	fn    func(k string, w io.WriterAt) error
+	temp  func() (*os.File, func(), error)
+
+	cur   *os.File
+	clean func()
+}
+
+func (r *partReader) Read(p []byte) (n int, err error) {
+	for {
+		if r.cur == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+
+			if err = r.next(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err = r.cur.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+
+		r.Close()
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+//next downloads the next part to a temporary file
+func (r *partReader) next() (err error) {
+	part := r.parts[0]
+	r.parts = r.parts[1:]
+
+	f, clean, err := r.temp()
+	if err != nil {
+		return err
+	}
+
+	if err = r.fn(part.Key, f); err != nil {
+		clean()
+		return errors.Wrapf(err, "failed to download part '%s'", part.Key)
+	}
+
+	if _, err = f.Seek(0, 0); err != nil {
+		clean()
+		return errors.Wrap(err, "failed to seek to the beginning of file")
+	}
+
+	r.cur, r.clean = f, clean
+	return nil
+}
+
+//Close removes the temporary file of the part that is being read
+func (r *partReader) Close() error {
+	if r.clean != nil {
+		r.clean()
+	}
+
+	r.cur, r.clean = nil, nil
+	return nil
+}
+
+//PartIndex calls 'fn' for the parts of the archive and its layers, these are listed in the
+//index objects that 'get' is called for. Archives that are not split have no parts
+func (a *TarArchiver) PartIndex(ctx context.Context, get func(k string, w io.WriterAt) error, fn func(k string) error) error {
+	return a.partIndex(a.prefixes(), get, fn)
+}
+
+func (a *TarArchiver) partIndex(prefixes []string, get func(k string, w io.WriterAt) error, fn func(k string) error) error {
+	if a.partSize <= 0 {
+		return nil
+	}
+
+	for _, prefix := range prefixes {
+		idx, err := readParts(prefix, get)
+		if errors.Cause(err) == transferstore.ErrObjectNotExists {
+			continue //nothing was pushed yet, or the upload was interrupted before the index
+		} else if err != nil {
+			return err
+		}
+
+		for _, part := range idx.Parts {
+			if err = fn(part.Key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//Pooled returns whether the object at 'k' is a part in the shared pool, other datasets may
+//use it as well so it must only be removed when no dataset references it anymore
+func (a *TarArchiver) Pooled(k string) bool {
+	return IsPoolKey(a.pool, k)
+}
+
+//Metadata returns whether the object at 'k' only describes the archive, eg. its table of
+//contents. These are read to list a dataset and must stay readable when it is archived
+func (a *TarArchiver) Metadata(k string) bool {
+	base := slashpath.Base(k)
+	return base == TarArchiverTOCKey || base == TarArchiverIndexKey
+}
+
+//Immutable returns whether the object at 'k' is named after its content, such objects don't
+//need to be stored again when they exist already, eg. when a push is retried
+func (a *TarArchiver) Immutable(k string) bool {
+	return a.partSize > 0 && isPartKey(k)
+}
@@ -0,0 +1,184 @@
+package transferarchiver
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	slashpath "path"
+
+	"github.com/pkg/errors"
+)
+
+//ErrReadOnlyReference is returned when content is pushed to a dataset that references existing objects
+var ErrReadOnlyReference = errors.New("dataset references existing objects and cannot be changed")
+
+//ListFunc calls 'fn' for every object whose key starts with 'prefix'
+type ListFunc func(ctx context.Context, prefix string, fn func(k string, size int64, modTime time.Time) error) error
+
+//RefArchiver treats every object under a key prefix as a file, the prefix is
+//used as the root of the dataset. Objects are only ever read such that existing
+//data can be used without copying it
+type RefArchiver struct {
+	keyPrefix string
+	list      ListFunc
+}
+
+//NewRefArchiver will setup the reference archiver, 'list' is used to discover the objects
+func NewRefArchiver(opts ArchiverOptions, list ListFunc) (a *RefArchiver, err error) {
+	a = &RefArchiver{keyPrefix: opts.RefArchiverKeyPrefix, list: list}
+	if a.keyPrefix != "" && !strings.HasSuffix(a.keyPrefix, "/") {
+		return nil, errors.Errorf("archiver key prefix must end with a forward slash")
+	}
+
+	if a.list == nil {
+		return nil, errors.New("reference archiver requires a store that can list objects")
+	}
+
+	return a, nil
+}
+
+//Index calls 'fn' for all object keys that are owned by the archive, referenced
+//objects are not owned so clearing the dataset never removes them
+func (a *RefArchiver) Index(fn func(k string) error) error { return nil }
+
+//walk calls 'fn' for each referenced object with its path relative to the prefix
+func (a *RefArchiver) walk(ctx context.Context, fn func(p, k string, size int64, modTime time.Time) error) error {
+	return a.list(ctx, a.keyPrefix, func(k string, size int64, modTime time.Time) error {
+		p := strings.TrimPrefix(k, a.keyPrefix)
+		if p == "" || strings.HasSuffix(p, TarArchiverPathSeparator) {
+			return nil //placeholder objects that represent directories
+		}
+
+		p = slashpath.Clean(strings.TrimLeft(p, TarArchiverPathSeparator))
+		if p == ".." || strings.HasPrefix(p, "../") {
+			return errors.Errorf("object key '%s' points outside of the dataset", k)
+		}
+
+		return fn(p, k, size, modTime)
+	})
+}
+
+//Contents lists the referenced objects as the table of contents
+func (a *RefArchiver) Contents(ctx context.Context, fn func(k string, w io.WriterAt) error) (toc *TOC, err error) {
+	toc = &TOC{}
+	if err = a.walk(ctx, func(p, k string, size int64, modTime time.Time) error {
+		toc.Entries = append(toc.Entries, TOCEntry{
+			Path:    p,
+			Size:    size,
+			Mode:    0644,
+			ModTime: modTime,
+			Key:     k,
+		})
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to list referenced objects")
+	}
+
+	return toc, nil
+}
+
+//Archive is not supported, referenced objects are read-only
+func (a *RefArchiver) Archive(ctx context.Context, path string, rep Reporter, fn func(k string, r io.ReadSeeker, nbytes int64) error) error {
+	return ErrReadOnlyReference
+}
+
+//ArchiveTar is not supported, referenced objects are read-only
+func (a *RefArchiver) ArchiveTar(ctx context.Context, r io.Reader, rep Reporter, fn func(k string, r io.ReadSeeker, nbytes int64) error) error {
+	return ErrReadOnlyReference
+}
+
+//Append is not supported, referenced objects are read-only
+func (a *RefArchiver) Append(ctx context.Context, path string, rep Reporter, get func(k string, w io.WriterAt) error, fn func(k string, r io.ReadSeeker, nbytes int64) error) (layer string, err error) {
+	return "", ErrReadOnlyReference
+}
+
+//Unarchive will call 'fn' for every referenced object and write it to a file at the same relative path
+func (a *RefArchiver) Unarchive(ctx context.Context, path string, rep Reporter, fn func(k string, w io.WriterAt) error) error {
+	err := checkTargetDir(path)
+	if err != nil {
+		return err
+	}
+
+	if err = a.walk(ctx, func(p, k string, size int64, modTime time.Time) error {
+		parts := []string{path}
+		parts = append(parts, strings.Split(p, TarArchiverPathSeparator)...)
+		target := filepath.Join(parts...)
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return errors.Wrap(err, "failed to create directory for referenced object")
+		}
+
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return errors.Wrap(err, "failed to open new file for referenced object")
+		}
+
+		defer f.Close()
+		if err = fn(k, f); err != nil {
+			return errors.Wrapf(err, "failed to download referenced object '%s'", k)
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "failed to unarchive referenced objects")
+	}
+
+	return nil
+}
+
+//UnarchiveTar will call 'fn' for every referenced object and write them as a single tar stream to 'w'
+func (a *RefArchiver) UnarchiveTar(ctx context.Context, w io.Writer, rep Reporter, fn func(k string, w io.WriterAt) error) error {
+	tw := tar.NewWriter(w)
+	if err := a.walk(ctx, func(p, k string, size int64, modTime time.Time) error {
+		tmpf, err := ioutil.TempFile("", "ref_archiver_")
+		if err != nil {
+			return errors.Wrap(err, "failed to create temporary file")
+		}
+
+		defer os.Remove(tmpf.Name())
+		defer tmpf.Close()
+		if err = fn(k, tmpf); err != nil {
+			return errors.Wrapf(err, "failed to download referenced object '%s'", k)
+		}
+
+		fi, err := tmpf.Stat()
+		if err != nil {
+			return errors.Wrap(err, "failed to stat temporary file")
+		}
+
+		if _, err = tmpf.Seek(0, 0); err != nil {
+			return errors.Wrap(err, "failed to seek to the beginning of file")
+		}
+
+		if err = tw.WriteHeader(&tar.Header{
+			Name:     p,
+			Mode:     0644,
+			Size:     fi.Size(),
+			ModTime:  modTime,
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return errors.Wrap(err, "failed to write tar header")
+		}
+
+		if _, err = Copy(ctx, tw, tmpf); err != nil {
+			return errors.Wrap(err, "failed to copy object content to tar stream")
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "failed to write referenced objects as tar stream")
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "failed to finish tar stream")
+	}
+
+	return nil
+}
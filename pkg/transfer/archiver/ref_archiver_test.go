@@ -0,0 +1,133 @@
+package transferarchiver_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+)
+
+func TestRefArchiver(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+	objs := map[string][]byte{
+		"data/":               nil,
+		"data/a.txt":          []byte("hello"),
+		"data/sub/b.txt":      []byte("world"),
+		"data-other/c.txt":    []byte("not referenced"),
+		"unrelated/d.txt":     []byte("not referenced"),
+		"data/sub/deep/e.txt": []byte(""),
+	}
+
+	list := func(ctx context.Context, prefix string, fn func(k string, size int64, modTime time.Time) error) error {
+		keys := []string{}
+		for k := range objs {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := fn(k, int64(len(objs[k])), time.Now()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	get := func(k string, w io.WriterAt) error {
+		_, err := w.WriteAt(objs[k], 0)
+		return err
+	}
+
+	a, err := transferarchiver.NewRefArchiver(transferarchiver.ArchiverOptions{RefArchiverKeyPrefix: "data/"}, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("index should not include referenced objects", func(t *testing.T) {
+		if err := a.Index(func(k string) error {
+			t.Fatalf("unexpected key in index: %s", k)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("archive should be refused", func(t *testing.T) {
+		if err := a.Archive(ctx, "/tmp", rep, nil); err != transferarchiver.ErrReadOnlyReference {
+			t.Fatalf("expected read only error, got: %v", err)
+		}
+	})
+
+	t.Run("contents lists objects under the prefix", func(t *testing.T) {
+		toc, err := a.Contents(ctx, get)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(toc.Entries) != 3 {
+			t.Fatalf("expected three files, got: %#v", toc.Entries)
+		}
+
+		e, ok := toc.Lookup("sub/b.txt")
+		if !ok || e.Key != "data/sub/b.txt" || e.Size != 5 {
+			t.Fatalf("expected entry to locate the referenced object, got: %#v", e)
+		}
+	})
+
+	t.Run("unarchive writes each object as a file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "ref_archiver_test_")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = a.Unarchive(ctx, dir, rep, get); err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := ioutil.ReadFile(filepath.Join(dir, "sub", "b.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(d, []byte("world")) {
+			t.Fatalf("unexpected file content: %q", d)
+		}
+	})
+
+	t.Run("unarchive to tar stream", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		if err = a.UnarchiveTar(ctx, buf, rep, get); err != nil {
+			t.Fatal(err)
+		}
+
+		names := []string{}
+		tr := tar.NewReader(buf)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			names = append(names, hdr.Name)
+		}
+
+		if strings.Join(names, ",") != "a.txt,sub/b.txt,sub/deep/e.txt" {
+			t.Fatalf("unexpected tar entries: %v", names)
+		}
+	})
+}
@@ -2,16 +2,23 @@ package transferarchiver
 
 import (
 	"archive/tar"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	slashpath "path"
 
 	humanize "github.com/dustin/go-humanize"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
 
 	"github.com/pkg/errors"
 )
@@ -20,6 +27,9 @@ var (
 	//TarArchiverKey configures the one object key returned by the tar Archiver
 	TarArchiverKey = "archive.tar"
 
+	//TarArchiverTOCKey configures the key of the table of contents that is stored next to the archive
+	TarArchiverTOCKey = "toc.json"
+
 	//TarArchiverPathSeparator standardizes the header path for cross platform (un)archiving
 	TarArchiverPathSeparator = "/"
 
@@ -32,6 +42,9 @@ var (
 	//ErrEmptyDirectory is returned when the archiver expected the directory to not be empty
 	ErrEmptyDirectory = errors.New("directory is empty")
 
+	//ErrDigestMismatch is returned when content does not match the digest that was recorded when it was archived
+	ErrDigestMismatch = errors.New("content does not match its recorded digest")
+
 	//ErrDatasetTooLarge is returned when the dataset size is above the sizelimit set in the dataset.
 	ErrDatasetTooLarge = "dataset is too big, limit is %s"
 
@@ -40,27 +53,55 @@ var (
 	SizeLimit = int64(1 * 1024 * 1024 * 1024)
 )
 
-//TarArchiver will archive a directory into a single tar file
+//TarArchiver will archive a directory into a single tar file, when a part size is
+//configured the tar file is split into parts that are listed in an index object
 type TarArchiver struct {
 	keyPrefix string
 	sizeLimit int64
+	partSize  int64
+	pool      string
+	layers    []string
 }
 
 //NewTarArchiver will setup the tar archiver
 func NewTarArchiver(opts ArchiverOptions) (a *TarArchiver, err error) {
-	a = &TarArchiver{keyPrefix: opts.TarArchiverKeyPrefix, sizeLimit: opts.SizeLimit}
+	a = &TarArchiver{keyPrefix: opts.KeyPrefix, sizeLimit: opts.SizeLimit, partSize: opts.TarArchiverPartSize, pool: opts.TarArchiverPoolPrefix, layers: opts.TarArchiverLayers}
 
 	if a.keyPrefix != "" && !strings.HasSuffix(a.keyPrefix, "/") {
 		return nil, errors.Errorf("archiver key prefix must end with a forward slash")
 	}
 
-	if a.sizeLimit <= 0 {
+	for _, l := range a.layers {
+		if !strings.HasSuffix(l, "/") {
+			return nil, errors.Errorf("archiver layer prefix must end with a forward slash")
+		}
+	}
+
+	if a.pool != "" && (a.partSize <= 0 || !strings.HasSuffix(a.pool, "/")) {
+		return nil, errors.Errorf("archiver pool prefix requires a part size and must end with a forward slash")
+	}
+
+	//split archives never go through a single temporary file or object, they are
+	//only limited when a limit is configured explicitely
+	if a.sizeLimit <= 0 && a.partSize <= 0 {
 		a.sizeLimit = SizeLimit
 	}
 
 	return a, nil
 }
 
+//Options returns the options the archiver can be setup with again
+func (a *TarArchiver) Options() ArchiverOptions {
+	return ArchiverOptions{
+		Type:                  ArchiverTypeTar,
+		KeyPrefix:             a.keyPrefix,
+		TarArchiverLayers:     a.layers,
+		TarArchiverPartSize:   a.partSize,
+		TarArchiverPoolPrefix: a.pool,
+		SizeLimit:             a.sizeLimit,
+	}
+}
+
 //tempFile will setup a temproary file that can easily be cleaned
 func (a *TarArchiver) tempFile() (f *os.File, clean func(), err error) {
 	f, err = ioutil.TempFile("", "tar_archiver_")
@@ -74,7 +115,8 @@ func (a *TarArchiver) tempFile() (f *os.File, clean func(), err error) {
 	}, nil
 }
 
-func (a *TarArchiver) checkTargetDir(path string) error {
+//checkTargetDir creates the directory at 'path' if necessary and checks that it is empty
+func checkTargetDir(path string) error {
 	dir, err := os.Open(path)
 	if err != nil {
 		if !os.IsNotExist(err) {
@@ -104,9 +146,97 @@ func (a *TarArchiver) checkTargetDir(path string) error {
 	return nil
 }
 
-//Index calls 'fn' for all object keys that are part of the archive
+//prefixes returns the key prefix of the base archive followed by those of its layers
+func (a *TarArchiver) prefixes() []string {
+	return append([]string{a.keyPrefix}, a.layers...)
+}
+
+//Index calls 'fn' for all object keys that are part of the archive, including its layers
 func (a *TarArchiver) Index(fn func(k string) error) error {
-	return fn(slashpath.Join(a.keyPrefix, TarArchiverKey))
+	for _, prefix := range a.prefixes() {
+		if err := a.indexPrefix(prefix, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//LayerIndex calls 'fn' only for the object keys of appended layers, including their
+//parts which are listed in the index objects that 'get' is called for
+func (a *TarArchiver) LayerIndex(ctx context.Context, get func(k string, w io.WriterAt) error, fn func(k string) error) error {
+	if err := a.partIndex(a.layers, get, fn); err != nil {
+		return err
+	}
+
+	for _, prefix := range a.layers {
+		if err := a.indexPrefix(prefix, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *TarArchiver) indexPrefix(prefix string, fn func(k string) error) error {
+	k := slashpath.Join(prefix, TarArchiverKey)
+	if a.partSize > 0 {
+		k = slashpath.Join(prefix, TarArchiverIndexKey)
+	}
+
+	if err := fn(k); err != nil {
+		return err
+	}
+
+	return fn(slashpath.Join(prefix, TarArchiverTOCKey))
+}
+
+//Contents returns the table of contents of the archive by calling 'fn' for the
+//objects that are required to construct it. The contents of layers are applied
+//in order such that entries of later layers replace those with the same path
+func (a *TarArchiver) Contents(ctx context.Context, fn func(k string, w io.WriterAt) error) (toc *TOC, err error) {
+	toc = &TOC{}
+	for _, prefix := range a.prefixes() {
+		var ltoc *TOC
+		if ltoc, err = a.contents(ctx, prefix, fn); err != nil {
+			return nil, err
+		}
+
+		toc.Overlay(ltoc)
+	}
+
+	return toc, nil
+}
+
+//contents returns the table of contents of a single archive. Archives that were
+//stored before tables of contents were recorded have their archive object scanned instead,
+//any other failure to get the table of contents is returned as it could be a large download
+func (a *TarArchiver) contents(ctx context.Context, prefix string, fn func(k string, w io.WriterAt) error) (toc *TOC, err error) {
+	buf := &writeAtBuffer{}
+	if err = fn(slashpath.Join(prefix, TarArchiverTOCKey), buf); err == nil {
+		return decodeTOC(bytes.NewReader(buf.buf))
+	}
+
+	if errors.Cause(err) != transferstore.ErrObjectNotExists || a.partSize > 0 {
+		return nil, errors.Wrap(err, "failed to get table of contents") //split archives always record one
+	}
+
+	tmpf, clean, err := a.tempFile()
+	if err != nil {
+		return nil, err
+	}
+
+	defer clean()
+	k := slashpath.Join(prefix, TarArchiverKey)
+	if err = fn(k, tmpf); err != nil {
+		return nil, errors.Wrap(err, "failed to download archive to temporary file")
+	}
+
+	if _, err = tmpf.Seek(0, 0); err != nil {
+		return nil, errors.Wrap(err, "failed to seek to the beginning of file")
+	}
+
+	return scanTOC(k, tmpf)
 }
 
 //@TODO do we want to expose this through the interface?
@@ -155,6 +285,41 @@ func checkValidDir(path string) (err error) {
 
 //Archive will archive a directory at 'path' into readable objects 'r' and calls 'fn' for each
 func (a *TarArchiver) Archive(ctx context.Context, path string, rep Reporter, fn func(k string, r io.ReadSeeker, nbytes int64) error) (err error) {
+	return a.archive(ctx, a.keyPrefix, path, a.sizeLimit, rep, fn)
+}
+
+//Append will archive a directory at 'path' into a new layer and calls 'fn' for each of its objects.
+//The returned layer prefix must be recorded in the options for the layer to become part of the archive.
+//The size limit applies to the existing content, read through 'get', together with the new layer
+func (a *TarArchiver) Append(ctx context.Context, path string, rep Reporter, get func(k string, w io.WriterAt) error, fn func(k string, r io.ReadSeeker, nbytes int64) error) (layer string, err error) {
+	limit := a.sizeLimit
+	if limit > 0 {
+		toc, err := a.Contents(ctx, get)
+		if err != nil && errors.Cause(err) != transferstore.ErrObjectNotExists {
+			return "", errors.Wrap(err, "failed to determine the size of the existing content")
+		}
+
+		if toc != nil {
+			for _, e := range toc.Entries {
+				limit -= e.Size
+			}
+		}
+
+		if limit <= 0 {
+			return "", errors.Errorf(ErrDatasetTooLarge, humanize.Bytes(uint64(a.sizeLimit)))
+		}
+	}
+
+	layer = fmt.Sprintf("%slayers/%d/", a.keyPrefix, time.Now().UnixNano())
+	if err = a.archive(ctx, layer, path, limit, rep, fn); err != nil {
+		return "", err
+	}
+
+	return layer, nil
+}
+
+//archive writes the directory at 'path' under 'prefix', its files may not exceed 'limit' bytes in total
+func (a *TarArchiver) archive(ctx context.Context, prefix string, path string, limit int64, rep Reporter, fn func(k string, r io.ReadSeeker, nbytes int64) error) (err error) {
 	err = checkValidDir(path)
 	if err != nil {
 		return err
@@ -172,7 +337,7 @@ func (a *TarArchiver) Archive(ctx context.Context, path string, rep Reporter, fn
 		return errors.Wrap(err, "failed to index filesystem")
 	}
 
-	if totalToTar > a.sizeLimit {
+	if limit > 0 && totalToTar > limit {
 		return errors.Errorf(ErrDatasetTooLarge, humanize.Bytes(uint64(a.sizeLimit)))
 	}
 
@@ -184,7 +349,7 @@ func (a *TarArchiver) Archive(ctx context.Context, path string, rep Reporter, fn
 	defer clean()
 	inc := rep.StartArchivingProgress(tmpf.Name(), totalToTar)
 
-	tw := tar.NewWriter(tmpf)
+	tw := a.newTOCWriter(prefix, tmpf, fn)
 	defer tw.Close()
 
 	if err = a.indexFS(path, func(p string, fi os.FileInfo, err error) error {
@@ -201,12 +366,9 @@ func (a *TarArchiver) Archive(ctx context.Context, path string, rep Reporter, fn
 		}
 
 		hdr.Name = strings.Join(path, TarArchiverPathSeparator)
-		if err = tw.WriteHeader(hdr); err != nil {
-			return errors.Wrap(err, "failed to write tar header")
-		}
-
 		if !fi.Mode().IsRegular() {
-			return nil //nothing to write for dirs or symlinks
+			_, err = tw.writeEntry(ctx, hdr, nil) //nothing to write for dirs or symlinks
+			return err
 		}
 
 		// open files for taring
@@ -216,10 +378,9 @@ func (a *TarArchiver) Archive(ctx context.Context, path string, rep Reporter, fn
 			return errors.Wrap(err, "failed to open file for archiving")
 		}
 
-		// copy file data into tar writer
 		var n int64
-		if n, err = Copy(ctx, tw, f); err != nil {
-			return errors.Wrap(err, "failed to copy file content to archive")
+		if n, err = tw.writeEntry(ctx, hdr, f); err != nil {
+			return err
 		}
 
 		inc(n)
@@ -227,60 +388,317 @@ func (a *TarArchiver) Archive(ctx context.Context, path string, rep Reporter, fn
 	}); err != nil {
 		return errors.Wrap(err, "failed to perform filesystem walk")
 	}
+
+	//stop progress reporting, we're done
+	rep.StopArchivingProgress()
+	return a.finish(ctx, prefix, tw, fn)
+}
+
+//ArchiveTar will read a tar stream from 'r' and turn it into readable objects for which 'fn'
+//is called. Entry names are normalized and the same size limit as for directories applies
+func (a *TarArchiver) ArchiveTar(ctx context.Context, r io.Reader, rep Reporter, fn func(k string, r io.ReadSeeker, nbytes int64) error) (err error) {
+	tmpf, clean, err := a.tempFile()
+	if err != nil {
+		return err
+	}
+
+	defer clean()
+	inc := rep.StartArchivingProgress(tmpf.Name(), 0) //total is unknown for streams
+
+	tw := a.newTOCWriter(a.keyPrefix, tmpf, fn)
+	defer tw.Close()
+
+	var total int64
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "failed to read next header")
+		}
+
+		name := slashpath.Clean(strings.TrimLeft(hdr.Name, TarArchiverPathSeparator))
+		if name == "." {
+			continue //the root of the stream, our archives don't include it
+		}
+
+		if name == ".." || strings.HasPrefix(name, "../") {
+			return errors.Errorf("tar entry '%s' points outside of the dataset", hdr.Name)
+		}
+
+		hdr.Name = name
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg:
+		case tar.TypeRegA:
+			hdr.Typeflag = tar.TypeReg
+		case tar.TypeXGlobalHeader:
+			continue //metadata for the whole stream, e.g. from 'git archive'
+		case tar.TypeSymlink, tar.TypeLink:
+			return errors.Errorf("tar entry '%s' is a link, links are not supported in datasets", hdr.Name)
+		default:
+			return errors.Errorf("tar entry '%s' is not a regular file or directory", hdr.Name)
+		}
+
+		total += hdr.Size
+		if a.sizeLimit > 0 && total > a.sizeLimit {
+			return errors.Errorf(ErrDatasetTooLarge, humanize.Bytes(uint64(a.sizeLimit)))
+		}
+
+		var n int64
+		if n, err = tw.writeEntry(ctx, hdr, tr); err != nil {
+			return err
+		}
+
+		inc(n)
+	}
+
+	if len(tw.toc.Entries) == 0 {
+		return ErrEmptyDirectory
+	}
+
+	rep.StopArchivingProgress()
+	return a.finish(ctx, a.keyPrefix, tw, fn)
+}
+
+//newTOCWriter sets up a tar writer for the archive object that records a table of contents,
+//for split archives 'fn' is called for each part as soon as it is complete
+func (a *TarArchiver) newTOCWriter(prefix string, f *os.File, fn func(k string, r io.ReadSeeker, nbytes int64) error) *tocWriter {
+	return &tocWriter{
+		Writer:   tar.NewWriter(f),
+		f:        f,
+		k:        slashpath.Join(prefix, TarArchiverKey),
+		toc:      &TOC{},
+		prefix:   prefix,
+		pool:     a.pool,
+		partSize: a.partSize,
+		put:      fn,
+		parts:    &partIndex{},
+	}
+}
+
+//finish flushes the archive and calls 'fn' for both the archive and its table of contents
+func (a *TarArchiver) finish(ctx context.Context, prefix string, tw *tocWriter, fn func(k string, r io.ReadSeeker, nbytes int64) error) (err error) {
+	if tw.partSize > 0 {
+		return a.finishParts(ctx, prefix, tw, fn)
+	}
+
 	err = tw.Flush()
 	if err != nil {
 		return errors.Wrap(err, "failed to flush tar writer to disk")
 	}
 
-	_, err = tmpf.Seek(0, 0)
+	_, err = tw.f.Seek(0, 0)
 	if err != nil {
 		return errors.Wrap(err, "failed to seek to beginning of file")
 	}
 
-	//stop progress reporting, we're done
-	rep.StopArchivingProgress()
-	fi, err := tmpf.Stat()
+	fi, err := tw.f.Stat()
 	if err != nil {
 		return errors.Wrap(err, "failed to stat the temporary file")
 	}
 
-	return fn(slashpath.Join(a.keyPrefix, TarArchiverKey), tmpf, fi.Size())
+	if err = fn(tw.k, tw.f, fi.Size()); err != nil {
+		return err
+	}
+
+	tocd, err := json.Marshal(tw.toc)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode table of contents")
+	}
+
+	return fn(slashpath.Join(prefix, TarArchiverTOCKey), bytes.NewReader(tocd), int64(len(tocd)))
+}
+
+//finishParts closes the archive and stores its last part, the index of parts and the table of contents
+func (a *TarArchiver) finishParts(ctx context.Context, prefix string, tw *tocWriter, fn func(k string, r io.ReadSeeker, nbytes int64) error) (err error) {
+	if err = tw.Close(); err != nil {
+		return errors.Wrap(err, "failed to close tar writer")
+	}
+
+	if err = tw.cut(ctx); err != nil {
+		return err
+	}
+
+	idxd, err := json.Marshal(tw.parts)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode part index")
+	}
+
+	if err = fn(slashpath.Join(prefix, TarArchiverIndexKey), bytes.NewReader(idxd), int64(len(idxd))); err != nil {
+		return err
+	}
+
+	tocd, err := json.Marshal(tw.toc)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode table of contents")
+	}
+
+	return fn(slashpath.Join(prefix, TarArchiverTOCKey), bytes.NewReader(tocd), int64(len(tocd)))
+}
+
+//tocWriter writes tar entries to a file while recording a table of contents
+type tocWriter struct {
+	*tar.Writer
+	f   *os.File
+	k   string
+	toc *TOC
+
+	//when 'partSize' is set the archive is split, parts are stored through 'put' and the
+	//entries since the last part was stored start at index 'pending' of the table of contents.
+	//Parts are stored below 'pool' instead of 'prefix' when it is set
+	prefix   string
+	pool     string
+	partSize int64
+	put      func(k string, r io.ReadSeeker, nbytes int64) error
+	parts    *partIndex
+	pending  int
+}
+
+//writeEntry writes the header and, for regular files, copies the content from 'r'
+func (tw *tocWriter) writeEntry(ctx context.Context, hdr *tar.Header, r io.Reader) (n int64, err error) {
+	if err = tw.maybeCut(ctx, hdr.Size); err != nil {
+		return 0, err
+	}
+
+	//pooled parts are named after their bytes, the owner and access times of an entry are never
+	//restored when pulling and would keep the parts with identical files of different users apart
+	if tw.pool != "" {
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+	}
+
+	if err = tw.WriteHeader(hdr); err != nil {
+		return 0, errors.Wrap(err, "failed to write tar header")
+	}
+
+	//the header is written through, our position is where the content starts
+	offset, err := tw.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to determine content offset")
+	}
+
+	e := TOCEntry{
+		Path:    hdr.Name,
+		Size:    hdr.Size,
+		Mode:    hdr.FileInfo().Mode(),
+		ModTime: hdr.ModTime,
+		Key:     tw.k,
+		Offset:  offset,
+	}
+
+	if r != nil && hdr.Size > 0 {
+		// copy file data into tar writer while recording its digest
+		h := sha256.New()
+		if n, err = Copy(ctx, io.MultiWriter(tw, h), r); err != nil {
+			return n, errors.Wrap(err, "failed to copy file content to archive")
+		}
+
+		e.SHA256 = hex.EncodeToString(h.Sum(nil))
+	} else if e.Mode.IsRegular() {
+		e.SHA256 = hex.EncodeToString(sha256.New().Sum(nil))
+	}
+
+	tw.toc.Entries = append(tw.toc.Entries, e)
+	return n, nil
 }
 
 //Unarchive will take a file system path and call 'fn' for each object that it needs for unarchiving.
-//It writes to a temporary directory first and then moves this to the final location
+//Layers are extracted after the base archive such that their files replace existing ones
 func (a *TarArchiver) Unarchive(ctx context.Context, path string, rep Reporter, fn func(k string, w io.WriterAt) error) error {
 	// We need to check the target directory first to avoid downloading data if there is a problem
-	err := a.checkTargetDir(path)
+	err := checkTargetDir(path)
 	if err != nil {
 		return err
 	}
 
-	tmpf, clean, err := a.tempFile()
+	for i, prefix := range a.prefixes() {
+		if err = a.unarchive(ctx, prefix, path, i > 0, rep, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//open returns the tar stream of the archive at 'prefix', with its total size and a label
+//for progress reporting. A split archive is read part by part, otherwise 'fn' is called to
+//write the archive object to a temporary file. The returned function removes temporary files
+func (a *TarArchiver) open(prefix string, fn func(k string, w io.WriterAt) error) (r io.Reader, label string, size int64, clean func(), err error) {
+	if a.partSize > 0 {
+		idx, err := readParts(prefix, fn)
+		if err != nil {
+			return nil, "", 0, nil, err
+		}
+
+		for _, part := range idx.Parts {
+			size += part.Size
+		}
+
+		pr := &partReader{parts: idx.Parts, fn: fn, temp: a.tempFile}
+		return pr, slashpath.Join(prefix, TarArchiverIndexKey), size, func() { pr.Close() }, nil
+	}
+
+	tmpf, size, clean, err := a.download(prefix, fn)
 	if err != nil {
-		return err
+		return nil, "", 0, nil, err
 	}
 
-	defer clean()
+	return tmpf, tmpf.Name(), size, clean, nil
+}
 
-	err = fn(slashpath.Join(a.keyPrefix, TarArchiverKey), tmpf)
+//download calls 'fn' to write the archive object at 'prefix' to a temporary file that is
+//positioned at the start, the returned function removes it
+func (a *TarArchiver) download(prefix string, fn func(k string, w io.WriterAt) error) (tmpf *os.File, size int64, clean func(), err error) {
+	tmpf, clean, err = a.tempFile()
 	if err != nil {
-		return errors.Wrap(err, "failed to download to temporary file")
+		return nil, 0, nil, err
+	}
+
+	err = fn(slashpath.Join(prefix, TarArchiverKey), tmpf)
+	if err != nil {
+		clean()
+		return nil, 0, nil, errors.Wrap(err, "failed to download to temporary file")
 	}
 
 	_, err = tmpf.Seek(0, 0)
 	if err != nil {
-		return errors.Wrap(err, "failed to seek to the beginning of file")
+		clean()
+		return nil, 0, nil, errors.Wrap(err, "failed to seek to the beginning of file")
 	}
 
 	fi, err := tmpf.Stat()
 	if err != nil {
-		return errors.Wrap(err, "failed to stat temporary file")
+		clean()
+		return nil, 0, nil, errors.Wrap(err, "failed to stat temporary file")
 	}
 
-	pr := rep.StartUnarchivingProgress(tmpf.Name(), fi.Size(), tmpf)
+	return tmpf, fi.Size(), clean, nil
+}
+
+//unarchive extracts the archive at 'prefix' into 'path', when 'overwrite' is set existing files are replaced
+func (a *TarArchiver) unarchive(ctx context.Context, prefix, path string, overwrite bool, rep Reporter, fn func(k string, w io.WriterAt) error) error {
+	r, label, size, clean, err := a.open(prefix, fn)
+	if err != nil {
+		return err
+	}
+
+	defer clean()
+	sums, err := a.recordedDigests(prefix, fn)
+	if err != nil {
+		return err
+	}
+
+	pr := rep.StartUnarchivingProgress(label, size, r)
 	defer rep.StopUnarchivingProgress()
 
+	flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
 	tr := tar.NewReader(pr)
 	for {
 		hdr, err := tr.Next()
@@ -305,18 +723,27 @@ func (a *TarArchiver) Unarchive(ctx context.Context, path string, rep Reporter,
 				return errors.Wrap(err, "failed to create directory for entry found in tar file")
 			}
 
-		case tar.TypeReg: //regular file is written, must not exist yet
+		case tar.TypeReg: //regular file is written, must not exist yet unless a layer replaces it
 			if err = func() (err error) {
-				f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, hdr.FileInfo().Mode())
+				if err = os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+					return errors.Wrap(err, "failed to create parent directory for tar entry")
+				}
+
+				f, err := os.OpenFile(target, flag, hdr.FileInfo().Mode())
 				if err != nil {
 					return errors.Wrap(err, "failed to open new file for tar entry ")
 				}
 
 				defer f.Close()
-				if _, err := io.Copy(f, tr); err != nil {
+				h := sha256.New()
+				if _, err := io.Copy(io.MultiWriter(f, h), tr); err != nil {
 					return errors.Wrap(err, "failed to copy archived file content")
 				}
 
+				if sum, ok := sums[hdr.Name]; ok && sum != hex.EncodeToString(h.Sum(nil)) {
+					return errors.Wrapf(ErrDigestMismatch, "file '%s'", hdr.Name)
+				}
+
 				return nil
 			}(); err != nil {
 				return errors.Wrap(err, "failed to extract file")
@@ -324,3 +751,118 @@ func (a *TarArchiver) Unarchive(ctx context.Context, path string, rep Reporter,
 		}
 	}
 }
+
+//recordedDigests returns the digests of regular files in the table of contents of the archive
+//at 'prefix'. Archives without a table of contents have none, these are not scanned for them
+func (a *TarArchiver) recordedDigests(prefix string, fn func(k string, w io.WriterAt) error) (sums map[string]string, err error) {
+	sums = map[string]string{}
+	buf := &writeAtBuffer{}
+	if err = fn(slashpath.Join(prefix, TarArchiverTOCKey), buf); err != nil {
+		if errors.Cause(err) == transferstore.ErrObjectNotExists {
+			return sums, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to get table of contents")
+	}
+
+	toc, err := decodeTOC(bytes.NewReader(buf.buf))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range toc.Entries {
+		if e.Mode.IsRegular() && e.SHA256 != "" {
+			sums[e.Path] = e.SHA256
+		}
+	}
+
+	return sums, nil
+}
+
+//UnarchiveTar will call 'fn' for each object it needs and writes the dataset as a single tar
+//stream to 'w', instead of extracting it to a directory
+func (a *TarArchiver) UnarchiveTar(ctx context.Context, w io.Writer, rep Reporter, fn func(k string, w io.WriterAt) error) error {
+	if len(a.layers) > 0 {
+		return a.unarchiveLayersTar(ctx, w, rep, fn)
+	}
+
+	r, label, size, clean, err := a.open(a.keyPrefix, fn)
+	if err != nil {
+		return err
+	}
+
+	defer clean()
+	pr := rep.StartUnarchivingProgress(label, size, r)
+	defer rep.StopUnarchivingProgress()
+
+	if _, err = Copy(ctx, w, pr); err != nil {
+		return errors.Wrap(err, "failed to copy archive to tar stream")
+	}
+
+	return nil
+}
+
+//unarchiveLayersTar writes a single tar stream for an archive with layers, of each path only
+//the entry of the layer that wins is written
+func (a *TarArchiver) unarchiveLayersTar(ctx context.Context, w io.Writer, rep Reporter, fn func(k string, w io.WriterAt) error) error {
+	//for each path, the index of the last layer that contains it
+	winners := map[string]int{}
+	for i, prefix := range a.prefixes() {
+		toc, err := a.contents(ctx, prefix, fn)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range toc.Entries {
+			winners[e.Path] = i
+		}
+	}
+
+	tw := tar.NewWriter(w)
+	written := map[string]bool{}
+	for i, prefix := range a.prefixes() {
+		if err := func() error {
+			r, label, size, clean, err := a.open(prefix, fn)
+			if err != nil {
+				return err
+			}
+
+			defer clean()
+			pr := rep.StartUnarchivingProgress(label, size, r)
+			defer rep.StopUnarchivingProgress()
+
+			tr := tar.NewReader(pr)
+			for {
+				hdr, err := tr.Next()
+				switch {
+				case err == io.EOF:
+					return nil
+				case err != nil:
+					return errors.Wrap(err, "failed to read next header")
+				case written[hdr.Name]:
+					continue //directories are written once
+				case hdr.Typeflag != tar.TypeDir && winners[hdr.Name] != i:
+					continue //a later layer replaces the file
+				}
+
+				if err = tw.WriteHeader(hdr); err != nil {
+					return errors.Wrap(err, "failed to write tar header")
+				}
+
+				if _, err = Copy(ctx, tw, tr); err != nil {
+					return errors.Wrap(err, "failed to copy file content to tar stream")
+				}
+
+				written[hdr.Name] = true
+			}
+		}(); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "failed to finish tar stream")
+	}
+
+	return nil
+}
@@ -1,17 +1,21 @@
 package transferarchiver_test
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	transfer "github.com/nerdalize/nerd/pkg/transfer"
 	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
 )
 
 func archive(tb testing.TB, a transfer.Archiver, dir string, assertErr error) map[string][]byte {
@@ -78,17 +82,78 @@ func TestTarArchiver(t *testing.T) {
 		}
 
 		objs := archive(t, a, dir, nil)
-		if len(objs) != 1 {
-			t.Fatal("expected exactly one object from tar archiver")
+		if len(objs) != 2 {
+			t.Fatal("expected exactly two objects from tar archiver")
 		}
 
 		if len(objs[transferarchiver.TarArchiverKey]) == 0 {
 			t.Fatal("created tar bytes should not be empty")
 		}
 
+		t.Run("read table of contents", func(t *testing.T) {
+			toc, err := a.Contents(ctx, func(k string, w io.WriterAt) error {
+				_, err := w.WriteAt(objs[k], 0)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(toc.List("")) != 3 {
+				t.Fatalf("expected two directories and one file in the table of contents, got: %#v", toc.Entries)
+			}
+
+			e, ok := toc.Lookup("foo/bar/hello.txt")
+			if !ok {
+				t.Fatal("expected file to be in the table of contents")
+			}
+
+			if e.SHA256 == "" {
+				t.Fatal("expected a content digest to be recorded for the file")
+			}
+
+			content := objs[e.Key][e.Offset : e.Offset+e.Size]
+			if !bytes.Equal(content, []byte("hello, world")) {
+				t.Fatalf("expected offset and size to locate file content, got: %q", content)
+			}
+
+			t.Run("scan archive without table of contents", func(t *testing.T) {
+				toc2, err := a.Contents(ctx, func(k string, w io.WriterAt) error {
+					if k != transferarchiver.TarArchiverKey {
+						return transferstore.ErrObjectNotExists
+					}
+
+					_, err := w.WriteAt(objs[k], 0)
+					return err
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				e2, ok := toc2.Lookup("foo/bar/hello.txt")
+				if !ok || e2.Offset != e.Offset || e2.Size != e.Size {
+					t.Fatalf("expected scanned entry to equal recorded entry, got: %#v", e2)
+				}
+			})
+
+			t.Run("fail on other errors without downloading the archive", func(t *testing.T) {
+				cerr := errors.New("access denied")
+				_, err := a.Contents(ctx, func(k string, w io.WriterAt) error {
+					if k == transferarchiver.TarArchiverKey {
+						t.Fatal("archive should not be downloaded")
+					}
+
+					return cerr
+				})
+				if err == nil || !strings.Contains(err.Error(), cerr.Error()) {
+					t.Fatalf("expected the error of the table of contents, got: %v", err)
+				}
+			})
+		})
+
 		t.Run("unarchive to non-empty directory", func(t *testing.T) {
 			if err := a.Unarchive(ctx, dir, rep, func(k string, w io.WriterAt) error {
-				_, err := w.WriteAt(objs[transferarchiver.TarArchiverKey], 0)
+				_, err := w.WriteAt(objs[k], 0)
 				return err
 			}); err == nil {
 				t.Fatal("should error upon encountering a non empty directory for untar")
@@ -102,7 +167,7 @@ func TestTarArchiver(t *testing.T) {
 			}
 
 			if err = a.Unarchive(ctx, tdir, rep, func(k string, w io.WriterAt) error {
-				_, err = w.WriteAt(objs[transferarchiver.TarArchiverKey], 0)
+				_, err = w.WriteAt(objs[k], 0)
 				return err
 			}); err != nil {
 				t.Fatal(err)
@@ -127,5 +192,388 @@ func TestTarArchiver(t *testing.T) {
 				t.Fatalf("expected file permissions to equal what was archived, got: %s", fi.Mode())
 			}
 		})
+
+		t.Run("round trip through a tar stream", func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			if err = a.UnarchiveTar(ctx, buf, rep, func(k string, w io.WriterAt) error {
+				_, err = w.WriteAt(objs[k], 0)
+				return err
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			objs2 := map[string][]byte{}
+			if err = a.ArchiveTar(ctx, buf, rep, func(k string, r io.ReadSeeker, nbytes int64) error {
+				b := bytes.NewBuffer(nil)
+				_, err = io.Copy(b, r)
+				objs2[k] = b.Bytes()
+				return err
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			toc, err := a.Contents(ctx, func(k string, w io.WriterAt) error {
+				_, err = w.WriteAt(objs2[k], 0)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			e, ok := toc.Lookup("foo/bar/hello.txt")
+			if !ok {
+				t.Fatal("expected file to be in the table of contents of the re-archived stream")
+			}
+
+			content := objs2[e.Key][e.Offset : e.Offset+e.Size]
+			if !bytes.Equal(content, []byte("hello, world")) {
+				t.Fatalf("expected file content to survive the round trip, got: %q", content)
+			}
+		})
+
+		t.Run("archive empty tar stream", func(t *testing.T) {
+			err := a.ArchiveTar(ctx, bytes.NewReader(nil), rep, func(k string, r io.ReadSeeker, nbytes int64) error {
+				return nil
+			})
+			if err != transferarchiver.ErrEmptyDirectory {
+				t.Fatalf("expected empty stream to be refused, got: %v", err)
+			}
+		})
+
+		t.Run("archive tar stream without directory entries", func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			tw := tar.NewWriter(buf)
+			content := []byte("hello, world")
+			if err = tw.WriteHeader(&tar.Header{Name: "dir/file2", Typeflag: tar.TypeReg, Mode: 0600, Size: int64(len(content))}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = tw.Write(content); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			objs2 := map[string][]byte{}
+			if err = a.ArchiveTar(ctx, buf, rep, func(k string, r io.ReadSeeker, nbytes int64) error {
+				b := bytes.NewBuffer(nil)
+				_, err = io.Copy(b, r)
+				objs2[k] = b.Bytes()
+				return err
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			tdir, err := ioutil.TempDir("", "tar_unarchive_test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = a.Unarchive(ctx, tdir, rep, func(k string, w io.WriterAt) error {
+				_, err = w.WriteAt(objs2[k], 0)
+				return err
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := ioutil.ReadFile(filepath.Join(tdir, "dir", "file2"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(data, content) {
+				t.Fatalf("expected file content to be extracted, got: %q", data)
+			}
+		})
+
+		t.Run("refuse links in a tar stream", func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			tw := tar.NewWriter(buf)
+			if err = tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "foo/bar/hello.txt", Mode: 0777}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			err := a.ArchiveTar(ctx, buf, rep, func(k string, r io.ReadSeeker, nbytes int64) error {
+				return nil
+			})
+			if err == nil || !strings.Contains(err.Error(), "links are not supported") {
+				t.Fatalf("expected stream with a symlink to be refused, got: %v", err)
+			}
+		})
+
+		t.Run("append layer that replaces and adds files", func(t *testing.T) {
+			ldir, err := ioutil.TempDir("", "tar_archiver_tests_")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = os.MkdirAll(filepath.Join(ldir, "foo", "bar"), 0777); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = ioutil.WriteFile(filepath.Join(ldir, "foo", "bar", "hello.txt"), []byte("hello, layer"), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = ioutil.WriteFile(filepath.Join(ldir, "new.txt"), []byte("new"), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			get := func(k string, w io.WriterAt) error {
+				d, ok := objs[k]
+				if !ok {
+					return errors.New("object does not exist")
+				}
+
+				_, err := w.WriteAt(d, 0)
+				return err
+			}
+
+			t.Run("refuse layer that exceeds the size limit together with the existing content", func(t *testing.T) {
+				toc, err := a.Contents(ctx, get)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var existing int64
+				for _, e := range toc.Entries {
+					existing += e.Size
+				}
+
+				la, err := transferarchiver.NewTarArchiver(transferarchiver.ArchiverOptions{SizeLimit: existing + 1})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err = la.Append(ctx, ldir, rep, get, func(k string, r io.ReadSeeker, nbytes int64) error {
+					t.Fatalf("expected no objects to be written, got: %s", k)
+					return nil
+				}); err == nil || !strings.Contains(err.Error(), "too big") {
+					t.Fatalf("expected layer to be refused, got: %v", err)
+				}
+			})
+
+			layer, err := a.Append(ctx, ldir, rep, get, func(k string, r io.ReadSeeker, nbytes int64) error {
+				b := bytes.NewBuffer(nil)
+				_, err = io.Copy(b, r)
+				objs[k] = b.Bytes()
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			la, err := transferarchiver.NewTarArchiver(transferarchiver.ArchiverOptions{TarArchiverLayers: []string{layer}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			keys := 0
+			if err = la.Index(func(k string) error { keys++; return nil }); err != nil || keys != 4 {
+				t.Fatalf("expected index to include the objects of the layer, got %d keys: %v", keys, err)
+			}
+
+			toc, err := la.Contents(ctx, get)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(toc.List("")) != 4 {
+				t.Fatalf("expected layer to add one file to the table of contents, got: %#v", toc.Entries)
+			}
+
+			if e, _ := toc.Lookup("foo/bar/hello.txt"); e.Size != int64(len("hello, layer")) {
+				t.Fatalf("expected the file of the layer to replace the original, got: %#v", e)
+			}
+
+			tdir, err := ioutil.TempDir("", "tar_unarchive_test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = la.Unarchive(ctx, tdir, rep, get); err != nil {
+				t.Fatal(err)
+			}
+
+			for p, content := range map[string]string{"foo/bar/hello.txt": "hello, layer", "new.txt": "new"} {
+				d, err := ioutil.ReadFile(filepath.Join(tdir, filepath.FromSlash(p)))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(d) != content {
+					t.Fatalf("expected '%s' to contain %q after unarchiving layers, got: %q", p, content, d)
+				}
+			}
+
+			t.Run("unarchive layers to a tar stream", func(t *testing.T) {
+				buf := bytes.NewBuffer(nil)
+				if err := la.UnarchiveTar(ctx, buf, rep, get); err != nil {
+					t.Fatal(err)
+				}
+
+				names := map[string]int{}
+				tr := tar.NewReader(buf)
+				for {
+					hdr, err := tr.Next()
+					if err == io.EOF {
+						break
+					} else if err != nil {
+						t.Fatal(err)
+					}
+
+					names[hdr.Name]++
+					if hdr.Name == "foo/bar/hello.txt" {
+						d, _ := ioutil.ReadAll(tr)
+						if string(d) != "hello, layer" {
+							t.Fatalf("expected replaced file content in tar stream, got: %q", d)
+						}
+					}
+				}
+
+				if len(names) != 4 {
+					t.Fatalf("expected each path in the tar stream once, got: %v", names)
+				}
+
+				for n, c := range names {
+					if c != 1 {
+						t.Fatalf("expected '%s' to be written once, got: %d", n, c)
+					}
+				}
+			})
+		})
+	})
+}
+
+func TestTarArchiverParts(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	a, err := transferarchiver.NewTarArchiver(transferarchiver.ArchiverOptions{TarArchiverPartSize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Options().SizeLimit != 0 {
+		t.Fatalf("expected split archives to have no default size limit, got: %d", a.Options().SizeLimit)
+	}
+
+	dir, err := ioutil.TempDir("", "tar_archiver_tests_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	files := map[string][]byte{
+		"a.txt": bytes.Repeat([]byte("a"), 1000),
+		"b.txt": bytes.Repeat([]byte("b"), 1000),
+		"c.txt": bytes.Repeat([]byte("c"), 3000),
+	}
+
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objs := archive(t, a, dir, nil)
+	if _, ok := objs[transferarchiver.TarArchiverKey]; ok {
+		t.Fatal("expected a split archive to not store a single archive object")
+	}
+
+	var parts int
+	for k := range objs {
+		if a.Immutable(k) {
+			parts++
+		}
+	}
+
+	if parts < 2 {
+		t.Fatalf("expected the archive to be split into multiple parts, got: %d", parts)
+	}
+
+	get := func(k string, w io.WriterAt) error {
+		d, ok := objs[k]
+		if !ok {
+			return errors.New("object does not exist")
+		}
+
+		_, err := w.WriteAt(d, 0)
+		return err
+	}
+
+	t.Run("index includes all parts", func(t *testing.T) {
+		keys := map[string]bool{}
+		if err = a.PartIndex(ctx, get, func(k string) error { keys[k] = true; return nil }); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = a.Index(func(k string) error { keys[k] = true; return nil }); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(keys) != len(objs) {
+			t.Fatalf("expected index to cover all %d objects, got: %v", len(objs), keys)
+		}
+	})
+
+	t.Run("table of contents locates files in parts", func(t *testing.T) {
+		toc, err := a.Contents(ctx, get)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, content := range files {
+			e, ok := toc.Lookup(name)
+			if !ok {
+				t.Fatalf("expected '%s' in the table of contents", name)
+			}
+
+			if !a.Immutable(e.Key) {
+				t.Fatalf("expected entry to point to a part, got: %s", e.Key)
+			}
+
+			if !bytes.Equal(objs[e.Key][e.Offset:e.Offset+e.Size], content) {
+				t.Fatalf("expected offset and size to locate the content of '%s' in its part", name)
+			}
+		}
+	})
+
+	t.Run("unarchive split archive", func(t *testing.T) {
+		tdir, err := ioutil.TempDir("", "tar_unarchive_test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer os.RemoveAll(tdir)
+		if err = a.Unarchive(ctx, tdir, rep, get); err != nil {
+			t.Fatal(err)
+		}
+
+		for name, content := range files {
+			d, err := ioutil.ReadFile(filepath.Join(tdir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(d, content) {
+				t.Fatalf("expected unarchived content of '%s' to be equal", name)
+			}
+		}
+	})
+
+	t.Run("archiving again yields the same parts", func(t *testing.T) {
+		objs2 := archive(t, a, dir, nil)
+		for k := range objs2 {
+			if _, ok := objs[k]; a.Immutable(k) && !ok {
+				t.Fatalf("expected part '%s' to be named after its unchanged content", k)
+			}
+		}
 	})
 }
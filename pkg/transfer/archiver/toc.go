@@ -0,0 +1,317 @@
+package transferarchiver
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//TOCEntry describes a single file or directory that is part of an archive
+type TOCEntry struct {
+	Path    string      `json:"path"`
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"modTime"`
+	SHA256  string      `json:"sha256,omitempty"`
+
+	//Key and Offset locate the content of a regular file in the store, it
+	//allows for reading a single file using a ranged request
+	Key    string `json:"key"`
+	Offset int64  `json:"offset"`
+}
+
+//TOC is a table of contents that is stored next to the archived objects such
+//that content can be inspected without retrieving the archive as a whole
+type TOC struct {
+	Entries []TOCEntry `json:"entries"`
+}
+
+//Lookup returns the entry with the exact (slash separated) path 'p'
+func (toc *TOC) Lookup(p string) (e TOCEntry, ok bool) {
+	p = strings.Trim(p, TarArchiverPathSeparator)
+	for _, e = range toc.Entries {
+		if e.Path == p {
+			return e, true
+		}
+	}
+
+	return e, false
+}
+
+//List returns all entries at or below the (slash separated) path 'p', sorted by
+//their path. An empty path lists the whole archive
+func (toc *TOC) List(p string) (entries []TOCEntry) {
+	p = strings.Trim(p, TarArchiverPathSeparator)
+	for _, e := range toc.Entries {
+		if p == "" || e.Path == p || strings.HasPrefix(e.Path, p+TarArchiverPathSeparator) {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	return entries
+}
+
+//ListPaths returns the entries at or below each of the (slash separated) 'paths', entries
+//are returned once when paths overlap. Paths that don't exist are refused
+func (toc *TOC) ListPaths(paths []string) (entries []TOCEntry, err error) {
+	seen := map[string]bool{}
+	for _, p := range paths {
+		list := toc.List(p)
+		if len(list) == 0 {
+			return nil, errors.Errorf("path '%s' does not exist in dataset", p)
+		}
+
+		for _, e := range list {
+			if !seen[e.Path] {
+				seen[e.Path] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+//Overlay applies the entries of 'other' on top of this table of contents, entries
+//with the same path are replaced and new entries are added in order
+func (toc *TOC) Overlay(other *TOC) {
+	idx := map[string]int{}
+	for i, e := range toc.Entries {
+		idx[e.Path] = i
+	}
+
+	for _, e := range other.Entries {
+		if i, ok := idx[e.Path]; ok {
+			toc.Entries[i] = e
+			continue
+		}
+
+		idx[e.Path] = len(toc.Entries)
+		toc.Entries = append(toc.Entries, e)
+	}
+}
+
+//TOCChangeType describes how a file differs between two tables of contents
+type TOCChangeType string
+
+const (
+	//TOCChangeAdded is used for files that only exist in the new contents
+	TOCChangeAdded TOCChangeType = "added"
+
+	//TOCChangeRemoved is used for files that only exist in the old contents
+	TOCChangeRemoved TOCChangeType = "removed"
+
+	//TOCChangeModified is used for files that exist in both but differ in size or content
+	TOCChangeModified TOCChangeType = "modified"
+)
+
+//TOCChange describes a single regular file that differs between two tables of contents
+type TOCChange struct {
+	Type      TOCChangeType `json:"type"`
+	Path      string        `json:"path"`
+	OldSize   int64         `json:"oldSize"`
+	NewSize   int64         `json:"newSize"`
+	OldSHA256 string        `json:"oldSHA256,omitempty"`
+	NewSHA256 string        `json:"newSHA256,omitempty"`
+}
+
+//Diff compares the regular files of this table of contents with 'other' and returns
+//the changes sorted by path. Content is only compared when both sides recorded a
+//digest, contents scanned from older archives are compared by size alone
+func (toc *TOC) Diff(other *TOC) (changes []TOCChange) {
+	files := func(t *TOC) map[string]TOCEntry {
+		m := map[string]TOCEntry{}
+		for _, e := range t.Entries {
+			if e.Mode.IsRegular() {
+				m[e.Path] = e
+			}
+		}
+
+		return m
+	}
+
+	olds, news := files(toc), files(other)
+	for p, o := range olds {
+		n, ok := news[p]
+		switch {
+		case !ok:
+			changes = append(changes, TOCChange{Type: TOCChangeRemoved, Path: p, OldSize: o.Size, OldSHA256: o.SHA256})
+		case o.Size != n.Size,
+			o.SHA256 != "" && n.SHA256 != "" && o.SHA256 != n.SHA256:
+			changes = append(changes, TOCChange{Type: TOCChangeModified, Path: p, OldSize: o.Size, NewSize: n.Size, OldSHA256: o.SHA256, NewSHA256: n.SHA256})
+		}
+	}
+
+	for p, n := range news {
+		if _, ok := olds[p]; !ok {
+			changes = append(changes, TOCChange{Type: TOCChangeAdded, Path: p, NewSize: n.Size, NewSHA256: n.SHA256})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
+
+//IndexDir creates a table of contents for a local directory at 'path', including
+//the digest of every regular file, such that it can be compared with a dataset
+func IndexDir(ctx context.Context, path string) (toc *TOC, err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoSuchDirectory
+		}
+
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		return nil, ErrNotADirectory
+	}
+
+	toc = &TOC{}
+	if err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if fi == nil || path == p {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return errors.Wrap(err, "failed to determine relative path")
+		}
+
+		e := TOCEntry{
+			Path:    strings.Join(strings.Split(rel, string(filepath.Separator)), TarArchiverPathSeparator),
+			Mode:    fi.Mode(),
+			ModTime: fi.ModTime(),
+		}
+
+		if fi.Mode().IsRegular() {
+			e.Size = fi.Size()
+			if e.SHA256, err = hashFile(ctx, p); err != nil {
+				return err
+			}
+		}
+
+		toc.Entries = append(toc.Entries, e)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to walk directory")
+	}
+
+	return toc, nil
+}
+
+//hashFile returns the hex encoded sha256 digest of the file at 'p'
+func hashFile(ctx context.Context, p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open file for hashing")
+	}
+
+	defer f.Close()
+	h := sha256.New()
+	if _, err = Copy(ctx, h, f); err != nil {
+		return "", errors.Wrap(err, "failed to hash file content")
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//decodeTOC reads a json encoded table of contents from 'r'
+func decodeTOC(r io.Reader) (toc *TOC, err error) {
+	toc = &TOC{}
+	if err = json.NewDecoder(r).Decode(toc); err != nil {
+		return nil, errors.Wrap(err, "failed to decode table of contents")
+	}
+
+	return toc, nil
+}
+
+//scanTOC creates a table of contents by reading all headers of the tar stream 'r'
+//that was stored at key 'k'
+func scanTOC(k string, r io.Reader) (toc *TOC, err error) {
+	cr := &readCounter{Reader: r}
+	tr := tar.NewReader(cr)
+
+	toc = &TOC{}
+	for {
+		hdr, err := tr.Next()
+		switch {
+		case err == io.EOF:
+			return toc, nil
+		case err != nil:
+			return nil, errors.Wrap(err, "failed to read next header")
+		case hdr == nil:
+			continue
+		}
+
+		//the tar reader doesn't buffer so after reading the header the count
+		//equals the offset at which the content starts
+		toc.Entries = append(toc.Entries, TOCEntry{
+			Path:    strings.Trim(hdr.Name, TarArchiverPathSeparator),
+			Size:    hdr.Size,
+			Mode:    hdr.FileInfo().Mode(),
+			ModTime: hdr.ModTime,
+			Key:     k,
+			Offset:  cr.n,
+		})
+	}
+}
+
+//readCounter keeps track of how many bytes were read
+type readCounter struct {
+	io.Reader
+	n int64
+}
+
+func (rc *readCounter) Read(p []byte) (n int, err error) {
+	n, err = rc.Reader.Read(p)
+	rc.n += int64(n)
+	return n, err
+}
+
+//writeAtBuffer is a simple in-memory implementation of io.WriterAt, what was written
+//can be read back such that it can be verified
+type writeAtBuffer struct{ buf []byte }
+
+func (b *writeAtBuffer) ReadAt(p []byte, off int64) (n int, err error) {
+	if off >= int64(len(b.buf)) {
+		return 0, io.EOF
+	}
+
+	if n = copy(p, b.buf[off:]); n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (b *writeAtBuffer) WriteAt(p []byte, off int64) (n int, err error) {
+	if end := off + int64(len(p)); end > int64(len(b.buf)) {
+		nbuf := make([]byte, end)
+		copy(nbuf, b.buf)
+		b.buf = nbuf
+	}
+
+	return copy(b.buf[off:], p), nil
+}
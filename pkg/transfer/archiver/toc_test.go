@@ -0,0 +1,70 @@
+package transferarchiver_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+)
+
+func TestTOCDiff(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "toc_tests_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"same.txt":     "hello, world",
+		"modified.txt": "hello, world",
+		"removed.txt":  "bye, world",
+	} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	toc1, err := transferarchiver.IndexDir(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err1 := ioutil.WriteFile(filepath.Join(dir, "modified.txt"), []byte("hello, World"), 0600)
+	err2 := os.Remove(filepath.Join(dir, "removed.txt"))
+	err3 := os.MkdirAll(filepath.Join(dir, "foo"), 0777)
+	err4 := ioutil.WriteFile(filepath.Join(dir, "foo", "added.txt"), []byte("hi"), 0600)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		t.Fatal(err1, err2, err3, err4)
+	}
+
+	toc2, err := transferarchiver.IndexDir(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := toc1.Diff(toc2)
+	if len(changes) != 3 {
+		t.Fatalf("expected exactly three changes, got: %#v", changes)
+	}
+
+	for i, exp := range []struct {
+		path string
+		typ  transferarchiver.TOCChangeType
+	}{
+		{"foo/added.txt", transferarchiver.TOCChangeAdded},
+		{"modified.txt", transferarchiver.TOCChangeModified},
+		{"removed.txt", transferarchiver.TOCChangeRemoved},
+	} {
+		if changes[i].Path != exp.path || changes[i].Type != exp.typ {
+			t.Fatalf("expected change %d to be '%s' for '%s', got: %#v", i, exp.typ, exp.path, changes[i])
+		}
+	}
+
+	if len(toc2.Diff(toc2)) != 0 {
+		t.Fatal("expected no changes when comparing with itself")
+	}
+}
@@ -0,0 +1,756 @@
+package transferarchiver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	slashpath "path"
+
+	humanize "github.com/dustin/go-humanize"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	//ZipArchiverKey configures the one object key returned by the zip Archiver
+	ZipArchiverKey = "archive.zip"
+
+	//ZipArchiverReadAhead is the minimal number of bytes that is requested when the central
+	//directory is read with ranged requests, the zip reader itself reads in small chunks
+	ZipArchiverReadAhead = int64(64 * 1024)
+
+	//ErrAppendNotSupported is returned when content is appended to an archive that has no layers
+	ErrAppendNotSupported = errors.New("dataset archive cannot be appended to, upload the dataset again instead")
+)
+
+//ObjectReader reads (ranges of) objects such that an archive can be inspected without downloading it
+type ObjectReader interface {
+	Head(ctx context.Context, k string) (size int64, err error)
+	GetRange(ctx context.Context, k string, off, n int64, w io.Writer) error
+}
+
+//ZipArchiver will archive a directory into a single zip file. Files are stored without
+//compression such that they can be read with a single ranged request, the table of contents
+//is read from the central directory at the end of the archive
+type ZipArchiver struct {
+	keyPrefix string
+	sizeLimit int64
+	objects   ObjectReader
+}
+
+//NewZipArchiver will setup the zip archiver, 'objects' is used to read the central directory
+func NewZipArchiver(opts ArchiverOptions, objects ObjectReader) (a *ZipArchiver, err error) {
+	a = &ZipArchiver{keyPrefix: opts.KeyPrefix, sizeLimit: opts.SizeLimit, objects: objects}
+	if a.keyPrefix != "" && !strings.HasSuffix(a.keyPrefix, "/") {
+		return nil, errors.Errorf("archiver key prefix must end with a forward slash")
+	}
+
+	if opts.TarArchiverPartSize > 0 || opts.TarArchiverPoolPrefix != "" || len(opts.TarArchiverLayers) > 0 {
+		return nil, errors.Errorf("zip archives cannot be split into parts or layers")
+	}
+
+	if a.objects == nil {
+		return nil, errors.New("zip archiver requires a store to read objects from")
+	}
+
+	if a.sizeLimit <= 0 {
+		a.sizeLimit = SizeLimit
+	}
+
+	return a, nil
+}
+
+//Options returns the options the archiver can be setup with again
+func (a *ZipArchiver) Options() ArchiverOptions {
+	return ArchiverOptions{
+		Type:      ArchiverTypeZip,
+		KeyPrefix: a.keyPrefix,
+		SizeLimit: a.sizeLimit,
+	}
+}
+
+func (a *ZipArchiver) key() string {
+	return slashpath.Join(a.keyPrefix, ZipArchiverKey)
+}
+
+//Index calls 'fn' for the one object of the archive
+func (a *ZipArchiver) Index(fn func(k string) error) error {
+	return fn(a.key())
+}
+
+//zipEntryMeta is stored as the comment of each entry in the central directory, it holds
+//what the table of contents needs but the zip format doesn't record
+type zipEntryMeta struct {
+	SHA256 string `json:"sha256,omitempty"`
+	Offset int64  `json:"offset"`
+}
+
+//Contents returns the table of contents by reading only the central directory of the archive
+//with ranged requests, 'fn' is not called as the archive is never downloaded as a whole
+func (a *ZipArchiver) Contents(ctx context.Context, fn func(k string, w io.WriterAt) error) (toc *TOC, err error) {
+	k := a.key()
+	size, err := a.objects.Head(ctx, k)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get archive size")
+	}
+
+	zr, err := zip.NewReader(&rangeReaderAt{ctx: ctx, objects: a.objects, k: k, size: size}, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read central directory")
+	}
+
+	toc = &TOC{}
+	for _, f := range zr.File {
+		e := TOCEntry{
+			Path:    strings.Trim(f.Name, TarArchiverPathSeparator),
+			Size:    int64(f.UncompressedSize64),
+			Mode:    f.Mode(),
+			ModTime: f.Modified,
+			Key:     k,
+		}
+
+		if e.Mode.IsRegular() {
+			meta := zipEntryMeta{}
+			if json.Unmarshal([]byte(f.Comment), &meta) != nil || meta.Offset <= 0 {
+				if meta.Offset, err = f.DataOffset(); err != nil {
+					return nil, errors.Wrapf(err, "failed to locate content of '%s'", f.Name)
+				}
+			}
+
+			if f.Method != zip.Store {
+				e.Key = "" //compressed content cannot be read with a ranged request
+			}
+
+			e.SHA256 = meta.SHA256
+			e.Offset = meta.Offset
+		}
+
+		toc.Entries = append(toc.Entries, e)
+	}
+
+	return toc, nil
+}
+
+//Archive will archive a directory at 'path' into a zip file and call 'fn' for it
+func (a *ZipArchiver) Archive(ctx context.Context, path string, rep Reporter, fn func(k string, r io.ReadSeeker, nbytes int64) error) (err error) {
+	if err = checkValidDir(path); err != nil {
+		return err
+	}
+
+	var total int64
+	if err = walkDir(path, func(p, rel string, fi os.FileInfo) error {
+		if fi.Mode().IsRegular() {
+			total += fi.Size()
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "failed to index filesystem")
+	}
+
+	if total > a.sizeLimit {
+		return errors.Errorf(ErrDatasetTooLarge, humanize.Bytes(uint64(a.sizeLimit)))
+	}
+
+	zw, err := a.newZipWriter()
+	if err != nil {
+		return err
+	}
+
+	defer zw.clean()
+	inc := rep.StartArchivingProgress(zw.f.Name(), total)
+	if err = walkDir(path, func(p, rel string, fi os.FileInfo) error {
+		hdr, err := zip.FileInfoHeader(fi)
+		if err != nil {
+			return errors.Wrap(err, "failed to convert file info to zip header")
+		}
+
+		//write header with a filename that standardizes the separator
+		hdr.Name = strings.Join(strings.Split(rel, string(filepath.Separator)), TarArchiverPathSeparator)
+		if !fi.Mode().IsRegular() {
+			return zw.writeEntry(ctx, hdr, nil)
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return errors.Wrap(err, "failed to open file for archiving")
+		}
+
+		defer f.Close()
+		if err = zw.writeEntry(ctx, hdr, f); err != nil {
+			return err
+		}
+
+		inc(fi.Size())
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "failed to perform filesystem walk")
+	}
+
+	rep.StopArchivingProgress()
+	return zw.finish(a.key(), fn)
+}
+
+//ArchiveTar will read a tar stream from 'r' and turn it into a zip file for which 'fn' is
+//called. Entry names are normalized and the same size limit as for directories applies
+func (a *ZipArchiver) ArchiveTar(ctx context.Context, r io.Reader, rep Reporter, fn func(k string, r io.ReadSeeker, nbytes int64) error) (err error) {
+	zw, err := a.newZipWriter()
+	if err != nil {
+		return err
+	}
+
+	defer zw.clean()
+	inc := rep.StartArchivingProgress(zw.f.Name(), 0) //total is unknown for streams
+
+	var total int64
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "failed to read next header")
+		}
+
+		name := slashpath.Clean(strings.TrimLeft(hdr.Name, TarArchiverPathSeparator))
+		if name == "." {
+			continue //the root of the stream, our archives don't include it
+		}
+
+		if name == ".." || strings.HasPrefix(name, "../") {
+			return errors.Errorf("tar entry '%s' points outside of the dataset", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeRegA:
+		case tar.TypeXGlobalHeader:
+			continue //metadata for the whole stream, e.g. from 'git archive'
+		case tar.TypeSymlink, tar.TypeLink:
+			return errors.Errorf("tar entry '%s' is a link, links are not supported in datasets", hdr.Name)
+		default:
+			return errors.Errorf("tar entry '%s' is not a regular file or directory", hdr.Name)
+		}
+
+		total += hdr.Size
+		if total > a.sizeLimit {
+			return errors.Errorf(ErrDatasetTooLarge, humanize.Bytes(uint64(a.sizeLimit)))
+		}
+
+		zhdr, err := zip.FileInfoHeader(hdr.FileInfo())
+		if err != nil {
+			return errors.Wrap(err, "failed to convert tar header to zip header")
+		}
+
+		zhdr.Name = name
+		if hdr.Typeflag == tar.TypeDir {
+			err = zw.writeEntry(ctx, zhdr, nil)
+		} else {
+			err = zw.writeEntry(ctx, zhdr, tr)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		inc(hdr.Size)
+	}
+
+	if zw.entries == 0 {
+		return ErrEmptyDirectory
+	}
+
+	rep.StopArchivingProgress()
+	return zw.finish(a.key(), fn)
+}
+
+//Append is not supported, zip archives have no layers
+func (a *ZipArchiver) Append(ctx context.Context, path string, rep Reporter, get func(k string, w io.WriterAt) error, fn func(k string, r io.ReadSeeker, nbytes int64) error) (layer string, err error) {
+	return "", ErrAppendNotSupported
+}
+
+//download calls 'fn' to write the archive to a temporary file and opens it, the returned function removes it
+func (a *ZipArchiver) download(fn func(k string, w io.WriterAt) error) (zr *zip.Reader, tmpf *os.File, clean func(), err error) {
+	if tmpf, err = ioutil.TempFile("", "zip_archiver_"); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to create temporary file")
+	}
+
+	clean = func() {
+		_ = tmpf.Close()
+		_ = os.Remove(tmpf.Name())
+	}
+
+	if err = fn(a.key(), tmpf); err != nil {
+		clean()
+		return nil, nil, nil, errors.Wrap(err, "failed to download to temporary file")
+	}
+
+	fi, err := tmpf.Stat()
+	if err != nil {
+		clean()
+		return nil, nil, nil, errors.Wrap(err, "failed to stat temporary file")
+	}
+
+	if zr, err = zip.NewReader(tmpf, fi.Size()); err != nil {
+		clean()
+		return nil, nil, nil, errors.Wrap(err, "failed to read zip archive")
+	}
+
+	return zr, tmpf, clean, nil
+}
+
+//entryName returns the normalized name of a zip entry, names that point outside of the dataset are refused
+func entryName(f *zip.File) (name string, err error) {
+	name = slashpath.Clean(strings.TrimLeft(f.Name, TarArchiverPathSeparator))
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", errors.Errorf("zip entry '%s' points outside of the dataset", f.Name)
+	}
+
+	return name, nil
+}
+
+//Unarchive will call 'fn' for the archive object and extract it into the directory at 'path'
+func (a *ZipArchiver) Unarchive(ctx context.Context, path string, rep Reporter, fn func(k string, w io.WriterAt) error) error {
+	err := checkTargetDir(path)
+	if err != nil {
+		return err
+	}
+
+	zr, tmpf, clean, err := a.download(fn)
+	if err != nil {
+		return err
+	}
+
+	defer clean()
+	var total int64
+	for _, f := range zr.File {
+		total += int64(f.UncompressedSize64)
+	}
+
+	er := &entryReader{}
+	pr := rep.StartUnarchivingProgress(tmpf.Name(), total, er)
+	defer rep.StopUnarchivingProgress()
+
+	for _, f := range zr.File {
+		name, err := entryName(f)
+		if err != nil {
+			return err
+		}
+
+		parts := []string{path}
+		parts = append(parts, strings.Split(name, TarArchiverPathSeparator)...)
+		target := filepath.Join(parts...)
+
+		switch {
+		case f.Mode().IsDir():
+			if err = os.MkdirAll(target, f.Mode().Perm()); err != nil {
+				return errors.Wrap(err, "failed to create directory for entry found in zip file")
+			}
+
+		case f.Mode().IsRegular():
+			if err = func() (err error) {
+				meta := zipEntryMeta{}
+				_ = json.Unmarshal([]byte(f.Comment), &meta)
+
+				rc, err := f.Open()
+				if err != nil {
+					return errors.Wrap(err, "failed to open zip entry")
+				}
+
+				defer rc.Close()
+				out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, f.Mode().Perm())
+				if err != nil {
+					return errors.Wrap(err, "failed to open new file for zip entry")
+				}
+
+				defer out.Close()
+				h := sha256.New()
+				er.r = rc
+				if _, err = Copy(ctx, io.MultiWriter(out, h), pr); err != nil {
+					return errors.Wrap(err, "failed to copy archived file content")
+				}
+
+				if meta.SHA256 != "" && meta.SHA256 != hex.EncodeToString(h.Sum(nil)) {
+					return errors.Wrapf(ErrDigestMismatch, "file '%s'", name)
+				}
+
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "failed to extract file")
+			}
+		}
+	}
+
+	return nil
+}
+
+//UnarchivePaths extracts the files at or below the (slash separated) 'paths' into the directory
+//at 'path'. Only the central directory and the content of those files is read, with ranged
+//requests, 'fn' is not called
+func (a *ZipArchiver) UnarchivePaths(ctx context.Context, path string, paths []string, rep Reporter, fn func(k string, w io.WriterAt) error) error {
+	err := checkTargetDir(path)
+	if err != nil {
+		return err
+	}
+
+	toc, err := a.Contents(ctx, fn)
+	if err != nil {
+		return err
+	}
+
+	entries := toc.Entries
+	if paths != nil {
+		if entries, err = toc.ListPaths(paths); err != nil {
+			return err
+		}
+	}
+
+	var total int64
+	for _, e := range entries {
+		if e.Mode.IsRegular() && e.Key == "" {
+			return errors.Errorf("file '%s' is compressed, it can only be downloaded with the whole dataset", e.Path)
+		}
+
+		total += e.Size
+	}
+
+	prog := newProgress(rep.StartUnarchivingProgress(path, total, zeroReader{}))
+	defer rep.StopUnarchivingProgress()
+
+	for _, e := range entries {
+		target := entryPath(path, e)
+		if e.Mode.IsDir() {
+			if err = os.MkdirAll(target, e.Mode.Perm()|0700); err != nil {
+				return errors.Wrap(err, "failed to create directory")
+			}
+
+			continue
+		}
+
+		if !e.Mode.IsRegular() {
+			continue
+		}
+
+		if err = os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+			return errors.Wrap(err, "failed to create directory")
+		}
+
+		if err = a.extractRange(ctx, target, e); err != nil {
+			return errors.Wrap(err, "failed to extract file")
+		}
+
+		prog.add(e.Size)
+	}
+
+	return nil
+}
+
+//extractRange reads the content of entry 'e' with a ranged request and writes it to a new file at 'target'
+func (a *ZipArchiver) extractRange(ctx context.Context, target string, e TOCEntry) (err error) {
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, e.Mode.Perm())
+	if err != nil {
+		return errors.Wrap(err, "failed to open new file for zip entry")
+	}
+
+	defer out.Close()
+	h := sha256.New()
+	if e.Size > 0 {
+		if err = a.objects.GetRange(ctx, e.Key, e.Offset, e.Size, io.MultiWriter(out, h)); err != nil {
+			return errors.Wrapf(err, "failed to read content of '%s'", e.Path)
+		}
+	}
+
+	if e.SHA256 != "" && e.SHA256 != hex.EncodeToString(h.Sum(nil)) {
+		return errors.Wrapf(ErrDigestMismatch, "file '%s'", e.Path)
+	}
+
+	return nil
+}
+
+//UnarchiveTar will call 'fn' for the archive object and write its entries as a single tar stream to 'w'
+func (a *ZipArchiver) UnarchiveTar(ctx context.Context, w io.Writer, rep Reporter, fn func(k string, w io.WriterAt) error) error {
+	zr, tmpf, clean, err := a.download(fn)
+	if err != nil {
+		return err
+	}
+
+	defer clean()
+	var total int64
+	for _, f := range zr.File {
+		total += int64(f.UncompressedSize64)
+	}
+
+	er := &entryReader{}
+	pr := rep.StartUnarchivingProgress(tmpf.Name(), total, er)
+	defer rep.StopUnarchivingProgress()
+
+	tw := tar.NewWriter(w)
+	for _, f := range zr.File {
+		name, err := entryName(f)
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(f.FileInfo(), "")
+		if err != nil {
+			return errors.Wrap(err, "failed to convert zip header to tar header")
+		}
+
+		hdr.Name = name
+		if err = tw.WriteHeader(hdr); err != nil {
+			return errors.Wrap(err, "failed to write tar header")
+		}
+
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		if err = func() error {
+			rc, err := f.Open()
+			if err != nil {
+				return errors.Wrap(err, "failed to open zip entry")
+			}
+
+			defer rc.Close()
+			er.r = rc
+			if _, err = Copy(ctx, tw, pr); err != nil {
+				return errors.Wrap(err, "failed to copy file content to tar stream")
+			}
+
+			return nil
+		}(); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "failed to finish tar stream")
+	}
+
+	return nil
+}
+
+//walkDir calls 'fn' for everything below 'path' with its path relative to it
+func walkDir(path string, fn func(p, rel string, fi os.FileInfo) error) error {
+	return filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if fi == nil || path == p {
+			return nil //this is triggered when a directory doesn't have an executable bit
+		}
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return errors.Wrap(err, "failed to determine relative path")
+		}
+
+		return fn(p, rel, fi)
+	})
+}
+
+//zipWriter writes entries to a temporary zip file, file contents are buffered in a
+//second temporary file as their digest must be known before the header is written
+type zipWriter struct {
+	*zip.Writer
+	f       *os.File
+	buf     *os.File
+	entries int
+}
+
+func (a *ZipArchiver) newZipWriter() (zw *zipWriter, err error) {
+	zw = &zipWriter{}
+	if zw.f, err = ioutil.TempFile("", "zip_archiver_"); err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary file")
+	}
+
+	if zw.buf, err = ioutil.TempFile("", "zip_archiver_buf_"); err != nil {
+		zw.clean()
+		return nil, errors.Wrap(err, "failed to create temporary file")
+	}
+
+	zw.Writer = zip.NewWriter(zw.f)
+	return zw, nil
+}
+
+func (zw *zipWriter) clean() {
+	for _, f := range []*os.File{zw.f, zw.buf} {
+		if f != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}
+}
+
+//writeEntry writes a stored entry for 'hdr', for regular files the content is copied from 'r'.
+//The digest and the offset of the content are recorded in the comment of the entry
+func (zw *zipWriter) writeEntry(ctx context.Context, hdr *zip.FileHeader, r io.Reader) (err error) {
+	hdr.Method = zip.Store
+	if hdr.FileInfo().IsDir() {
+		hdr.Name = strings.TrimSuffix(hdr.Name, TarArchiverPathSeparator) + TarArchiverPathSeparator
+		if _, err = zw.CreateHeader(hdr); err != nil {
+			return errors.Wrap(err, "failed to write zip header")
+		}
+
+		zw.entries++
+		return nil
+	}
+
+	if err = zw.buf.Truncate(0); err != nil {
+		return errors.Wrap(err, "failed to truncate buffer file")
+	}
+
+	if _, err = zw.buf.Seek(0, 0); err != nil {
+		return errors.Wrap(err, "failed to seek to beginning of buffer file")
+	}
+
+	h := sha256.New()
+	if r != nil {
+		if _, err = Copy(ctx, io.MultiWriter(zw.buf, h), r); err != nil {
+			return errors.Wrap(err, "failed to copy file content to buffer")
+		}
+	}
+
+	if _, err = zw.buf.Seek(0, 0); err != nil {
+		return errors.Wrap(err, "failed to seek to beginning of buffer file")
+	}
+
+	//the comment only goes into the central directory so it doesn't change the size of the local
+	//header, which is measured by writing the same header to a separate writer first
+	if err = zw.Flush(); err != nil {
+		return errors.Wrap(err, "failed to flush zip writer")
+	}
+
+	offset, err := zw.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return errors.Wrap(err, "failed to determine header offset")
+	}
+
+	hsize, err := localHeaderSize(*hdr)
+	if err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(zipEntryMeta{SHA256: hex.EncodeToString(h.Sum(nil)), Offset: offset + hsize})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode entry metadata")
+	}
+
+	hdr.Comment = string(meta)
+	w, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return errors.Wrap(err, "failed to write zip header")
+	}
+
+	if _, err = io.Copy(w, zw.buf); err != nil {
+		return errors.Wrap(err, "failed to copy file content to archive")
+	}
+
+	zw.entries++
+	return nil
+}
+
+//finish closes the zip file and calls 'fn' to store it at key 'k'
+func (zw *zipWriter) finish(k string, fn func(k string, r io.ReadSeeker, nbytes int64) error) (err error) {
+	if err = zw.Close(); err != nil {
+		return errors.Wrap(err, "failed to finish zip file")
+	}
+
+	if _, err = zw.f.Seek(0, 0); err != nil {
+		return errors.Wrap(err, "failed to seek to beginning of file")
+	}
+
+	fi, err := zw.f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "failed to stat the temporary file")
+	}
+
+	return fn(k, zw.f, fi.Size())
+}
+
+//localHeaderSize returns the number of bytes the zip writer writes before the content of 'hdr'
+func localHeaderSize(hdr zip.FileHeader) (int64, error) {
+	cw := &countWriter{}
+	zw := zip.NewWriter(cw)
+	if _, err := zw.CreateHeader(&hdr); err != nil {
+		return 0, errors.Wrap(err, "failed to measure zip header")
+	}
+
+	if err := zw.Flush(); err != nil {
+		return 0, errors.Wrap(err, "failed to measure zip header")
+	}
+
+	return cw.n, nil
+}
+
+//countWriter discards what is written but keeps a count
+type countWriter struct{ n int64 }
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	cw.n += int64(len(p))
+	return len(p), nil
+}
+
+//entryReader reads from the entry that is currently extracted, it allows a single progress
+//reader to report on all entries of the archive
+type entryReader struct{ r io.Reader }
+
+func (er *entryReader) Read(p []byte) (n int, err error) { return er.r.Read(p) }
+
+//rangeReaderAt reads an object with ranged requests, at least 'ZipArchiverReadAhead' bytes are
+//requested at a time and the last response is kept to serve subsequent small reads
+type rangeReaderAt struct {
+	ctx     context.Context
+	objects ObjectReader
+	k       string
+	size    int64
+
+	off int64
+	buf []byte
+}
+
+func (r *rangeReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(p))
+	if end > r.size {
+		end = r.size
+	}
+
+	if off < r.off || end > r.off+int64(len(r.buf)) {
+		n := end - off
+		if n < ZipArchiverReadAhead {
+			n = ZipArchiverReadAhead
+		}
+
+		if off+n > r.size {
+			n = r.size - off
+		}
+
+		buf := bytes.NewBuffer(make([]byte, 0, n))
+		if err = r.objects.GetRange(r.ctx, r.k, off, n, buf); err != nil {
+			return 0, errors.Wrapf(err, "failed to read range of '%s'", r.k)
+		}
+
+		r.off, r.buf = off, buf.Bytes()
+	}
+
+	if n = copy(p, r.buf[off-r.off:]); n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
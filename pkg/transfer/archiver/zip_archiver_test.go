@@ -0,0 +1,223 @@
+package transferarchiver_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+)
+
+func TestZipArchiver(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, err := ioutil.TempDir("", "zip_archiver_tests_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	store, err := transferstore.NewFSStore(transferstore.StoreOptions{FSStoreDir: filepath.Join(dir, "objects")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(dir, "src")
+	if err = os.MkdirAll(filepath.Join(src, "foo", "bar"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(src, "foo", "bar", "hello.txt"), []byte("hello, world"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(src, "empty.txt"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := transferarchiver.NewZipArchiver(transferarchiver.ArchiverOptions{KeyPrefix: "my-prefix/"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(k string, r io.ReadSeeker, nbytes int64) error { return store.Put(ctx, k, r) }
+	get := func(k string, w io.WriterAt) error { return store.Get(ctx, k, w) }
+	if err = a.Archive(ctx, src, rep, put); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("archive opens as a regular zip file", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		size, err := store.Head(ctx, "my-prefix/archive.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = store.GetRange(ctx, "my-prefix/archive.zip", 0, size, buf); err != nil {
+			t.Fatal(err)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), size)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := map[string]bool{}
+		for _, f := range zr.File {
+			names[f.Name] = true
+		}
+
+		for _, name := range []string{"foo/", "foo/bar/", "foo/bar/hello.txt", "empty.txt"} {
+			if !names[name] {
+				t.Fatalf("expected entry '%s' with slash separators, got: %v", name, names)
+			}
+		}
+	})
+
+	t.Run("contents are read from the central directory", func(t *testing.T) {
+		toc, err := a.Contents(ctx, func(k string, w io.WriterAt) error {
+			t.Fatalf("expected no full download of '%s'", k)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		e, ok := toc.Lookup("foo/bar/hello.txt")
+		if !ok || e.Size != 12 || !e.Mode.IsRegular() || e.SHA256 == "" {
+			t.Fatalf("unexpected entry: %#v", e)
+		}
+
+		buf := &bytes.Buffer{}
+		if err = store.GetRange(ctx, e.Key, e.Offset, e.Size, buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != "hello, world" {
+			t.Fatalf("expected the entry to locate the file content, got: %q", buf.String())
+		}
+
+		if e, ok = toc.Lookup("foo/bar"); !ok || !e.Mode.IsDir() {
+			t.Fatalf("expected directory entry, got: %#v", e)
+		}
+	})
+
+	t.Run("unarchive into a directory", func(t *testing.T) {
+		dst := filepath.Join(dir, "dst")
+		if err := a.Unarchive(ctx, dst, rep, get); err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := ioutil.ReadFile(filepath.Join(dst, "foo", "bar", "hello.txt"))
+		if err != nil || string(d) != "hello, world" {
+			t.Fatalf("unexpected content: %q, %v", d, err)
+		}
+	})
+
+	t.Run("unarchive some paths with ranged requests", func(t *testing.T) {
+		dst := filepath.Join(dir, "dst-paths")
+		if err := a.UnarchivePaths(ctx, dst, []string{"foo/bar"}, rep, func(k string, w io.WriterAt) error {
+			t.Fatalf("expected no full download of '%s'", k)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := ioutil.ReadFile(filepath.Join(dst, "foo", "bar", "hello.txt"))
+		if err != nil || string(d) != "hello, world" {
+			t.Fatalf("unexpected content: %q, %v", d, err)
+		}
+
+		if _, err = os.Stat(filepath.Join(dst, "empty.txt")); !os.IsNotExist(err) {
+			t.Fatalf("expected other files to be left out, got: %v", err)
+		}
+
+		if err = a.UnarchivePaths(ctx, filepath.Join(dir, "dst-bogus"), []string{"bogus"}, rep, get); err == nil {
+			t.Fatal("expected path that doesn't exist to be refused")
+		}
+	})
+
+	t.Run("unarchive as a tar stream and archive it again", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := a.UnarchiveTar(ctx, buf, rep, get); err != nil {
+			t.Fatal(err)
+		}
+
+		tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+		n := 0
+		for {
+			_, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			n++
+		}
+
+		if n != 4 {
+			t.Fatalf("expected 4 tar entries, got: %d", n)
+		}
+
+		b, err := transferarchiver.NewZipArchiver(transferarchiver.ArchiverOptions{KeyPrefix: "other/"}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = b.ArchiveTar(ctx, bytes.NewReader(buf.Bytes()), rep, put); err != nil {
+			t.Fatal(err)
+		}
+
+		toc, err := b.Contents(ctx, get)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if e, ok := toc.Lookup("foo/bar/hello.txt"); !ok || e.Size != 12 {
+			t.Fatalf("unexpected entry: %#v", e)
+		}
+	})
+
+	t.Run("refuse links in a tar stream", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		tw := tar.NewWriter(buf)
+		if err := tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "foo/bar/hello.txt", Mode: 0777}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		err := a.ArchiveTar(ctx, buf, rep, put)
+		if err == nil || !strings.Contains(err.Error(), "links are not supported") {
+			t.Fatalf("expected stream with a symlink to be refused, got: %v", err)
+		}
+	})
+
+	t.Run("size limit and append", func(t *testing.T) {
+		b, err := transferarchiver.NewZipArchiver(transferarchiver.ArchiverOptions{SizeLimit: 4}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = b.Archive(ctx, src, rep, put); err == nil {
+			t.Fatal("expected archive to be too large")
+		}
+
+		if _, err = b.Append(ctx, src, rep, nil, put); err != transferarchiver.ErrAppendNotSupported {
+			t.Fatalf("expected append to be refused, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,377 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	//CacheEntryFile holds the metadata of a cached dataset
+	CacheEntryFile = "entry.json"
+
+	//CacheDataDir holds the files of a cached dataset
+	CacheDataDir = "data"
+
+	//cacheStaleFill is the age after which an incomplete entry is assumed to be abandoned
+	cacheStaleFill = 24 * time.Hour
+)
+
+//CacheEntry describes a dataset version that is kept in the cache
+type CacheEntry struct {
+	ID       string    `json:"-"`
+	Dataset  string    `json:"dataset"`
+	Version  string    `json:"version"`
+	Size     int64     `json:"size"`
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+//Cache keeps the files of pulled datasets in a local directory. A dataset is identified by
+//its name and a fingerprint of its objects such that it is only downloaded again when
+//the objects were changed. When the cache grows larger than its maximum size the least
+//recently used datasets are removed
+type Cache struct {
+	dir     string
+	maxSize int64
+	link    bool
+}
+
+//NewCache sets up a cache in 'dir' that is pruned to 'maxSize' bytes after a dataset is
+//added, a size of zero or less disables pruning. With 'link' set files are hardlinked out
+//of the cache instead of copied, these must then never be modified
+func NewCache(dir string, maxSize int64, link bool) (c *Cache, err error) {
+	c = &Cache{dir: dir, maxSize: maxSize, link: link}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create cache directory")
+	}
+
+	return c, nil
+}
+
+//id returns the name of the cache entry for a version of a dataset
+func (c *Cache) id(dataset, version string) string {
+	sum := sha256.Sum256([]byte(dataset + "\x00" + version))
+	return hex.EncodeToString(sum[:16])
+}
+
+//readEntry reads the metadata of the cache entry with 'id'
+func (c *Cache) readEntry(id string) (e *CacheEntry, err error) {
+	d, err := ioutil.ReadFile(filepath.Join(c.dir, id, CacheEntryFile))
+	if err != nil {
+		return nil, err
+	}
+
+	e = &CacheEntry{ID: id}
+	if err = json.Unmarshal(d, e); err != nil {
+		return nil, errors.Wrap(err, "failed to decode cache entry")
+	}
+
+	return e, nil
+}
+
+//writeEntry writes the metadata of the cache entry into directory 'dir'
+func (c *Cache) writeEntry(dir string, e *CacheEntry) (err error) {
+	d, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode cache entry")
+	}
+
+	return writeState(filepath.Join(dir, CacheEntryFile), d)
+}
+
+//lookup returns the data directory of a cached version of 'dataset', it is marked as used
+func (c *Cache) lookup(dataset, version string) (data string, ok bool) {
+	id := c.id(dataset, version)
+	e, err := c.readEntry(id)
+	if err != nil || e.Dataset != dataset || e.Version != version {
+		return "", false
+	}
+
+	e.LastUsed = time.Now()
+	c.writeEntry(filepath.Join(c.dir, id), e) //only affects the order of pruning
+	return filepath.Join(c.dir, id, CacheDataDir), true
+}
+
+//stage creates a directory in which a dataset can be pulled before it is added to the cache
+func (c *Cache) stage() (dir string, err error) {
+	if dir, err = ioutil.TempDir(c.dir, ".fill_"); err != nil {
+		return "", errors.Wrap(err, "failed to create cache staging directory")
+	}
+
+	return dir, nil
+}
+
+//add moves the dataset that was pulled into the data directory of staging directory 'dir'
+//into the cache and returns where its data is kept
+func (c *Cache) add(dataset, version, dir string) (data string, err error) {
+	e := &CacheEntry{ID: c.id(dataset, version), Dataset: dataset, Version: version, Created: time.Now()}
+	e.LastUsed = e.Created
+	if err = filepath.Walk(filepath.Join(dir, CacheDataDir), func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fi.Mode().IsRegular() {
+			e.Size += fi.Size()
+		}
+
+		return nil
+	}); err != nil {
+		return "", errors.Wrap(err, "failed to determine size of cached dataset")
+	}
+
+	if err = c.writeEntry(dir, e); err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(c.dir, e.ID)
+	if err = os.Rename(dir, target); err != nil {
+		if _, ok := c.lookup(dataset, version); ok {
+			os.RemoveAll(dir) //another pull added the same version first
+			return filepath.Join(target, CacheDataDir), nil
+		}
+
+		os.RemoveAll(target) //incomplete entry
+		if err = os.Rename(dir, target); err != nil {
+			return "", errors.Wrap(err, "failed to move dataset into the cache")
+		}
+	}
+
+	return filepath.Join(target, CacheDataDir), nil
+}
+
+//Entries returns the datasets in the cache, the most recently used first
+func (c *Cache) Entries() (entries []*CacheEntry, err error) {
+	fis, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read cache directory")
+	}
+
+	for _, fi := range fis {
+		if !fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+
+		e, err := c.readEntry(fi.Name())
+		if err != nil {
+			continue //not an entry of the cache
+		}
+
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+
+	return entries, nil
+}
+
+//Remove deletes the entry from the cache
+func (c *Cache) Remove(e *CacheEntry) error {
+	if err := os.RemoveAll(filepath.Join(c.dir, e.ID)); err != nil {
+		return errors.Wrapf(err, "failed to remove cached dataset '%s'", e.Dataset)
+	}
+
+	return nil
+}
+
+//Prune removes the least recently used entries until the cache holds no more than 'maxSize'
+//bytes, incomplete entries that were abandoned are removed as well
+func (c *Cache) Prune(maxSize int64) (removed []*CacheEntry, err error) {
+	fis, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read cache directory")
+	}
+
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), ".fill_") && time.Since(fi.ModTime()) > cacheStaleFill {
+			os.RemoveAll(filepath.Join(c.dir, fi.Name()))
+		}
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	for i := len(entries) - 1; i >= 0 && total > maxSize; i-- {
+		if err = c.Remove(entries[i]); err != nil {
+			return removed, err
+		}
+
+		total -= entries[i].Size
+		removed = append(removed, entries[i])
+	}
+
+	return removed, nil
+}
+
+//materialize links or copies the cached dataset in 'data' to 'toPath'
+func (c *Cache) materialize(ctx context.Context, data, toPath string) error {
+	return filepath.Walk(data, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(data, p)
+		if err != nil {
+			return errors.Wrap(err, "failed to determine relative path")
+		}
+
+		dst := filepath.Join(toPath, rel)
+		switch {
+		case fi.IsDir():
+			if err = os.MkdirAll(dst, fi.Mode().Perm()); err != nil {
+				return errors.Wrap(err, "failed to create directory")
+			}
+
+			return nil
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return errors.Wrap(err, "failed to read symlink")
+			}
+
+			os.Remove(dst)
+			if err = os.Symlink(target, dst); err != nil {
+				return errors.Wrap(err, "failed to create symlink")
+			}
+
+			return nil
+		case fi.Mode().IsRegular():
+			os.Remove(dst)
+			if c.link && os.Link(p, dst) == nil {
+				return nil
+			}
+
+			return copyCachedFile(p, dst, fi)
+		default:
+			return nil
+		}
+	})
+}
+
+//copyCachedFile copies the file at 'src' to 'dst' while keeping its mode and modification time
+func copyCachedFile(src, dst string, fi os.FileInfo) (err error) {
+	sf, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "failed to open cached file")
+	}
+
+	defer sf.Close()
+	df, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return errors.Wrap(err, "failed to create file")
+	}
+
+	defer df.Close()
+	if _, err = io.Copy(df, sf); err != nil {
+		return errors.Wrap(err, "failed to copy cached file")
+	}
+
+	if err = df.Close(); err != nil {
+		return errors.Wrap(err, "failed to close file")
+	}
+
+	return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+}
+
+//version returns a fingerprint of the objects that make up the dataset, it changes when
+//any of them is written again, and their total size. It returns false when no fingerprint
+//can be determined
+func (h *StdHandle) version(ctx context.Context) (version string, total int64, ok bool, err error) {
+	et, ok := h.store.(ETagger)
+	if !ok {
+		return "", 0, false, nil
+	}
+
+	hash := sha256.New()
+	n := 0
+	if err = h.archiver.Index(func(k string) error {
+		size, etag, err := et.ETag(ctx, k)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get entity tag of '%s'", k)
+		}
+
+		n++
+		total += size
+		fmt.Fprintf(hash, "%s\x00%d\x00%s\n", k, size, etag)
+		return nil
+	}); err != nil {
+		return "", 0, false, err
+	}
+
+	if n == 0 {
+		return "", 0, false, nil //eg. referenced objects are not part of the index
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), total, true, nil
+}
+
+//pullCached pulls the dataset through the cache, it is only downloaded when no
+//version with the same objects is cached already. Datasets that are larger than the
+//cache would evict everything else, these are pulled without it
+func (h *StdHandle) pullCached(ctx context.Context, toPath string, rep Reporter) (err error) {
+	version, total, ok, err := h.version(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to determine dataset version")
+	}
+
+	if !ok || (h.cache.maxSize > 0 && total > h.cache.maxSize) {
+		if err = h.archiver.Unarchive(ctx, toPath, rep, h.get(ctx, rep)); err != nil {
+			return errors.Wrap(err, "failed to unarchive")
+		}
+
+		return h.postPull(ctx)
+	}
+
+	data, ok := h.cache.lookup(h.name, version)
+	if !ok {
+		dir, err := h.cache.stage()
+		if err != nil {
+			return err
+		}
+
+		if err = h.archiver.Unarchive(ctx, filepath.Join(dir, CacheDataDir), rep, h.get(ctx, rep)); err != nil {
+			os.RemoveAll(dir)
+			return errors.Wrap(err, "failed to unarchive")
+		}
+
+		if data, err = h.cache.add(h.name, version, dir); err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+	}
+
+	if err = h.cache.materialize(ctx, data, toPath); err != nil {
+		return errors.Wrap(err, "failed to copy dataset from the cache")
+	}
+
+	if h.cache.maxSize > 0 {
+		h.cache.Prune(h.cache.maxSize) //the pull itself succeeded, a later pull prunes again
+	}
+
+	return h.postPull(ctx)
+}
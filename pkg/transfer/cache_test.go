@@ -0,0 +1,114 @@
+package transfer_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+)
+
+//countingStore counts the objects that are downloaded
+type countingStore struct {
+	*transferstore.FSStore
+	gets int
+}
+
+func (s *countingStore) Get(ctx context.Context, k string, w io.WriterAt) error {
+	s.gets++
+	return s.FSStore.Get(ctx, k, w)
+}
+
+func TestCachedPull(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, src, clean := testSource(t, "hello.txt")
+	defer clean()
+
+	_, fs := testFSStore(t, dir)
+	a, err := transferarchiver.NewTarArchiver(transferarchiver.ArchiverOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &countingStore{FSStore: fs}
+	h, err := transfer.CreateStdHandle("my-dataset", store, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := transfer.NewCache(filepath.Join(dir, "cache"), 1024*1024, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.UseCache(cache)
+	change := func(content string) {
+		if err = ioutil.WriteFile(filepath.Join(src, "hello.txt"), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		testPush(t, h, src)
+	}
+
+	pull := func(name, expected string) {
+		dst := filepath.Join(dir, name)
+		if err = h.Pull(ctx, dst, rep); err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := ioutil.ReadFile(filepath.Join(dst, "hello.txt"))
+		if err != nil || string(d) != expected {
+			t.Fatalf("expected pulled content '%s', got: %q, %v", expected, d, err)
+		}
+	}
+
+	testPush(t, h, src)
+	pull("dst1", "hello, world")
+	gets := store.gets
+	if gets == 0 {
+		t.Fatal("expected first pull to download objects")
+	}
+
+	pull("dst2", "hello, world")
+	if store.gets != gets {
+		t.Fatalf("expected second pull to come from the cache, got %d downloads instead of %d", store.gets, gets)
+	}
+
+	change("hello, changed world")
+	pull("dst3", "hello, changed world")
+	if store.gets == gets {
+		t.Fatal("expected a changed dataset to be downloaded again")
+	}
+
+	entries, err := cache.Entries()
+	if err != nil || len(entries) != 2 || entries[0].Dataset != "my-dataset" {
+		t.Fatalf("expected two versions to be cached, got: %v, %v", entries, err)
+	}
+
+	removed, err := cache.Prune(entries[0].Size)
+	if err != nil || len(removed) != 1 || removed[0].ID != entries[1].ID {
+		t.Fatalf("expected least recently used version to be pruned, got: %v, %v", removed, err)
+	}
+
+	small, err := transfer.NewCache(filepath.Join(dir, "small-cache"), 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.UseCache(small)
+	gets = store.gets
+	pull("dst4", "hello, changed world")
+	if store.gets == gets {
+		t.Fatal("expected dataset to be downloaded")
+	}
+
+	if entries, err = small.Entries(); err != nil || len(entries) != 0 {
+		t.Fatalf("expected a dataset larger than the cache not to be cached, got: %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,39 @@
+//Package transferconfig holds the versioned JSON documents that stores and archivers keep
+//their options in
+package transferconfig
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+//Config holds options as a JSON document, the version allows a store or archiver to recognize
+//and upgrade options that were written by an older version of itself
+type Config struct {
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+//New encodes 'v' as the JSON document of a config with 'version'
+func New(version int, v interface{}) (c *Config, err error) {
+	c = &Config{Version: version}
+	if c.Data, err = json.Marshal(v); err != nil {
+		return nil, errors.Wrapf(err, "failed to encode config version %d", version)
+	}
+
+	return c, nil
+}
+
+//Decode the JSON document into 'v'
+func (c *Config) Decode(v interface{}) error {
+	if c == nil || len(c.Data) < 1 {
+		return errors.New("no config")
+	}
+
+	if err := json.Unmarshal(c.Data, v); err != nil {
+		return errors.Wrapf(err, "failed to decode config version %d", c.Version)
+	}
+
+	return nil
+}
@@ -0,0 +1,283 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/pkg/errors"
+)
+
+//DiskCache keeps objects that were downloaded from a store in a local directory such that
+//processes on the same machine, eg. flex volumes that mount the same input, download each
+//object once. Objects are identified by their key, size and entity tag, objects of stores
+//that cannot tell their entity tag are never cached. When the cache grows beyond its
+//capacity the least recently used objects are removed. Processes that want the same object wait on a file lock for the one
+//that downloads it
+type DiskCache struct {
+	dir      string
+	capacity int64
+}
+
+//NewDiskCache creates a cache in 'dir' that holds at most 'capacity' bytes
+func NewDiskCache(dir string, capacity int64) (c *DiskCache, err error) {
+	c = &DiskCache{dir: dir, capacity: capacity}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create cache directory")
+	}
+
+	return c, nil
+}
+
+//Wrap returns a store that downloads objects of 'store' through the cache, stores that cannot
+//tell the version of their objects are returned as is
+func (c *DiskCache) Wrap(store Store) Store {
+	et, ok := store.(ETagger)
+	if !ok {
+		return store
+	}
+
+	s := &diskCacheStore{Store: store, et: et, cache: c}
+	if l, ok := store.(Lister); ok {
+		return &diskCacheListStore{diskCacheStore: s, l: l}
+	}
+
+	return s
+}
+
+//lock claims the object with 'id', when 'wait' is false it returns errLocked instead of
+//waiting for another process to release it
+func (c *DiskCache) lock(id string, wait bool) (unlock func(), err error) {
+	f, err := os.OpenFile(filepath.Join(c.dir, id+".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open lock file")
+	}
+
+	if err = lockFile(f, wait); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() { f.Close() }, nil
+}
+
+//open returns the cached object with 'id', when it is not cached yet 'fill' is called to download
+//it. Objects are moved into the cache once they are complete, when locks are lost because the
+//object was evicted at the same time it is merely downloaded twice
+func (c *DiskCache) open(id string, size int64, fill func(f *os.File) error) (f *os.File, err error) {
+	unlock, err := c.lock(id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+	p := filepath.Join(c.dir, id)
+	if f, err = os.Open(p); err == nil {
+		if fi, err := f.Stat(); err == nil && fi.Size() == size {
+			now := time.Now()
+			os.Chtimes(p, now, now) //marks it as recently used
+			return f, nil
+		}
+
+		f.Close()
+	}
+
+	if f, err = ioutil.TempFile(c.dir, ".fill_"); err != nil {
+		return nil, errors.Wrap(err, "failed to create cache file")
+	}
+
+	clean := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	if err = fill(f); err != nil {
+		clean()
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		clean()
+		return nil, errors.Wrap(err, "failed to stat cache file")
+	}
+
+	if fi.Size() != size {
+		clean()
+		return nil, errors.Errorf("downloaded %d bytes instead of %d", fi.Size(), size)
+	}
+
+	if err = os.Rename(f.Name(), p); err != nil {
+		clean()
+		return nil, errors.Wrap(err, "failed to move object into the cache")
+	}
+
+	if err = c.evict(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+//evict removes the least recently used objects until the cache fits its capacity, objects
+//that are in use by other processes are skipped
+func (c *DiskCache) evict() (err error) {
+	unlock, err := c.lock(".evict", false)
+	if err == errLocked {
+		return nil //another process is evicting
+	} else if err != nil {
+		return err
+	}
+
+	defer unlock()
+	fis, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read cache directory")
+	}
+
+	var total int64
+	objects := []os.FileInfo{}
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), ".fill_") && time.Since(fi.ModTime()) > cacheStaleFill {
+			os.Remove(filepath.Join(c.dir, fi.Name()))
+			continue
+		}
+
+		if strings.HasPrefix(fi.Name(), ".") || strings.HasSuffix(fi.Name(), ".lock") || !fi.Mode().IsRegular() {
+			continue
+		}
+
+		objects = append(objects, fi)
+		total += fi.Size()
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].ModTime().Before(objects[j].ModTime())
+	})
+
+	for _, fi := range objects {
+		if total <= c.capacity {
+			break
+		}
+
+		unlock, err := c.lock(fi.Name(), false)
+		if err != nil {
+			continue //in use
+		}
+
+		os.Remove(filepath.Join(c.dir, fi.Name()))
+		os.Remove(filepath.Join(c.dir, fi.Name()+".lock"))
+		unlock()
+		total -= fi.Size()
+	}
+
+	return nil
+}
+
+//diskCacheStore reads objects through the disk cache, other operations go to the store directly
+type diskCacheStore struct {
+	Store
+	et    ETagger
+	cache *DiskCache
+}
+
+//id identifies the current version of the object with key 'k', it is empty when the store
+//has no entity tag for the object
+func (s *diskCacheStore) id(ctx context.Context, k string) (id string, size int64, err error) {
+	size, etag, err := s.et.ETag(ctx, k)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "failed to get entity tag of '%s'", k)
+	}
+
+	if etag == "" {
+		return "", size, nil //a new version with the same size would be read from the cache
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s", k, size, etag)))
+	return hex.EncodeToString(sum[:]), size, nil
+}
+
+//Get writes the object with key 'k' to 'w' from the cache, it is downloaded first when it isn't cached
+func (s *diskCacheStore) Get(ctx context.Context, k string, w io.WriterAt) (err error) {
+	id, size, err := s.id(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	if id == "" || size > s.cache.capacity {
+		return s.Store.Get(ctx, k, w) //cannot be told apart from other versions or would evict everything else
+	}
+
+	f, err := s.cache.open(id, size, func(f *os.File) error {
+		return s.Store.Get(ctx, k, f)
+	})
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	var off int64
+	if rw, ok := w.(transferstore.ResumableWriterAt); ok {
+		off = rw.Written()
+	}
+
+	if _, err = io.Copy(&sectionWriter{w: w, off: off}, io.NewSectionReader(f, off, size-off)); err != nil {
+		return errors.Wrap(err, "failed to copy cached object")
+	}
+
+	return nil
+}
+
+//GetRange reads from the cache when the object is cached, ranges never fill the cache
+func (s *diskCacheStore) GetRange(ctx context.Context, k string, off, n int64, w io.Writer) (err error) {
+	id, size, err := s.id(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		return s.Store.GetRange(ctx, k, off, n, w)
+	}
+
+	f, err := os.Open(filepath.Join(s.cache.dir, id))
+	if err != nil {
+		return s.Store.GetRange(ctx, k, off, n, w)
+	}
+
+	defer f.Close()
+	if fi, err := f.Stat(); err != nil || fi.Size() != size {
+		return s.Store.GetRange(ctx, k, off, n, w)
+	}
+
+	if _, err = io.Copy(w, io.NewSectionReader(f, off, n)); err != nil {
+		return errors.Wrap(err, "failed to copy cached object range")
+	}
+
+	return nil
+}
+
+//ETag forwards to the store
+func (s *diskCacheStore) ETag(ctx context.Context, k string) (size int64, etag string, err error) {
+	return s.et.ETag(ctx, k)
+}
+
+//diskCacheListStore is a disk cache store for stores that can enumerate their objects
+type diskCacheListStore struct {
+	*diskCacheStore
+	l Lister
+}
+
+//List forwards to the store, eg. for datasets that reference existing objects
+func (s *diskCacheListStore) List(ctx context.Context, prefix string, fn func(k string, size int64, modTime time.Time) error) error {
+	return s.l.List(ctx, prefix, fn)
+}
@@ -0,0 +1,198 @@
+package transfer_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+)
+
+//keyCountingStore counts the downloads of each object from a file system store
+type keyCountingStore struct {
+	*transferstore.FSStore
+	mu   sync.Mutex
+	gets map[string]int
+}
+
+func (s *keyCountingStore) Get(ctx context.Context, k string, w io.WriterAt) error {
+	s.mu.Lock()
+	s.gets[k]++
+	s.mu.Unlock()
+	return s.FSStore.Get(ctx, k, w)
+}
+
+//untaggedStore is a file system store that has no entity tags for its objects
+type untaggedStore struct {
+	*keyCountingStore
+}
+
+func (s *untaggedStore) ETag(ctx context.Context, k string) (size int64, etag string, err error) {
+	size, err = s.Head(ctx, k)
+	return size, "", err
+}
+
+func TestDiskCache(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, err := ioutil.TempDir("", "disk_cache_tests_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	fs, err := transferstore.NewFSStore(transferstore.StoreOptions{FSStoreDir: filepath.Join(dir, "objects")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(dir, "src")
+	if err = os.MkdirAll(src, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err = ioutil.WriteFile(filepath.Join(src, fmt.Sprintf("file-%d.txt", i)), []byte(strings.Repeat("x", 3000)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ato := transferarchiver.ArchiverOptions{TarArchiverPartSize: 4096}
+	a, err := transferarchiver.NewTarArchiver(ato)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := transfer.CreateStdHandle("my-dataset", fs, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Push(ctx, src, rep); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &keyCountingStore{FSStore: fs, gets: map[string]int{}}
+	var wrap transfer.Store = store
+	pull := func(cacheDir string, capacity int64, i int) error {
+		c, err := transfer.NewDiskCache(cacheDir, capacity)
+		if err != nil {
+			return err
+		}
+
+		a, err := transferarchiver.NewTarArchiver(ato)
+		if err != nil {
+			return err
+		}
+
+		h, err := transfer.CreateStdHandle("my-dataset", c.Wrap(wrap), a, nil)
+		if err != nil {
+			return err
+		}
+
+		dst := filepath.Join(dir, fmt.Sprintf("dst-%d", i))
+		defer os.RemoveAll(dst)
+		if err = h.Pull(ctx, dst, rep); err != nil {
+			return err
+		}
+
+		d, err := ioutil.ReadFile(filepath.Join(dst, "file-3.txt"))
+		if err != nil || len(d) != 3000 {
+			return fmt.Errorf("unexpected content: %d bytes, %v", len(d), err)
+		}
+
+		return nil
+	}
+
+	//concurrent pulls each have their own cache, as if they were separate processes
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- pull(filepath.Join(dir, "cache"), 1024*1024, i)
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(store.gets) < 3 {
+		t.Fatalf("expected the dataset to consist of several parts, got: %v", store.gets)
+	}
+
+	for k, n := range store.gets {
+		if n != 1 {
+			t.Fatalf("expected '%s' to be downloaded once, got: %d", k, n)
+		}
+	}
+
+	//a small cache only keeps the most recently used objects
+	store.gets = map[string]int{}
+	small := filepath.Join(dir, "small-cache")
+	if err = pull(small, 8*1024, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var total int64
+	if err = filepath.Walk(small, func(p string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() && !strings.HasSuffix(p, ".lock") {
+			total += fi.Size()
+		}
+
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if total == 0 || total > 8*1024 {
+		t.Fatalf("expected cache to fit its capacity, got: %d bytes", total)
+	}
+
+	//objects without entity tags cannot be told apart from other versions and are not cached
+	store.gets = map[string]int{}
+	wrap = &untaggedStore{keyCountingStore: store}
+	for i := 0; i < 2; i++ {
+		if err = pull(filepath.Join(dir, "untagged-cache"), 1024*1024, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(store.gets) == 0 {
+		t.Fatal("expected objects to be downloaded")
+	}
+
+	for k, n := range store.gets {
+		if n != 2 {
+			t.Fatalf("expected '%s' to be downloaded by each pull, got: %d", k, n)
+		}
+	}
+
+	c, err := transfer.NewDiskCache(filepath.Join(dir, "cache"), 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Wrap(struct{ transfer.Store }{fs}).(transfer.Lister); ok {
+		t.Fatal("expected cached store to only list objects when the store can")
+	}
+
+	if _, ok := c.Wrap(fs).(transfer.Lister); !ok {
+		t.Fatal("expected cached store to list objects of the store")
+	}
+}
@@ -1,2 +1,7 @@
 //Package transfer provides primitives for uploading and downloading datasets
+//
+//Stores and archivers are created by type through a registry, other backends are made
+//available by calling RegisterStore or RegisterArchiver from the init function of their
+//package and importing that package in the CLI, flex volume and controller binaries. Their
+//options are kept in the Config field of the dataset as versioned JSON
 package transfer
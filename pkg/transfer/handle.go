@@ -2,18 +2,90 @@ package transfer
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
+	slashpath "path"
 
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
 	"github.com/pkg/errors"
 )
 
+var (
+	//ErrFileNotExists is returned when a file is not part of a dataset
+	ErrFileNotExists = errors.New("file does not exist in dataset")
+
+	//ErrNotAFile is returned when a path in the dataset is not a regular file
+	ErrNotAFile = errors.New("path is not a regular file")
+
+	//ErrDigestMismatch is returned when pulled content does not match what was pushed
+	ErrDigestMismatch = transferarchiver.ErrDigestMismatch
+
+	//ErrDigestMissing is returned when no digest was recorded for an object of a dataset that records them
+	ErrDigestMissing = errors.New("no digest was recorded for object")
+
+	//ErrObjectArchived is returned when content is in an archive storage class and must be restored first
+	ErrObjectArchived = transferstore.ErrObjectArchived
+
+	//ErrPartialPullNotSupported is returned when only some paths are pulled from an archive that cannot do so
+	ErrPartialPullNotSupported = errors.New("dataset archive can only be downloaded as a whole")
+)
+
 //HandleDelegate allows customization of lifecycle events, these
 //events can be handled inside the lock of the handle
 type HandleDelegate interface {
-	PostClean(ctx context.Context) error             //eg, set size to
-	PostPush(ctx context.Context, size uint64) error //eg, set new size
+	PostClean(ctx context.Context) error                                                        //eg, mark as cleared
+	PrePush(ctx context.Context) error                                                          //eg, mark as uploading
+	PostPush(ctx context.Context, size uint64, digests map[string]string) error                 //eg, set new size and object digests
+	PostPushError(ctx context.Context, err error) error                                         //eg, mark as failed
+	PreAppend(ctx context.Context) error                                                        //eg, mark as appending
+	PostAppend(ctx context.Context, layer string, size uint64, digests map[string]string) error //eg, record the layer and grow the size
+	PostAppendError(ctx context.Context, err error) error                                       //eg, no longer mark as appending
 	PostPull(ctx context.Context) error
-	PostClose() error //eg release the lock
+	PostTransition(ctx context.Context, class string) error //eg, record the storage class
+	PostClose() error                                       //eg release the lock
+}
+
+//layerIndexer is implemented by archivers that store appended content as separate layers
+type layerIndexer interface {
+	LayerIndex(ctx context.Context, get func(k string, w io.WriterAt) error, fn func(k string) error) error
+}
+
+//partIndexer is implemented by archivers that split content into parts, these are listed
+//in objects that need to be retrieved with 'get'. It is mandatory for archivers whose Index
+//doesn't return all of their objects
+type partIndexer interface {
+	PartIndex(ctx context.Context, get func(k string, w io.WriterAt) error, fn func(k string) error) error
+}
+
+//immutableKeyer is implemented by archivers that name objects after their content, such
+//objects are not stored again when they exist already
+type immutableKeyer interface {
+	Immutable(k string) bool
+}
+
+//metadataKeyer is implemented by archivers that store objects which only describe the content
+type metadataKeyer interface {
+	Metadata(k string) bool
+}
+
+//pathUnarchiver is implemented by archivers that can extract a subset of the dataset
+type pathUnarchiver interface {
+	UnarchivePaths(ctx context.Context, path string, paths []string, rep transferarchiver.Reporter, fn func(k string, w io.WriterAt) error) error
+}
+
+//pooledKeyer is implemented by archivers that store objects in a pool that is shared with
+//other datasets, such objects are never removed by the handle but garbage collected
+//once no dataset references them anymore
+type pooledKeyer interface {
+	Pooled(k string) bool
+}
+
+//parallelGetter is implemented by archivers that get multiple objects at the same time, they
+//report the progress of all downloads together
+type parallelGetter interface {
+	ParallelGets() bool
 }
 
 //StdHandle provides a standard implementation for handling datasets
@@ -22,6 +94,8 @@ type StdHandle struct {
 	delegate HandleDelegate
 	store    Store
 	archiver Archiver
+	cache    *Cache
+	digests  map[string]string
 }
 
 //CreateStdHandle sets up a standard implementation of the handle
@@ -36,10 +110,21 @@ func CreateStdHandle(name string, store Store, a Archiver, del HandleDelegate) (
 //Name returns the name
 func (h *StdHandle) Name() string { return h.name }
 
+//ExpectDigests sets the digests that were recorded when objects were pushed, objects that
+//are pulled are checked against them
+func (h *StdHandle) ExpectDigests(digests map[string]string) { h.digests = digests }
+
+//UseCache makes pulls go through cache 'c', datasets whose objects did not change since
+//they were cached are then not downloaded again
+func (h *StdHandle) UseCache(c *Cache) { h.cache = c }
+
 //Clear removes all objects related to a dataset
 func (h *StdHandle) Clear(ctx context.Context, reporter Reporter) (err error) {
+	del := func(k string) error {
+		if h.pooled(k) {
+			return nil //may be used by other datasets
+		}
 
-	if err = h.archiver.Index(func(k string) error {
 		if err = h.store.Del(ctx, k); err != nil {
 			return errors.Wrap(err, "failed to delete object key")
 		}
@@ -48,10 +133,23 @@ func (h *StdHandle) Clear(ctx context.Context, reporter Reporter) (err error) {
 		//@TODO inform reporter
 
 		return nil
-	}); err != nil {
+	}
+
+	//parts are listed by objects that are part of the index, they go first
+	if pi, ok := h.archiver.(partIndexer); ok {
+		if err = pi.PartIndex(ctx, h.getQuiet(ctx), del); err != nil {
+			return errors.Wrap(err, "failed to walk part index")
+		}
+	}
+
+	if err = h.archiver.Index(del); err != nil {
 		return errors.Wrap(err, "failed to walk index")
 	}
 
+	if err = h.clearShares(ctx, reporter); err != nil {
+		return errors.Wrap(err, "failed to clear shares")
+	}
+
 	if h.delegate != nil {
 		if err = h.delegate.PostClean(ctx); err != nil {
 			return errors.Wrap(err, "failed to run post clean delegate")
@@ -76,27 +174,228 @@ func (pr *progressReader) Read(p []byte) (n int, err error) {
 
 //Push pushes new content from a local filesystem
 func (h *StdHandle) Push(ctx context.Context, fromPath string, rep Reporter) (err error) {
+	if err = h.prePush(ctx); err != nil {
+		return err
+	}
+
+	stale := h.parts(ctx)
+	wc := newWriteCounter()
+	if err = h.archiver.Archive(ctx, fromPath, rep, h.put(ctx, wc, rep)); err != nil {
+		return h.postPushError(errors.Wrapf(err, "failed to archive"))
+	}
+
+	return h.postPush(ctx, wc, rep, stale)
+}
+
+//PushTar pushes new content by reading a tar stream from 'r'
+func (h *StdHandle) PushTar(ctx context.Context, r io.Reader, rep Reporter) (err error) {
+	if err = h.prePush(ctx); err != nil {
+		return err
+	}
+
+	stale := h.parts(ctx)
+	wc := newWriteCounter()
+	if err = h.archiver.ArchiveTar(ctx, r, rep, h.put(ctx, wc, rep)); err != nil {
+		return h.postPushError(errors.Wrapf(err, "failed to archive tar stream"))
+	}
+
+	return h.postPush(ctx, wc, rep, stale)
+}
+
+//Append pushes content from a local filesystem as a new layer on top of the existing
+//content. The dataset remains usable while the layer is uploaded, it becomes part of
+//the dataset only after the delegate recorded it
+func (h *StdHandle) Append(ctx context.Context, fromPath string, rep Reporter) (err error) {
+	wc := newWriteCounter()
+	keys := []string{}
+	put := h.put(ctx, wc, rep)
+
+	//the dataset is marked while appending, pooled parts the layer stores are not recorded
+	//yet and must not be collected in the mean time
+	if h.delegate != nil {
+		if err = h.delegate.PreAppend(ctx); err != nil {
+			return errors.Wrap(err, "failed to run pre append delegate")
+		}
+	}
 
-	wc := &writeCounter{}
-	if err = h.archiver.Archive(ctx, fromPath, rep, func(k string, r io.ReadSeeker, nbytes int64) error {
+	layer, err := h.archiver.Append(ctx, fromPath, rep, h.getQuiet(ctx), func(k string, r io.ReadSeeker, nbytes int64) error {
+		keys = append(keys, k)
+		return put(k, r, nbytes)
+	})
+	if err != nil {
+		return h.postAppendError(h.removeKeys(keys, errors.Wrap(err, "failed to archive layer")))
+	}
+
+	if h.delegate != nil {
+		if err = h.delegate.PostAppend(ctx, layer, wc.total, wc.digests); err != nil {
+			return h.postAppendError(h.removeKeys(keys, errors.Wrap(err, "failed to run post append delegate")))
+		}
+	}
+
+	if h.digests == nil {
+		h.digests = map[string]string{}
+	}
+
+	for k, sum := range wc.digests {
+		h.digests[k] = sum
+	}
+
+	return nil
+}
+
+//removeKeys deletes objects of a layer that was never recorded and returns the original error
+func (h *StdHandle) removeKeys(keys []string, perr error) error {
+	for _, k := range keys {
+		if h.pooled(k) {
+			continue //may be used by other datasets
+		}
+
+		//the append may have failed because the context was cancelled, the objects
+		//should be removed regardless
+		if err := h.store.Del(context.Background(), k); err != nil {
+			return errors.Wrapf(perr, "failed to remove object '%s' of unrecorded layer (%v)", k, err)
+		}
+	}
+
+	return perr
+}
+
+//put returns an archiver callback that puts objects into the store
+func (h *StdHandle) put(ctx context.Context, wc *writeCounter, rep Reporter) func(k string, r io.ReadSeeker, nbytes int64) error {
+	ik, _ := h.archiver.(immutableKeyer)
+	return func(k string, r io.ReadSeeker, nbytes int64) error {
+		immutable := ik != nil && ik.Immutable(k)
+
+		//objects that are named after their content carry their digest in the key, recording
+		//them would grow the dataset with every file. Pooled parts are recorded as references
+		if !immutable || h.pooled(k) {
+			sum, err := digest(r)
+			if err != nil {
+				return err
+			}
+
+			wc.digests[k] = sum
+		}
+
+		//objects that are named after their content and exist with the same size were
+		//stored by an earlier, interrupted, push
+		if immutable {
+			if size, err := h.store.Head(ctx, k); err == nil && size == nbytes {
+				wc.total += uint64(nbytes)
+				rep.HandledKey(k)
+				return nil
+			}
+		}
 
 		//push bytes while counting the total number being pushed across all objects
 		defer rep.StopUploadProgress()
-		if err = h.store.Put(ctx, k, newProgressReader(wc, r, rep.StartUploadProgress(k, nbytes, r))); err != nil {
+		if err := h.store.Put(ctx, k, newProgressReader(wc, r, rep.StartUploadProgress(k, nbytes, r))); err != nil {
 			return errors.Wrap(err, "failed to put object")
 		}
 
+		rep.HandledKey(k)
 		return nil
-	}); err != nil {
-		return errors.Wrapf(err, "failed to archive")
+	}
+}
+
+func (h *StdHandle) prePush(ctx context.Context) (err error) {
+	if h.delegate != nil {
+		if err = h.delegate.PrePush(ctx); err != nil {
+			return errors.Wrap(err, "failed to run pre push delegate")
+		}
+	}
+
+	return nil
+}
+
+//postPushError informs the delegate of a failed push and returns the original error
+func (h *StdHandle) postPushError(perr error) (err error) {
+	if h.delegate != nil {
+		//the push may have failed because the context was cancelled, the failure
+		//should be recorded regardless
+		if err = h.delegate.PostPushError(context.Background(), perr); err != nil {
+			return errors.Wrapf(perr, "failed to run post push error delegate (%v)", err)
+		}
+	}
+
+	return perr
+}
+
+//postAppendError informs the delegate of a failed append and returns the original error
+func (h *StdHandle) postAppendError(perr error) (err error) {
+	if h.delegate != nil {
+		if err = h.delegate.PostAppendError(context.Background(), perr); err != nil {
+			return errors.Wrapf(perr, "failed to run post append error delegate (%v)", err)
+		}
+	}
+
+	return perr
+}
+
+//pooled returns whether the object at 'k' is shared with other datasets
+func (h *StdHandle) pooled(k string) bool {
+	pk, ok := h.archiver.(pooledKeyer)
+	return ok && pk.Pooled(k)
+}
+
+//parts returns the keys of the parts the content currently consists of, the
+//content may not have been pushed yet in which case there are none
+func (h *StdHandle) parts(ctx context.Context) map[string]bool {
+	keys := map[string]bool{}
+	if pi, ok := h.archiver.(partIndexer); ok {
+		_ = pi.PartIndex(ctx, h.getQuiet(ctx), func(k string) error {
+			keys[k] = true
+			return nil
+		})
 	}
 
+	return keys
+}
+
+//postPush informs the delegate of the new size. Layers that were appended before, and parts
+//in 'stale' that are no longer used, are replaced by the new content so their objects are
+//removed once the delegate succeeded
+func (h *StdHandle) postPush(ctx context.Context, wc *writeCounter, rep Reporter, stale map[string]bool) (err error) {
 	if h.delegate != nil {
-		if err = h.delegate.PostPush(ctx, wc.total); err != nil {
+		if err = h.delegate.PostPush(ctx, wc.total, wc.digests); err != nil {
 			return errors.Wrap(err, "failed to run post push delegate")
 		}
 	}
 
+	h.digests = wc.digests
+
+	del := func(k string) error {
+		if h.pooled(k) {
+			return nil //may be used by other datasets
+		}
+
+		if err = h.store.Del(ctx, k); err != nil {
+			return errors.Wrap(err, "failed to delete object key")
+		}
+
+		rep.HandledKey(k)
+		return nil
+	}
+
+	for k := range h.parts(ctx) {
+		delete(stale, k)
+	}
+
+	if li, ok := h.archiver.(layerIndexer); ok {
+		if err = li.LayerIndex(ctx, h.getQuiet(ctx), func(k string) error {
+			delete(stale, k)
+			return del(k)
+		}); err != nil {
+			return errors.Wrap(err, "failed to remove replaced layers")
+		}
+	}
+
+	for k := range stale {
+		if err = del(k); err != nil {
+			return errors.Wrap(err, "failed to remove replaced parts")
+		}
+	}
+
 	return nil
 }
 
@@ -116,10 +415,53 @@ func (pw *progressWriter) WriteAt(p []byte, off int64) (n int, err error) {
 
 //Pull content from the store to the local filesystem
 func (h *StdHandle) Pull(ctx context.Context, toPath string, rep Reporter) (err error) {
-	if err = h.archiver.Unarchive(ctx, toPath, rep, func(k string, w io.WriterAt) error {
+	if h.cache != nil {
+		return h.pullCached(ctx, toPath, rep)
+	}
+
+	if err = h.archiver.Unarchive(ctx, toPath, rep, h.get(ctx, rep)); err != nil {
+		return errors.Wrap(err, "failed to unarchive")
+	}
 
-		var total int64
-		total, err = h.store.Head(ctx, k)
+	return h.postPull(ctx)
+}
+
+//PullPaths pulls only the files at or below the (slash separated) 'paths' to the local filesystem
+func (h *StdHandle) PullPaths(ctx context.Context, toPath string, paths []string, rep Reporter) (err error) {
+	pu, ok := h.archiver.(pathUnarchiver)
+	if !ok {
+		return ErrPartialPullNotSupported
+	}
+
+	if err = pu.UnarchivePaths(ctx, toPath, paths, rep, h.get(ctx, rep)); err != nil {
+		return errors.Wrap(err, "failed to unarchive")
+	}
+
+	return h.postPull(ctx)
+}
+
+//PullTar writes the content from the store as a tar stream to 'w'
+func (h *StdHandle) PullTar(ctx context.Context, w io.Writer, rep Reporter) (err error) {
+	if err = h.archiver.UnarchiveTar(ctx, w, rep, h.get(ctx, rep)); err != nil {
+		return errors.Wrap(err, "failed to unarchive to tar stream")
+	}
+
+	return h.postPull(ctx)
+}
+
+//get returns an archiver callback that gets objects from the store
+func (h *StdHandle) get(ctx context.Context, rep Reporter) func(k string, w io.WriterAt) error {
+	if pg, ok := h.archiver.(parallelGetter); ok && pg.ParallelGets() {
+		rep = NewDiscardReporter() //reporters track a single download at a time
+	}
+
+	return func(k string, w io.WriterAt) error {
+		dw, err := h.verifying(k, w)
+		if err != nil {
+			return err
+		}
+
+		total, err := h.store.Head(ctx, k)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object metadata")
 		}
@@ -127,17 +469,62 @@ func (h *StdHandle) Pull(ctx context.Context, toPath string, rep Reporter) (err
 		pw := rep.StartDownloadProgress(k, total)
 		defer rep.StopDownloadProgress()
 
-		if err = h.store.Get(ctx, k, newProgressWriter(w, pw)); err != nil {
+		//objects that never change can be downloaded in multiple attempts
+		if ik, ok := h.archiver.(immutableKeyer); ok && ik.Immutable(k) {
+			err = h.getResumable(ctx, k, total, dw, pw)
+		} else {
+			err = h.store.Get(ctx, k, newProgressWriter(dw, pw))
+		}
+
+		if err != nil {
 			return errors.Wrap(err, "failed to get object")
 		}
 
-		//@TODO update progress, per byte also while unarchiving
+		if err = dw.check(); err != nil {
+			return err
+		}
 
+		rep.HandledKey(k)
 		return nil
-	}); err != nil {
-		return errors.Wrap(err, "failed to unarchive")
 	}
+}
+
+//expectedDigest returns the digest that the object with key 'k' must have. Objects that are
+//named after their content have it in their key, others must have had it recorded when they were
+//pushed. Datasets that were pushed before digests were recorded have none, nothing is expected then
+func (h *StdHandle) expectedDigest(k string) (expected string, ok bool, err error) {
+	if ik, isik := h.archiver.(immutableKeyer); isik && ik.Immutable(k) {
+		return slashpath.Base(k), true, nil
+	}
+
+	if expected, ok = h.digests[k]; ok {
+		return expected, true, nil
+	}
+
+	if h.digests != nil {
+		return "", false, errors.Wrapf(ErrDigestMissing, "object '%s'", k)
+	}
+
+	return "", false, nil
+}
+
+//verifying wraps 'w' such that the object with key 'k' is hashed while it is written, it
+//is checked against the digest that is expected for it once the object was written completely
+func (h *StdHandle) verifying(k string, w io.WriterAt) (dw *digestWriter, err error) {
+	expected, ok, err := h.expectedDigest(k)
+	if err != nil {
+		return nil, err
+	}
+
+	dw = newDigestWriter(k, w)
+	if ok {
+		dw.expected = expected
+	}
+
+	return dw, nil
+}
 
+func (h *StdHandle) postPull(ctx context.Context) (err error) {
 	if h.delegate != nil {
 		if err = h.delegate.PostPull(ctx); err != nil {
 			return errors.Wrap(err, "failed to run post pull delegate")
@@ -147,6 +534,55 @@ func (h *StdHandle) Pull(ctx context.Context, toPath string, rep Reporter) (err
 	return nil
 }
 
+//getQuiet returns an archiver callback that gets objects from the store without reporting progress
+func (h *StdHandle) getQuiet(ctx context.Context) func(k string, w io.WriterAt) error {
+	return func(k string, w io.WriterAt) error {
+		dw, err := h.verifying(k, w)
+		if err != nil {
+			return err
+		}
+
+		if err = h.store.Get(ctx, k, dw); err != nil {
+			return err
+		}
+
+		return dw.check()
+	}
+}
+
+//Contents returns the table of contents of the dataset without pulling it
+func (h *StdHandle) Contents(ctx context.Context) (toc *transferarchiver.TOC, err error) {
+	if toc, err = h.archiver.Contents(ctx, h.getQuiet(ctx)); err != nil {
+		return nil, errors.Wrap(err, "failed to get table of contents")
+	}
+
+	return toc, nil
+}
+
+//ReadFile writes the content of a single file at (slash separated) path 'p' to
+//'w', only the range of the object that holds the file is retrieved
+func (h *StdHandle) ReadFile(ctx context.Context, p string, w io.Writer) (err error) {
+	toc, err := h.Contents(ctx)
+	if err != nil {
+		return err
+	}
+
+	e, ok := toc.Lookup(p)
+	if !ok {
+		return ErrFileNotExists
+	}
+
+	if !e.Mode.IsRegular() {
+		return ErrNotAFile
+	}
+
+	if err = h.store.GetRange(ctx, e.Key, e.Offset, e.Size, w); err != nil {
+		return errors.Wrap(err, "failed to get object range")
+	}
+
+	return nil
+}
+
 //Close the handle performing any cleanup logic
 func (h *StdHandle) Close() (err error) {
 	if h.delegate != nil {
@@ -158,11 +594,33 @@ func (h *StdHandle) Close() (err error) {
 	return nil
 }
 
-//write counter discards every byte written but keeps a count
-type writeCounter struct{ total uint64 }
+//write counter discards every byte written but keeps a count, the digests of the objects
+//that are written are recorded as well
+type writeCounter struct {
+	total   uint64
+	digests map[string]string
+}
+
+func newWriteCounter() *writeCounter {
+	return &writeCounter{digests: map[string]string{}}
+}
 
 func (wc *writeCounter) Write(p []byte) (int, error) {
 	n := len(p)
 	wc.total += uint64(n)
 	return n, nil
 }
+
+//digest returns the hex encoded SHA-256 digest of what 'r' reads, it is positioned at the start again
+func digest(r io.ReadSeeker) (sum string, err error) {
+	h := sha256.New()
+	if _, err = io.Copy(h, r); err != nil {
+		return "", errors.Wrap(err, "failed to compute digest")
+	}
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return "", errors.Wrap(err, "failed to seek to the beginning of object")
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,215 @@
+package transfer
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	//ProgressEventStart is written when a phase starts
+	ProgressEventStart = "start"
+
+	//ProgressEventProgress is written while a phase handles bytes, at most once per interval
+	ProgressEventProgress = "progress"
+
+	//ProgressEventStop is written when a phase stops
+	ProgressEventStop = "stop"
+
+	//ProgressEventKey is written when an object was handled
+	ProgressEventKey = "key"
+
+	//ProgressEventError is written when the transfer failed
+	ProgressEventError = "error"
+)
+
+const (
+	//ProgressPhaseArchiving is the phase that writes files into an archive
+	ProgressPhaseArchiving = "archiving"
+
+	//ProgressPhaseUploading is the phase that stores objects
+	ProgressPhaseUploading = "uploading"
+
+	//ProgressPhaseDownloading is the phase that retrieves objects
+	ProgressPhaseDownloading = "downloading"
+
+	//ProgressPhaseUnarchiving is the phase that writes files from an archive
+	ProgressPhaseUnarchiving = "unarchiving"
+)
+
+//ProgressEvent is a single line written by the JSONReporter. Rate is in bytes per second and
+//ETA in seconds, the latter is only known when the phase has a total
+type ProgressEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Phase string    `json:"phase,omitempty"`
+	Label string    `json:"label,omitempty"`
+	Key   string    `json:"key,omitempty"`
+	Total int64     `json:"total,omitempty"`
+	Done  int64     `json:"done,omitempty"`
+	Rate  float64   `json:"rate,omitempty"`
+	ETA   float64   `json:"eta,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+//progressPhase keeps track of the bytes handled in a phase
+type progressPhase struct {
+	label   string
+	total   int64
+	done    int64
+	started time.Time
+	last    time.Time
+}
+
+//JSONReporter writes progress as JSON events, one per line, such that other programs can follow it
+type JSONReporter struct {
+	mu       sync.Mutex
+	enc      *json.Encoder
+	interval time.Duration
+	phases   map[string]*progressPhase
+	now      func() time.Time
+}
+
+//NewJSONReporter creates a reporter that writes events to 'w', progress events of a phase are
+//written at most once per 'interval'
+func NewJSONReporter(w io.Writer, interval time.Duration) *JSONReporter {
+	return &JSONReporter{
+		enc:      json.NewEncoder(w),
+		interval: interval,
+		phases:   map[string]*progressPhase{},
+		now:      time.Now,
+	}
+}
+
+//write encodes the event, errors are ignored as progress must never fail a transfer
+func (r *JSONReporter) write(ev ProgressEvent) {
+	ev.Time = r.now().UTC()
+	r.enc.Encode(ev)
+}
+
+//event describes the phase, the rate and eta are derived from the bytes handled so far
+func (r *JSONReporter) event(event, name string, p *progressPhase) ProgressEvent {
+	ev := ProgressEvent{Event: event, Phase: name, Label: p.label, Total: p.total, Done: p.done}
+	if secs := r.now().Sub(p.started).Seconds(); secs > 0 {
+		ev.Rate = float64(p.done) / secs
+	}
+
+	if ev.Rate > 0 && p.total > p.done {
+		ev.ETA = float64(p.total-p.done) / ev.Rate
+	}
+
+	return ev
+}
+
+//start writes the start event of phase 'name'
+func (r *JSONReporter) start(name, label string, total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	p := &progressPhase{label: label, total: total, started: now, last: now}
+	r.phases[name] = p
+	r.write(r.event(ProgressEventStart, name, p))
+}
+
+//add records 'n' handled bytes for phase 'name'
+func (r *JSONReporter) add(name string, n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.phases[name]
+	if !ok {
+		return
+	}
+
+	p.done += n
+	if now := r.now(); now.Sub(p.last) >= r.interval {
+		p.last = now
+		r.write(r.event(ProgressEventProgress, name, p))
+	}
+}
+
+//stop writes the stop event of phase 'name'
+func (r *JSONReporter) stop(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.phases[name]
+	if !ok {
+		return
+	}
+
+	delete(r.phases, name)
+	r.write(r.event(ProgressEventStop, name, p))
+}
+
+//HandledKey writes an event for the key that was handled
+func (r *JSONReporter) HandledKey(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.write(ProgressEvent{Event: ProgressEventKey, Key: key})
+}
+
+//Error writes an event for the error that failed the transfer
+func (r *JSONReporter) Error(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.write(ProgressEvent{Event: ProgressEventError, Error: err.Error()})
+}
+
+//StartArchivingProgress is called when archiving has started and total size is known
+func (r *JSONReporter) StartArchivingProgress(label string, total int64) func(int64) {
+	r.start(ProgressPhaseArchiving, label, total)
+	return func(n int64) { r.add(ProgressPhaseArchiving, n) }
+}
+
+//StopArchivingProgress is called when archiving has stoppped
+func (r *JSONReporter) StopArchivingProgress() { r.stop(ProgressPhaseArchiving) }
+
+//StartUploadProgress is called when upload has started while total size is known
+func (r *JSONReporter) StartUploadProgress(label string, total int64, rr io.Reader) io.Reader {
+	r.start(ProgressPhaseUploading, label, total)
+	return &eventReader{Reader: rr, fn: func(n int64) { r.add(ProgressPhaseUploading, n) }}
+}
+
+//StopUploadProgress is called when uploading has stopped
+func (r *JSONReporter) StopUploadProgress() { r.stop(ProgressPhaseUploading) }
+
+//StartDownloadProgress will start the download progress
+func (r *JSONReporter) StartDownloadProgress(label string, total int64) io.Writer {
+	r.start(ProgressPhaseDownloading, label, total)
+	return eventWriter(func(n int64) { r.add(ProgressPhaseDownloading, n) })
+}
+
+//StopDownloadProgress will stop the download progress
+func (r *JSONReporter) StopDownloadProgress() { r.stop(ProgressPhaseDownloading) }
+
+//StartUnarchivingProgress is called when unarchiving has started and total size is known
+func (r *JSONReporter) StartUnarchivingProgress(label string, total int64, rr io.Reader) io.Reader {
+	r.start(ProgressPhaseUnarchiving, label, total)
+	return &eventReader{Reader: rr, fn: func(n int64) { r.add(ProgressPhaseUnarchiving, n) }}
+}
+
+//StopUnarchivingProgress is called when unarchiving has stopped
+func (r *JSONReporter) StopUnarchivingProgress() { r.stop(ProgressPhaseUnarchiving) }
+
+//eventReader reports the bytes that are read through it
+type eventReader struct {
+	io.Reader
+	fn func(n int64)
+}
+
+func (r *eventReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	if n > 0 {
+		r.fn(int64(n))
+	}
+
+	return n, err
+}
+
+//eventWriter reports the bytes that are written to it
+type eventWriter func(n int64)
+
+func (w eventWriter) Write(p []byte) (n int, err error) {
+	w(int64(len(p)))
+	return len(p), nil
+}
@@ -0,0 +1,112 @@
+package transfer_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/pkg/errors"
+)
+
+func TestJSONReporter(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "json_reporter_tests_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	if err = os.MkdirAll(src, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(src, "hello.txt"), bytes.Repeat([]byte("hello, world"), 1000), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := transferstore.NewFSStore(transferstore.StoreOptions{FSStoreDir: filepath.Join(dir, "objects")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := transferarchiver.NewTarArchiver(transferarchiver.ArchiverOptions{KeyPrefix: "my-prefix/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := transfer.CreateStdHandle("my-dataset", store, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	rep := transfer.NewJSONReporter(buf, 0)
+	if err = h.Push(ctx, src, rep); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Pull(ctx, filepath.Join(dir, "dst"), rep); err != nil {
+		t.Fatal(err)
+	}
+
+	rep.Error(errors.New("my-error"))
+
+	started := map[string]bool{}
+	stopped := map[string]transfer.ProgressEvent{}
+	var keys int
+	var last transfer.ProgressEvent
+	scan := bufio.NewScanner(buf)
+	for scan.Scan() {
+		var ev transfer.ProgressEvent
+		if err = json.Unmarshal(scan.Bytes(), &ev); err != nil {
+			t.Fatalf("expected every line to be a JSON event, got: %q, %v", scan.Text(), err)
+		}
+
+		if ev.Time.IsZero() {
+			t.Fatalf("expected event to have a time, got: %#v", ev)
+		}
+
+		switch ev.Event {
+		case transfer.ProgressEventStart:
+			started[ev.Phase] = true
+		case transfer.ProgressEventProgress:
+			if !started[ev.Phase] || ev.Done < 1 {
+				t.Fatalf("unexpected progress event: %#v", ev)
+			}
+		case transfer.ProgressEventStop:
+			stopped[ev.Phase] = ev
+		case transfer.ProgressEventKey:
+			keys++
+		}
+
+		last = ev
+	}
+
+	for _, phase := range []string{transfer.ProgressPhaseArchiving, transfer.ProgressPhaseUploading, transfer.ProgressPhaseDownloading, transfer.ProgressPhaseUnarchiving} {
+		ev, ok := stopped[phase]
+		if !started[phase] || !ok {
+			t.Fatalf("expected phase '%s' to start and stop", phase)
+		}
+
+		if ev.Total < 1 || ev.Done != ev.Total || ev.ETA != 0 {
+			t.Fatalf("expected all bytes of phase '%s' to be done, got: %#v", phase, ev)
+		}
+	}
+
+	if keys < 1 {
+		t.Fatal("expected handled keys to be reported")
+	}
+
+	if last.Event != transfer.ProgressEventError || last.Error != "my-error" {
+		t.Fatalf("expected error event, got: %#v", last)
+	}
+}
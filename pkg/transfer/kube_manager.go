@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 
+	datasetsv1 "github.com/nerdalize/nerd/crd/pkg/apis/stable.nerdalize.com/v1"
 	"github.com/nerdalize/nerd/pkg/transfer/archiver"
 	"github.com/nerdalize/nerd/pkg/transfer/store"
 	"github.com/nerdalize/nerd/svc"
@@ -19,14 +20,70 @@ type kubeDelegate struct {
 }
 
 func (d *kubeDelegate) PostClean(ctx context.Context) error {
-	return d.PostPush(ctx, 0)
+	return d.update(ctx, &svc.UpdateDatasetInput{
+		Name:  d.name,
+		Clear: true,
+	})
+}
+
+func (d *kubeDelegate) PrePush(ctx context.Context) error {
+	return d.update(ctx, &svc.UpdateDatasetInput{
+		Name:  d.name,
+		State: datasetsv1.DatasetStateUploading,
+	})
+}
+
+func (d *kubeDelegate) PostPush(ctx context.Context, size uint64, digests map[string]string) error {
+	return d.update(ctx, &svc.UpdateDatasetInput{
+		Name:        d.name,
+		Size:        &size,
+		State:       datasetsv1.DatasetStateReady,
+		ResetLayers: true,
+		Digests:     digests,
+	})
+}
+
+func (d *kubeDelegate) PreAppend(ctx context.Context) error {
+	return d.update(ctx, &svc.UpdateDatasetInput{
+		Name:         d.name,
+		AddAppending: 1,
+	})
+}
+
+func (d *kubeDelegate) PostAppend(ctx context.Context, layer string, size uint64, digests map[string]string) error {
+	return d.update(ctx, &svc.UpdateDatasetInput{
+		Name:         d.name,
+		AppendLayer:  layer,
+		LayerSize:    size,
+		AddDigests:   digests,
+		AddAppending: -1,
+	})
+}
+
+func (d *kubeDelegate) PostAppendError(ctx context.Context, err error) error {
+	return d.update(ctx, &svc.UpdateDatasetInput{
+		Name:         d.name,
+		AddAppending: -1,
+	})
+}
+
+func (d *kubeDelegate) PostPushError(ctx context.Context, err error) error {
+	return d.update(ctx, &svc.UpdateDatasetInput{
+		Name:         d.name,
+		State:        datasetsv1.DatasetStateFailed,
+		StateMessage: err.Error(),
+	})
+}
+
+func (d *kubeDelegate) PostTransition(ctx context.Context, class string) error {
+	return d.update(ctx, &svc.UpdateDatasetInput{
+		Name:         d.name,
+		StorageClass: class,
+	})
 }
 
-func (d *kubeDelegate) PostPush(ctx context.Context, size uint64) error {
-	if _, err := d.kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{
-		Name: d.name,
-		Size: &size,
-	}); err != nil {
+func (d *kubeDelegate) update(ctx context.Context, in *svc.UpdateDatasetInput) error {
+	if _, err := d.kube.UpdateDataset(ctx, in); err != nil {
 		return errors.Wrap(err, "failed to update dataset")
 	}
 
@@ -39,7 +96,8 @@ func (d *kubeDelegate) PostClose() error                   { return nil }
 //KubeManager is a dataset manager that uses Kubernetes as its metadata
 //store and locking service
 type KubeManager struct {
-	kube *svc.Kube
+	kube  *svc.Kube
+	cache *DiskCache
 }
 
 //NewKubeManager creates a transferManager that uses our kubevisor implementation
@@ -51,6 +109,9 @@ func NewKubeManager(kube *svc.Kube) (mgr *KubeManager, err error) {
 	return mgr, nil
 }
 
+//UseDiskCache makes handles download objects through the disk cache 'c'
+func (mgr *KubeManager) UseDiskCache(c *DiskCache) { mgr.cache = c }
+
 //Create a dataset with provided name and return a handle to it, dataset must not yet exist
 func (mgr *KubeManager) Create(ctx context.Context, name string, sto transferstore.StoreOptions, ato transferarchiver.ArchiverOptions) (h Handle, err error) {
 
@@ -64,13 +125,11 @@ func (mgr *KubeManager) Create(ctx context.Context, name string, sto transfersto
 	//archiver is in control of key prefixes inside the store prefix
 	//@TODO we would preferrably have the kubernetes name here as well
 	//but that one can be generated and is options, hence is only known
-	//after the dataset has been created
-	//@TODO this should probably a mandatory argument of any archiver so
-	//to be addedd to the ArchiverFactory type
-	ato.TarArchiverKeyPrefix = fmt.Sprintf("%x/", d)
+	//after the dataset has been created. Every archiver factory receives it
+	ato.KeyPrefix = fmt.Sprintf("%x/", d)
 
 	//step 1: initate stores and archivers from options
-	store, err := CreateStore(sto)
+	store, err := mgr.createStore(ctx, sto)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to setup store '%s' with options: %#v", sto.Type, sto)
 	}
@@ -87,7 +146,7 @@ func (mgr *KubeManager) Create(ctx context.Context, name string, sto transfersto
 		}
 	}
 
-	archiver, err := CreateArchiver(ato)
+	archiver, err := CreateArchiver(ato, store)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to setup archiver '%s' with options: %#v", ato.Type, ato)
 	}
@@ -123,20 +182,63 @@ func (mgr *KubeManager) Open(ctx context.Context, name string) (Handle, error) {
 		return nil, errors.Wrap(err, "failed to get dataset resource")
 	}
 
-	store, err := CreateStore(out.StoreOptions)
+	store, err := mgr.createStore(ctx, out.StoreOptions)
 	if err != nil {
 		return nil, errors.Errorf("failed to setup store '%s' with options: %#v", out.StoreOptions.Type, out.StoreOptions)
 	}
 
-	archiver, err := CreateArchiver(out.ArchiverOptions)
+	archiver, err := CreateArchiver(out.ArchiverOptions, store)
 	if err != nil {
 		return nil, errors.Errorf("failed to setup archiver '%s' with options: %#v", out.ArchiverOptions.Type, out.ArchiverOptions)
 	}
 
-	return CreateStdHandle(out.Name, store, archiver, &kubeDelegate{
+	h, err := CreateStdHandle(out.Name, store, archiver, &kubeDelegate{
 		name: out.Name,
 		kube: mgr.kube,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.ExpectDigests(out.Digests)
+	return h, nil
+}
+
+//createStore sets up the store, credentials that are referenced by a secret are looked
+//up first such that they are never stored with the dataset itself
+func (mgr *KubeManager) createStore(ctx context.Context, sto transferstore.StoreOptions) (Store, error) {
+	if sto.S3StoreCredentialsSecret != "" {
+		out, err := mgr.kube.GetStoreSecret(ctx, &svc.GetStoreSecretInput{
+			Name: sto.S3StoreCredentialsSecret,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get store credentials from secret '%s'", sto.S3StoreCredentialsSecret)
+		}
+
+		sto.S3StoreAccessKey = out.AccessKey
+		sto.S3StoreSecretKey = out.SecretKey
+		sto.S3SessionToken = out.SessionToken
+	}
+
+	if sto.HTTPStoreCredentialsSecret != "" {
+		out, err := mgr.kube.GetStoreSecret(ctx, &svc.GetStoreSecretInput{
+			Name: sto.HTTPStoreCredentialsSecret,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get store credentials from secret '%s'", sto.HTTPStoreCredentialsSecret)
+		}
+
+		sto.HTTPStoreUsername = out.Username
+		sto.HTTPStorePassword = out.Password
+		sto.HTTPStoreToken = out.Token
+	}
+
+	store, err := CreateStore(sto)
+	if err != nil || mgr.cache == nil {
+		return store, err
+	}
+
+	return mgr.cache.Wrap(store), nil
 }
 
 //Remove an existing dataset, dataset must exist
@@ -0,0 +1,450 @@
+package transfer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	datasetsv1 "github.com/nerdalize/nerd/crd/pkg/apis/stable.nerdalize.com/v1"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/pkg/errors"
+)
+
+var (
+	//ErrDatasetNotExists is returned when a dataset is not known to the manager
+	ErrDatasetNotExists = errors.New("dataset does not exist")
+
+	//ErrDatasetExists is returned when a dataset is created with a name that is taken
+	ErrDatasetExists = errors.New("dataset already exists")
+
+	//ErrDatasetLocked is returned when a dataset is claimed by another handle
+	ErrDatasetLocked = errors.New("dataset is opened by another handle")
+)
+
+//LocalDataset is the metadata of a dataset that is managed by the LocalManager
+type LocalDataset struct {
+	Name            string                           `json:"name"`
+	Size            uint64                           `json:"size"`
+	State           datasetsv1.DatasetState          `json:"state"`
+	StateMessage    string                           `json:"stateMessage,omitempty"`
+	Appending       int                              `json:"appending,omitempty"`
+	StoreOptions    transferstore.StoreOptions       `json:"storeOptions"`
+	ArchiverOptions transferarchiver.ArchiverOptions `json:"archiverOptions"`
+	InputFor        []string                         `json:"inputFor,omitempty"`
+	OutputFrom      []string                         `json:"outputFrom,omitempty"`
+	Digests         map[string]string                `json:"digests,omitempty"`
+	CreatedAt       time.Time                        `json:"createdAt"`
+}
+
+//localDelegate updates the metadata file after lifecycle events and releases the
+//claim on the dataset when the handle is closed
+type localDelegate struct {
+	name   string
+	mgr    *LocalManager
+	unlock func() error
+}
+
+func (d *localDelegate) PostClean(ctx context.Context) error {
+	return d.mgr.update(d.name, func(ds *LocalDataset) error {
+		ds.Size, ds.Digests = 0, nil
+		ds.State, ds.StateMessage = datasetsv1.DatasetStateCleared, ""
+		ds.ArchiverOptions.TarArchiverLayers = nil
+		return nil
+	})
+}
+
+func (d *localDelegate) PrePush(ctx context.Context) error {
+	return d.mgr.update(d.name, func(ds *LocalDataset) error {
+		ds.State, ds.StateMessage = datasetsv1.DatasetStateUploading, ""
+		return nil
+	})
+}
+
+func (d *localDelegate) PostPush(ctx context.Context, size uint64, digests map[string]string) error {
+	return d.mgr.update(d.name, func(ds *LocalDataset) error {
+		ds.Size, ds.Digests = size, digests
+		ds.State, ds.StateMessage = datasetsv1.DatasetStateReady, ""
+		ds.ArchiverOptions.TarArchiverLayers = nil
+		return nil
+	})
+}
+
+func (d *localDelegate) PostPushError(ctx context.Context, err error) error {
+	return d.mgr.update(d.name, func(ds *LocalDataset) error {
+		ds.State, ds.StateMessage = datasetsv1.DatasetStateFailed, err.Error()
+		return nil
+	})
+}
+
+func (d *localDelegate) PreAppend(ctx context.Context) error {
+	return d.mgr.update(d.name, func(ds *LocalDataset) error {
+		ds.Appending++
+		return nil
+	})
+}
+
+func (d *localDelegate) PostAppend(ctx context.Context, layer string, size uint64, digests map[string]string) error {
+	return d.mgr.update(d.name, func(ds *LocalDataset) error {
+		ds.ArchiverOptions.TarArchiverLayers = append(ds.ArchiverOptions.TarArchiverLayers, layer)
+		ds.Size += size
+		ds.Appending--
+		if ds.Digests == nil {
+			ds.Digests = map[string]string{}
+		}
+
+		for k, sum := range digests {
+			ds.Digests[k] = sum
+		}
+
+		return nil
+	})
+}
+
+func (d *localDelegate) PostAppendError(ctx context.Context, err error) error {
+	return d.mgr.update(d.name, func(ds *LocalDataset) error {
+		ds.Appending--
+		return nil
+	})
+}
+
+func (d *localDelegate) PostPull(ctx context.Context) error { return nil }
+
+func (d *localDelegate) PostTransition(ctx context.Context, class string) error {
+	return d.mgr.update(d.name, func(ds *LocalDataset) error {
+		ds.StoreOptions.S3StoreStorageClass = class
+		return nil
+	})
+}
+
+func (d *localDelegate) PostClose() (err error) {
+	if d.unlock == nil {
+		return nil //closed already
+	}
+
+	err, d.unlock = d.unlock(), nil
+	return err
+}
+
+//LocalManager is a dataset manager that keeps metadata as files in a local directory and
+//uses lock files to claim datasets. It allows datasets to be used without a cluster
+type LocalManager struct {
+	dir string
+}
+
+//NewLocalManager creates a transferManager that keeps metadata in 'dir'
+func NewLocalManager(dir string) (mgr *LocalManager, err error) {
+	mgr = &LocalManager{dir: dir}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create metadata directory")
+	}
+
+	return mgr, nil
+}
+
+//path returns the file with extension 'ext' for dataset 'name'
+func (mgr *LocalManager) path(name, ext string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", errors.Errorf("invalid dataset name '%s'", name)
+	}
+
+	return filepath.Join(mgr.dir, name+ext), nil
+}
+
+//lock claims the dataset 'name' until the returned function is called
+func (mgr *LocalManager) lock(name string) (unlock func() error, err error) {
+	p, err := mgr.path(name, ".lock")
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, errors.Wrapf(ErrDatasetLocked, "remove '%s' if no other process uses it", p)
+		}
+
+		return nil, errors.Wrap(err, "failed to create lock file")
+	}
+
+	defer f.Close()
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	return func() error {
+		if err := os.Remove(p); err != nil {
+			return errors.Wrap(err, "failed to remove lock file")
+		}
+
+		return nil
+	}, nil
+}
+
+//read returns the metadata of dataset 'name'
+func (mgr *LocalManager) read(name string) (ds *LocalDataset, err error) {
+	p, err := mgr.path(name, ".json")
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrDatasetNotExists
+		}
+
+		return nil, errors.Wrap(err, "failed to read metadata file")
+	}
+
+	ds = &LocalDataset{}
+	if err = json.Unmarshal(d, ds); err != nil {
+		return nil, errors.Wrap(err, "failed to decode metadata")
+	}
+
+	return ds, nil
+}
+
+//write replaces the metadata of the dataset
+func (mgr *LocalManager) write(ds *LocalDataset) (err error) {
+	p, err := mgr.path(ds.Name, ".json")
+	if err != nil {
+		return err
+	}
+
+	d, err := json.MarshalIndent(ds, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode metadata")
+	}
+
+	return writeState(p, d)
+}
+
+//update calls 'fn' to change the metadata of dataset 'name', the caller must have claimed it
+func (mgr *LocalManager) update(name string, fn func(ds *LocalDataset) error) (err error) {
+	ds, err := mgr.read(name)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(ds); err != nil {
+		return err
+	}
+
+	return mgr.write(ds)
+}
+
+//handle sets up the store and archiver of 'ds' and returns a handle that releases the claim when closed
+func (mgr *LocalManager) handle(ds *LocalDataset, unlock func() error) (h Handle, err error) {
+	if ds.StoreOptions.S3StoreCredentialsSecret != "" {
+		return nil, errors.New("store credentials that are kept in a secret require a cluster")
+	}
+
+	store, err := CreateStore(ds.StoreOptions)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to setup store '%s'", ds.StoreOptions.Type)
+	}
+
+	archiver, err := CreateArchiver(ds.ArchiverOptions, store)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to setup archiver '%s'", ds.ArchiverOptions.Type)
+	}
+
+	sh, err := CreateStdHandle(ds.Name, store, archiver, &localDelegate{
+		name:   ds.Name,
+		mgr:    mgr,
+		unlock: unlock,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sh.ExpectDigests(ds.Digests)
+	return sh, nil
+}
+
+//Create a dataset with provided name and return a handle to it, dataset must not yet exist
+func (mgr *LocalManager) Create(ctx context.Context, name string, sto transferstore.StoreOptions, ato transferarchiver.ArchiverOptions) (h Handle, err error) {
+	d := make([]byte, 16)
+	if _, err = rand.Read(d); err != nil {
+		return nil, errors.Wrap(err, "failed to read random bytes")
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("d-%x", d[:4])
+	}
+
+	//archiver is in control of key prefixes inside the store prefix
+	ato.KeyPrefix = fmt.Sprintf("%x/", d)
+
+	unlock, err := mgr.lock(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = mgr.read(name); err != ErrDatasetNotExists {
+		unlock()
+		if err == nil {
+			return nil, ErrDatasetExists
+		}
+
+		return nil, err
+	}
+
+	ds := &LocalDataset{
+		Name:            name,
+		State:           datasetsv1.DatasetStateCreating,
+		StoreOptions:    sto,
+		ArchiverOptions: ato,
+		CreatedAt:       time.Now(),
+	}
+
+	if h, err = mgr.handle(ds, unlock); err != nil {
+		unlock()
+		return nil, err
+	}
+
+	if err = mgr.write(ds); err != nil {
+		unlock()
+		return nil, err
+	}
+
+	return h, nil
+}
+
+//Open an existing dataset and return a handle to it, dataset must exist and is
+//claimed until the handle is closed
+func (mgr *LocalManager) Open(ctx context.Context, name string) (h Handle, err error) {
+	unlock, err := mgr.lock(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, err := mgr.read(name)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	if h, err = mgr.handle(ds, unlock); err != nil {
+		unlock()
+		return nil, err
+	}
+
+	return h, nil
+}
+
+//Remove an existing dataset, its objects are removed from the store as well. Pooled
+//parts are only removed when none of the other datasets reference them
+func (mgr *LocalManager) Remove(ctx context.Context, name string) (err error) {
+	h, err := mgr.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	defer h.Close()
+	ds, err := mgr.read(name)
+	if err != nil {
+		return err
+	}
+
+	if err = h.Clear(ctx, NewDiscardReporter()); err != nil {
+		return errors.Wrap(err, "failed to remove objects")
+	}
+
+	p, err := mgr.path(name, ".json")
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(p); err != nil {
+		return errors.Wrap(err, "failed to remove metadata file")
+	}
+
+	if ds.ArchiverOptions.TarArchiverPoolPrefix == "" {
+		return nil
+	}
+
+	others, err := mgr.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	refs := NewPoolRefs()
+	for _, o := range others {
+		p := Pool{Store: o.StoreOptions, Prefix: o.ArchiverOptions.TarArchiverPoolPrefix}
+		refs.Add(p, poolChunks(o.StoreOptions, o.ArchiverOptions, o.Digests), o.State == datasetsv1.DatasetStateUploading || o.Appending > 0)
+	}
+
+	//the pool of the removed dataset is swept even when the others don't use it
+	gc := NewPoolGC(0)
+	gc.Track(Pool{Store: ds.StoreOptions, Prefix: ds.ArchiverOptions.TarArchiverPoolPrefix})
+	if _, err = gc.Sweep(ctx, refs, NewDiscardReporter()); err != nil {
+		return errors.Wrap(err, "failed to remove unreferenced pooled parts")
+	}
+
+	return nil
+}
+
+//Info (re)fetches dataset info from the manager
+func (mgr *LocalManager) Info(ctx context.Context, name string) (size uint64, err error) {
+	ds, err := mgr.read(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return ds.Size, nil
+}
+
+//Get returns the metadata of dataset 'name'
+func (mgr *LocalManager) Get(ctx context.Context, name string) (*LocalDataset, error) {
+	return mgr.read(name)
+}
+
+//List returns the metadata of all datasets, sorted by name
+func (mgr *LocalManager) List(ctx context.Context) (datasets []*LocalDataset, err error) {
+	ps, err := filepath.Glob(filepath.Join(mgr.dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list metadata files")
+	}
+
+	for _, p := range ps {
+		ds, err := mgr.read(strings.TrimSuffix(filepath.Base(p), ".json"))
+		if err != nil {
+			return nil, err
+		}
+
+		datasets = append(datasets, ds)
+	}
+
+	sort.Slice(datasets, func(i, j int) bool {
+		return datasets[i].Name < datasets[j].Name
+	})
+
+	return datasets, nil
+}
+
+//AddLineage records that dataset 'name' is used as input for, or is the output from, a job
+func (mgr *LocalManager) AddLineage(ctx context.Context, name, inputFor, outputFrom string) (err error) {
+	unlock, err := mgr.lock(name)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+	return mgr.update(name, func(ds *LocalDataset) error {
+		if inputFor != "" {
+			ds.InputFor = append(ds.InputFor, inputFor)
+		}
+
+		if outputFrom != "" {
+			ds.OutputFrom = append(ds.OutputFrom, outputFrom)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,198 @@
+package transfer_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"testing"
+
+	datasetsv1 "github.com/nerdalize/nerd/crd/pkg/apis/stable.nerdalize.com/v1"
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/pkg/errors"
+)
+
+func TestLocalManager(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, src, clean := testSource(t, "hello.txt")
+	defer clean()
+
+	mgr, err := transfer.NewLocalManager(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sto, _ := testFSStore(t, dir)
+	ato := transferarchiver.ArchiverOptions{Type: transferarchiver.ArchiverTypeTar}
+
+	h, err := mgr.Create(ctx, "my-dataset", sto, ato)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = mgr.Open(ctx, "my-dataset"); errors.Cause(err) != transfer.ErrDatasetLocked {
+		t.Fatalf("expected open handle to lock the dataset, got: %v", err)
+	}
+
+	testPush(t, h, src)
+
+	if err = h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = mgr.Create(ctx, "my-dataset", sto, ato); err != transfer.ErrDatasetExists {
+		t.Fatalf("expected creating the dataset again to fail, got: %v", err)
+	}
+
+	size, err := mgr.Info(ctx, "my-dataset")
+	if err != nil || size == 0 {
+		t.Fatalf("expected size to be recorded after push, got: %d, %v", size, err)
+	}
+
+	if err = mgr.AddLineage(ctx, "my-dataset", "my-job", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err = mgr.Open(ctx, "my-dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "dst")
+	if err = h.Pull(ctx, dst, rep); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := ioutil.ReadFile(filepath.Join(dst, "hello.txt"))
+	if err != nil || string(d) != "hello, world" {
+		t.Fatalf("expected pulled content to equal what was pushed, got: %q, %v", d, err)
+	}
+
+	dss, err := mgr.List(ctx)
+	if err != nil || len(dss) != 1 || len(dss[0].InputFor) != 1 {
+		t.Fatalf("expected one dataset with lineage, got: %v, %v", dss, err)
+	}
+
+	h, err = mgr.Open(ctx, "my-dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Clear(ctx, rep); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dss, err = mgr.List(ctx)
+	if err != nil || dss[0].Size != 0 || dss[0].State != datasetsv1.DatasetStateCleared {
+		t.Fatalf("expected clearing to empty the dataset without marking it ready, got: %v, %v", dss, err)
+	}
+
+	if err = mgr.Remove(ctx, "my-dataset"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = mgr.Info(ctx, "my-dataset"); err != transfer.ErrDatasetNotExists {
+		t.Fatalf("expected dataset to be removed, got: %v", err)
+	}
+}
+
+func TestLocalManagerFiles(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, src, clean := testSource(t, "hello.txt")
+	defer clean()
+
+	mgr, err := transfer.NewLocalManager(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sto, _ := testFSStore(t, dir)
+	h, err := mgr.Create(ctx, "my-dataset", sto, transferarchiver.ArchiverOptions{Type: transferarchiver.ArchiverTypeFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPush(t, h, src)
+	if err = h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := mgr.Get(ctx, "my-dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ds.Digests) != 1 {
+		t.Fatalf("expected only the digest of the manifest to be recorded, got: %v", ds.Digests)
+	}
+
+	for k := range ds.Digests {
+		if path.Base(k) != transferarchiver.FileArchiverManifestKey {
+			t.Fatalf("expected only the digest of the manifest to be recorded, got: %v", ds.Digests)
+		}
+	}
+
+	h, err = mgr.Open(ctx, "my-dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer h.Close()
+	dst := filepath.Join(dir, "dst")
+	if err = h.PullPaths(ctx, dst, []string{"hello.txt"}, rep); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := ioutil.ReadFile(filepath.Join(dst, "hello.txt"))
+	if err != nil || string(d) != "hello, world" {
+		t.Fatalf("expected pulled file to equal what was pushed, got: %q, %v", d, err)
+	}
+}
+
+func TestLocalManagerClearEmpty(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	for name, ato := range map[string]transferarchiver.ArchiverOptions{
+		"split tar": {Type: transferarchiver.ArchiverTypeTar, TarArchiverPartSize: 1024},
+		"file":      {Type: transferarchiver.ArchiverTypeFile},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, _, clean := testSource(t, "hello.txt")
+			defer clean()
+
+			mgr, err := transfer.NewLocalManager(filepath.Join(dir, "meta"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sto, _ := testFSStore(t, dir)
+			h, err := mgr.Create(ctx, "my-dataset", sto, ato)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer h.Close()
+
+			//a dataset that was never pushed to can be cleared, also more than once
+			for i := 0; i < 2; i++ {
+				if err = h.Clear(ctx, rep); err != nil {
+					t.Fatalf("expected clearing a dataset without content to succeed, got: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+// +build !windows
+
+package transfer
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+//errLocked is returned when a lock is held by another process and waiting was not requested
+var errLocked = errors.New("file is locked by another process")
+
+//lockFile claims 'f' exclusively, the lock is released when the file is closed or the process exits
+func lockFile(f *os.File, wait bool) error {
+	how := syscall.LOCK_EX
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		if err == syscall.EWOULDBLOCK {
+			return errLocked
+		}
+
+		return errors.Wrap(err, "failed to lock file")
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package transfer
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+//errLocked is returned when a lock is held by another process and waiting was not requested
+var errLocked = errors.New("file is locked by another process")
+
+//lockFile is a no-op, the disk cache is shared between processes on the (linux) nodes
+//that run flex volumes only
+func lockFile(f *os.File, wait bool) error {
+	return nil
+}
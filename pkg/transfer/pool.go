@@ -0,0 +1,225 @@
+package transfer
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	datasetsv1 "github.com/nerdalize/nerd/crd/pkg/apis/stable.nerdalize.com/v1"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/pkg/errors"
+)
+
+//PoolChunk is a part in a pool that is shared between datasets, identical parts of datasets
+//that share a pool are stored only once
+type PoolChunk struct {
+	Store transferstore.StoreOptions
+	Key   string
+}
+
+//storeID identifies the store that holds the chunk
+func (c PoolChunk) storeID() string {
+	return strings.Join([]string{string(c.Store.Type), c.Store.S3StoreBucket, c.Store.FSStoreDir}, "|")
+}
+
+//ID identifies the chunk across stores
+func (c PoolChunk) ID() string {
+	return c.storeID() + "|" + c.Key
+}
+
+//poolChunks returns the pooled parts a dataset consists of, datasets record the objects they
+//consist of with their digests
+func poolChunks(sto transferstore.StoreOptions, ato transferarchiver.ArchiverOptions, digests map[string]string) (chunks []PoolChunk) {
+	for k := range digests {
+		if transferarchiver.IsPoolKey(ato.TarArchiverPoolPrefix, k) {
+			chunks = append(chunks, PoolChunk{Store: sto, Key: k})
+		}
+	}
+
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Key < chunks[j].Key })
+	return chunks
+}
+
+//PoolChunks returns the pooled parts that the dataset references
+func PoolChunks(ds *datasetsv1.Dataset) []PoolChunk {
+	return poolChunks(ds.Spec.StoreOptions, ds.Spec.ArchiverOptions, ds.Spec.Digests)
+}
+
+//Pool is a prefix in a store below which datasets share their parts. Credentials of the
+//store may be referenced by a secret, these are looked up in the namespace
+type Pool struct {
+	Namespace string
+	Store     transferstore.StoreOptions
+	Prefix    string
+}
+
+//id identifies the pool across stores
+func (p Pool) id() string {
+	return PoolChunk{Store: p.Store, Key: p.Prefix}.ID()
+}
+
+//DatasetPool returns the pool that the dataset shares its parts in, if any
+func DatasetPool(ds *datasetsv1.Dataset) (p Pool, ok bool) {
+	p = Pool{Namespace: ds.Namespace, Store: ds.Spec.StoreOptions, Prefix: ds.Spec.ArchiverOptions.TarArchiverPoolPrefix}
+	return p, p.Prefix != ""
+}
+
+//PoolRefs counts the datasets that reference each pooled part and records the pools they use.
+//While a dataset is being uploaded or appended to the parts it will reference are not known yet,
+//so its store is considered busy
+type PoolRefs struct {
+	counts map[string]int
+	busy   map[string]bool
+	pools  map[string]Pool
+}
+
+//NewPoolRefs creates an empty reference count
+func NewPoolRefs() *PoolRefs {
+	return &PoolRefs{counts: map[string]int{}, busy: map[string]bool{}, pools: map[string]Pool{}}
+}
+
+//DatasetPoolRefs counts the references of all 'datasets'
+func DatasetPoolRefs(datasets []*datasetsv1.Dataset) *PoolRefs {
+	refs := NewPoolRefs()
+	for _, ds := range datasets {
+		p, _ := DatasetPool(ds)
+		refs.Add(p, PoolChunks(ds), ds.Spec.State == datasetsv1.DatasetStateUploading || ds.Spec.Appending > 0)
+	}
+
+	return refs
+}
+
+//Add counts a reference to each of 'chunks' by a dataset that shares its parts in pool 'p'
+func (refs *PoolRefs) Add(p Pool, chunks []PoolChunk, busy bool) {
+	for _, c := range chunks {
+		refs.counts[c.ID()]++
+	}
+
+	if p.Prefix != "" {
+		refs.pools[p.id()] = p
+	}
+
+	if busy {
+		refs.busy[PoolChunk{Store: p.Store}.storeID()] = true
+	}
+}
+
+//Count returns the number of datasets that reference the chunk
+func (refs *PoolRefs) Count(c PoolChunk) int {
+	return refs.counts[c.ID()]
+}
+
+//PoolGC removes pooled parts that no dataset references anymore. Each sweep lists the parts
+//in the pools of the store and removes those that are not referenced and that were stored
+//longer than the grace period ago, uploads that stored a part, or found it to exist already,
+//can record their reference in that period. Pools of datasets that were removed are tracked
+//such that they are swept when no other dataset uses them
+type PoolGC struct {
+	grace       time.Duration
+	now         func() time.Time
+	createStore func(namespace string, sto transferstore.StoreOptions) (Store, error)
+
+	mu    sync.Mutex
+	pools map[string]Pool
+	last  time.Time
+}
+
+//NewPoolGC creates a garbage collector that removes unreferenced parts after 'grace'
+func NewPoolGC(grace time.Duration) *PoolGC {
+	return &PoolGC{
+		grace: grace,
+		now:   time.Now,
+		pools: map[string]Pool{},
+		createStore: func(namespace string, sto transferstore.StoreOptions) (Store, error) {
+			return CreateStore(sto)
+		},
+	}
+}
+
+//UseStoreFactory makes the collector setup stores with 'fn', eg. to look up credentials
+//that are referenced by a secret in the namespace
+func (gc *PoolGC) UseStoreFactory(fn func(namespace string, sto transferstore.StoreOptions) (Store, error)) {
+	gc.createStore = fn
+}
+
+//Track makes sure the pool is swept, even when no dataset uses it anymore
+func (gc *PoolGC) Track(p Pool) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	gc.pools[p.id()] = p
+}
+
+//Due returns whether a grace period passed since the last sweep, sweeping more often
+//only lists the pools again
+func (gc *PoolGC) Due() bool {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	return gc.now().Sub(gc.last) >= gc.grace
+}
+
+//Sweep removes parts that are not referenced in 'refs' from the tracked pools and those in 'refs'.
+//Parts that were stored recently, or that are in a store that is being uploaded to, are kept
+//for a later sweep
+func (gc *PoolGC) Sweep(ctx context.Context, refs *PoolRefs, rep Reporter) (swept []PoolChunk, err error) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	for id, p := range refs.pools {
+		gc.pools[id] = p
+	}
+
+	gc.last = gc.now()
+	for _, p := range gc.pools {
+		if refs.busy[PoolChunk{Store: p.Store}.storeID()] {
+			continue
+		}
+
+		var ps []PoolChunk
+		if ps, err = gc.sweep(ctx, p, refs, rep); err != nil {
+			return swept, err
+		}
+
+		swept = append(swept, ps...)
+	}
+
+	return swept, nil
+}
+
+//sweep removes the unreferenced parts of a single pool
+func (gc *PoolGC) sweep(ctx context.Context, p Pool, refs *PoolRefs, rep Reporter) (swept []PoolChunk, err error) {
+	store, err := gc.createStore(p.Namespace, p.Store)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to setup store '%s'", p.Store.Type)
+	}
+
+	l, ok := store.(Lister)
+	if !ok {
+		return nil, errors.Errorf("store '%s' cannot list pooled parts", p.Store.Type)
+	}
+
+	unreferenced := []PoolChunk{}
+	if err = l.List(ctx, p.Prefix, func(k string, size int64, modTime time.Time) error {
+		c := PoolChunk{Store: p.Store, Key: k}
+		if transferarchiver.IsPoolKey(p.Prefix, k) && refs.Count(c) < 1 && gc.now().Sub(modTime) >= gc.grace {
+			unreferenced = append(unreferenced, c)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "failed to list pool '%s'", p.Prefix)
+	}
+
+	for _, c := range unreferenced {
+		if err = store.Del(ctx, c.Key); err != nil {
+			return swept, errors.Wrapf(err, "failed to delete pooled part '%s'", c.Key)
+		}
+
+		rep.HandledKey(c.Key)
+		swept = append(swept, c)
+	}
+
+	return swept, nil
+}
@@ -0,0 +1,256 @@
+package transfer_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+)
+
+func TestPool(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, src, clean := testSource(t, "hello.txt")
+	defer clean()
+
+	mgr, err := transfer.NewLocalManager(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sto, _ := testFSStore(t, dir)
+	objects := sto.FSStoreDir
+	ato := transferarchiver.ArchiverOptions{Type: transferarchiver.ArchiverTypeTar, TarArchiverPartSize: 4096, TarArchiverPoolPrefix: "pool/"}
+	for _, name := range []string{"dataset-a", "dataset-b"} {
+		h, err := mgr.Create(ctx, name, sto, ato)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testPush(t, h, src)
+		if err = h.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pooled := func() []string {
+		ps, err := filepath.Glob(filepath.Join(objects, "pool", transferarchiver.TarArchiverPartsDir, "*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ps
+	}
+
+	if ps := pooled(); len(ps) != 1 {
+		t.Fatalf("expected identical parts to be stored once, got: %v", ps)
+	}
+
+	if err = mgr.Remove(ctx, "dataset-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ps := pooled(); len(ps) != 1 {
+		t.Fatalf("expected part that is still referenced to be kept, got: %v", ps)
+	}
+
+	h, err := mgr.Open(ctx, "dataset-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Pull(ctx, filepath.Join(dir, "dst"), rep); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := mgr.Get(ctx, "dataset-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a collector with a grace period keeps recently stored parts until a later sweep
+	chunks := []transfer.PoolChunk{}
+	for k := range ds.Digests {
+		if transferarchiver.IsPoolKey(ds.ArchiverOptions.TarArchiverPoolPrefix, k) {
+			chunks = append(chunks, transfer.PoolChunk{Store: sto, Key: k})
+		}
+	}
+
+	pool := transfer.Pool{Store: sto, Prefix: ds.ArchiverOptions.TarArchiverPoolPrefix}
+	gc := transfer.NewPoolGC(time.Hour)
+	gc.Track(pool)
+	if swept, err := gc.Sweep(ctx, transfer.NewPoolRefs(), rep); err != nil || len(swept) != 0 || gc.Due() {
+		t.Fatalf("expected unreferenced part to be kept during grace period, got: %v, %v", swept, err)
+	}
+
+	refs := transfer.NewPoolRefs()
+	refs.Add(pool, chunks, false)
+	if swept, err := transfer.NewPoolGC(0).Sweep(ctx, refs, rep); err != nil || len(swept) != 0 {
+		t.Fatalf("expected referenced part to be kept, got: %v, %v", swept, err)
+	}
+
+	if ps := pooled(); len(ps) != 1 {
+		t.Fatalf("expected referenced part to be kept, got: %v", ps)
+	}
+
+	if err = mgr.Remove(ctx, "dataset-b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ps := pooled(); len(ps) != 0 {
+		t.Fatalf("expected unreferenced part to be removed, got: %v", ps)
+	}
+}
+
+func TestPoolIdenticalFiles(t *testing.T) {
+	ctx := context.Background()
+
+	dir, _, clean := testSource(t, "hello.txt")
+	defer clean()
+
+	mgr, err := transfer.NewLocalManager(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sto, _ := testFSStore(t, dir)
+	ato := transferarchiver.ArchiverOptions{Type: transferarchiver.ArchiverTypeTar, TarArchiverPartSize: 4096, TarArchiverPoolPrefix: "pool/"}
+	mtime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, mt := range map[string]time.Time{
+		"dataset-a": mtime,
+		"dataset-b": mtime,
+		"dataset-c": mtime.Add(time.Hour),
+	} {
+		//each dataset is pushed from a directory of its own
+		_, src, sclean := testSource(t, "hello.txt")
+		defer sclean()
+
+		if err = os.Chtimes(filepath.Join(src, "hello.txt"), mt, mt); err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := mgr.Create(ctx, name, sto, ato)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testPush(t, h, src)
+		if err = h.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ps, err := filepath.Glob(filepath.Join(sto.FSStoreDir, "pool", transferarchiver.TarArchiverPartsDir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the modification time is part of the tar stream, the file of the third dataset is stored again
+	if len(ps) != 2 {
+		t.Fatalf("expected identical files from different directories to be stored once, got: %v", ps)
+	}
+}
+
+//pausingReporter holds the first upload once its object is stored, until 'resume' is closed
+type pausingReporter struct {
+	*transfer.DiscardReporter
+
+	once   sync.Once
+	stored chan struct{}
+	resume chan struct{}
+}
+
+func (r *pausingReporter) StopUploadProgress() {
+	r.once.Do(func() {
+		close(r.stored)
+		<-r.resume
+	})
+}
+
+func TestPoolAppending(t *testing.T) {
+	ctx := context.Background()
+
+	dir, src, clean := testSource(t, "hello.txt")
+	defer clean()
+
+	_, more, mclean := testSource(t, "more.txt")
+	defer mclean()
+
+	if err := ioutil.WriteFile(filepath.Join(more, "more.txt"), []byte("more content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr, err := transfer.NewLocalManager(filepath.Join(dir, "meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sto, _ := testFSStore(t, dir)
+	ato := transferarchiver.ArchiverOptions{Type: transferarchiver.ArchiverTypeTar, TarArchiverPartSize: 4096, TarArchiverPoolPrefix: "pool/"}
+	for _, name := range []string{"dataset-a", "dataset-b"} {
+		h, err := mgr.Create(ctx, name, sto, ato)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testPush(t, h, src)
+		if err = h.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := mgr.Open(ctx, "dataset-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep := &pausingReporter{DiscardReporter: transfer.NewDiscardReporter(), stored: make(chan struct{}), resume: make(chan struct{})}
+	appended := make(chan error, 1)
+	go func() {
+		appended <- h.Append(ctx, more, rep)
+	}()
+
+	//the first part of the layer is stored but not yet recorded when the pool is swept
+	<-rep.stored
+	if err = mgr.Remove(ctx, "dataset-b"); err != nil {
+		t.Fatal(err)
+	}
+
+	close(rep.resume)
+	if err = <-appended; err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err = mgr.Open(ctx, "dataset-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer h.Close()
+	if err = h.Pull(ctx, filepath.Join(dir, "dst"), transfer.NewDiscardReporter()); err != nil {
+		t.Fatalf("expected parts of the appended layer to be kept, got: %v", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "dst", "more.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if ds, err := mgr.Get(ctx, "dataset-a"); err != nil || ds.Appending != 0 {
+		t.Fatalf("expected dataset to no longer be appending, got: %v, %v", ds, err)
+	}
+}
@@ -0,0 +1,174 @@
+package transfer
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/config"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/pkg/errors"
+)
+
+//StoreFactory creates a store from its versioned config, factories decode it into options
+//of their own and upgrade configs that were written by older versions
+type StoreFactory func(cfg *transferconfig.Config) (Store, error)
+
+//ArchiverFactory creates an archiver from its versioned config, the archiver keeps the objects
+//of the dataset below 'keyPrefix'. Some archivers require the store that holds them
+type ArchiverFactory func(keyPrefix string, cfg *transferconfig.Config, store Store) (Archiver, error)
+
+var registry = struct {
+	sync.RWMutex
+	stores    map[transferstore.StoreType]StoreFactory
+	archivers map[transferarchiver.ArchiverType]ArchiverFactory
+}{
+	stores:    map[transferstore.StoreType]StoreFactory{},
+	archivers: map[transferarchiver.ArchiverType]ArchiverFactory{},
+}
+
+func init() {
+	RegisterStore(transferstore.StoreTypeS3, standardStore(transferstore.StoreTypeS3, func(opts transferstore.StoreOptions) (Store, error) {
+		return transferstore.NewS3Store(opts)
+	}))
+	RegisterStore(transferstore.StoreTypeFS, standardStore(transferstore.StoreTypeFS, func(opts transferstore.StoreOptions) (Store, error) {
+		return transferstore.NewFSStore(opts)
+	}))
+	RegisterStore(transferstore.StoreTypeHTTP, standardStore(transferstore.StoreTypeHTTP, func(opts transferstore.StoreOptions) (Store, error) {
+		return transferstore.NewHTTPStore(opts)
+	}))
+
+	RegisterArchiver(transferarchiver.ArchiverTypeTar, standardArchiver(transferarchiver.ArchiverTypeTar, func(opts transferarchiver.ArchiverOptions, store Store) (Archiver, error) {
+		return transferarchiver.NewTarArchiver(opts)
+	}))
+	RegisterArchiver(transferarchiver.ArchiverTypeRef, standardArchiver(transferarchiver.ArchiverTypeRef, func(opts transferarchiver.ArchiverOptions, store Store) (Archiver, error) {
+		l, ok := store.(Lister)
+		if !ok {
+			return nil, errors.New("store cannot list objects")
+		}
+
+		return transferarchiver.NewRefArchiver(opts, l.List)
+	}))
+	RegisterArchiver(transferarchiver.ArchiverTypeZip, standardArchiver(transferarchiver.ArchiverTypeZip, func(opts transferarchiver.ArchiverOptions, store Store) (Archiver, error) {
+		return transferarchiver.NewZipArchiver(opts, store)
+	}))
+	RegisterArchiver(transferarchiver.ArchiverTypeFile, standardArchiver(transferarchiver.ArchiverTypeFile, func(opts transferarchiver.ArchiverOptions, store Store) (Archiver, error) {
+		return transferarchiver.NewFileArchiver(opts, store)
+	}))
+}
+
+//standardStore returns a factory that decodes the config into the options of a standard store
+func standardStore(typ transferstore.StoreType, fn func(opts transferstore.StoreOptions) (Store, error)) StoreFactory {
+	return func(cfg *transferconfig.Config) (Store, error) {
+		opts, err := transferstore.DecodeStoreOptions(typ, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return fn(opts)
+	}
+}
+
+//standardArchiver returns a factory that decodes the config into the options of a standard archiver
+func standardArchiver(typ transferarchiver.ArchiverType, fn func(opts transferarchiver.ArchiverOptions, store Store) (Archiver, error)) ArchiverFactory {
+	return func(keyPrefix string, cfg *transferconfig.Config, store Store) (Archiver, error) {
+		opts, err := transferarchiver.DecodeArchiverOptions(typ, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.KeyPrefix = keyPrefix
+		return fn(opts, store)
+	}
+}
+
+//RegisterStore makes a store type available to CreateStore, it is usually called from the init
+//function of the package that implements the store. It panics when the type is registered twice
+func RegisterStore(typ transferstore.StoreType, factory StoreFactory) {
+	registry.Lock()
+	defer registry.Unlock()
+	if factory == nil {
+		panic("transfer: store factory is nil")
+	}
+
+	if _, ok := registry.stores[typ]; ok {
+		panic("transfer: store type '" + string(typ) + "' is registered twice")
+	}
+
+	registry.stores[typ] = factory
+}
+
+//RegisterArchiver makes an archiver type available to CreateArchiver, it is usually called from
+//the init function of the package that implements the archiver. It panics when the type is registered twice
+func RegisterArchiver(typ transferarchiver.ArchiverType, factory ArchiverFactory) {
+	registry.Lock()
+	defer registry.Unlock()
+	if factory == nil {
+		panic("transfer: archiver factory is nil")
+	}
+
+	if _, ok := registry.archivers[typ]; ok {
+		panic("transfer: archiver type '" + string(typ) + "' is registered twice")
+	}
+
+	registry.archivers[typ] = factory
+}
+
+//StoreTypes returns the registered store types in alphabetical order
+func StoreTypes() (types []transferstore.StoreType) {
+	registry.RLock()
+	defer registry.RUnlock()
+	for typ := range registry.stores {
+		types = append(types, typ)
+	}
+
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+//ArchiverTypes returns the registered archiver types in alphabetical order
+func ArchiverTypes() (types []transferarchiver.ArchiverType) {
+	registry.RLock()
+	defer registry.RUnlock()
+	for typ := range registry.archivers {
+		types = append(types, typ)
+	}
+
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+//CreateArchiver creates an archiver of a registered type with the provided options, some
+//archivers require the store that holds its objects
+func CreateArchiver(opts transferarchiver.ArchiverOptions, store Store) (Archiver, error) {
+	registry.RLock()
+	factory, ok := registry.archivers[opts.Type]
+	registry.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unsupported archiver '%s'", opts.Type)
+	}
+
+	cfg, err := opts.VersionedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return factory(opts.KeyPrefix, cfg, store)
+}
+
+//CreateStore creates a store of a registered type with the provided options
+func CreateStore(opts transferstore.StoreOptions) (Store, error) {
+	registry.RLock()
+	factory, ok := registry.stores[opts.Type]
+	registry.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unsupported store '%s'", opts.Type)
+	}
+
+	cfg, err := opts.VersionedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return factory(cfg)
+}
@@ -0,0 +1,172 @@
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/config"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/pkg/errors"
+)
+
+//dirStoreConfig is the config of a store that is registered by the test, version 1
+//named the directory 'path' instead of 'dir'
+type dirStoreConfig struct {
+	Dir  string `json:"dir"`
+	Path string `json:"path"`
+}
+
+func init() {
+	transfer.RegisterStore("test-dir", func(c *transferconfig.Config) (transfer.Store, error) {
+		var cfg dirStoreConfig
+		if err := c.Decode(&cfg); err != nil {
+			return nil, err
+		}
+
+		switch c.Version {
+		case 1:
+			cfg.Dir = cfg.Path
+		case 2:
+		default:
+			return nil, errors.Errorf("unsupported config version %d", c.Version)
+		}
+
+		return transferstore.NewFSStore(transferstore.StoreOptions{FSStoreDir: cfg.Dir})
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "registry_tests_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	t.Run("standard types are registered", func(t *testing.T) {
+		types := transfer.StoreTypes()
+		if len(types) != 4 || types[0] != transferstore.StoreTypeFS || types[3] != "test-dir" {
+			t.Fatalf("unexpected store types: %v", types)
+		}
+
+		if len(transfer.ArchiverTypes()) != 4 {
+			t.Fatalf("unexpected archiver types: %v", transfer.ArchiverTypes())
+		}
+	})
+
+	t.Run("unregistered types are refused", func(t *testing.T) {
+		if _, err := transfer.CreateStore(transferstore.StoreOptions{Type: "bogus"}); err == nil {
+			t.Fatal("expected store type to be refused")
+		}
+
+		if _, err := transfer.CreateArchiver(transferarchiver.ArchiverOptions{Type: "bogus"}, nil); err == nil {
+			t.Fatal("expected archiver type to be refused")
+		}
+	})
+
+	t.Run("registering a type twice panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected a panic")
+			}
+		}()
+
+		transfer.RegisterArchiver(transferarchiver.ArchiverTypeTar, func(keyPrefix string, cfg *transferconfig.Config, store transfer.Store) (transfer.Archiver, error) {
+			return nil, nil
+		})
+	})
+
+	t.Run("registered store reads versioned config", func(t *testing.T) {
+		for version, doc := range map[int]string{
+			1: `{"type":"test-dir","config":{"version":1,"data":{"path":"` + filepath.Join(dir, "v1") + `"}}}`,
+			2: `{"type":"test-dir","config":{"version":2,"data":{"dir":"` + filepath.Join(dir, "v2") + `"}}}`,
+		} {
+			var opts transferstore.StoreOptions
+			if err := json.Unmarshal([]byte(doc), &opts); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err := transfer.CreateStore(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = store.Put(ctx, "my-object", bytes.NewReader([]byte("hello"))); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = os.Stat(filepath.Join(dir, fmt.Sprintf("v%d", version), "my-object")); err != nil {
+				t.Fatalf("expected object in the configured directory for version %d, got: %v", version, err)
+			}
+		}
+
+		if _, err := transfer.CreateStore(transferstore.StoreOptions{Type: "test-dir"}); err == nil {
+			t.Fatal("expected store without config to be refused")
+		}
+	})
+
+	t.Run("standard options are stored as a versioned config", func(t *testing.T) {
+		sto := transferstore.StoreOptions{Type: transferstore.StoreTypeFS, FSStoreDir: filepath.Join(dir, "fs")}
+		ato := transferarchiver.ArchiverOptions{Type: transferarchiver.ArchiverTypeTar, KeyPrefix: "my-prefix/", TarArchiverPartSize: 4096}
+		d, err := json.Marshal(struct {
+			Store    transferstore.StoreOptions       `json:"store"`
+			Archiver transferarchiver.ArchiverOptions `json:"archiver"`
+		}{sto, ato})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var doc struct {
+			Store    map[string]json.RawMessage `json:"store"`
+			Archiver map[string]json.RawMessage `json:"archiver"`
+		}
+
+		if err = json.Unmarshal(d, &doc); err != nil || len(doc.Store) != 2 || doc.Store["config"] == nil || len(doc.Archiver) != 2 || doc.Archiver["config"] == nil {
+			t.Fatalf("expected only the type and config to be encoded, got: %s, %v", d, err)
+		}
+
+		var back struct {
+			Store    transferstore.StoreOptions       `json:"store"`
+			Archiver transferarchiver.ArchiverOptions `json:"archiver"`
+		}
+
+		if err = json.Unmarshal(d, &back); err != nil || back.Store != sto {
+			t.Fatalf("expected store options to be decoded from the config, got: %#v, %v", back.Store, err)
+		}
+
+		if back.Archiver.KeyPrefix != "my-prefix/" || back.Archiver.TarArchiverPartSize != 4096 || back.Archiver.Config != nil {
+			t.Fatalf("expected archiver options to be decoded from the config, got: %#v", back.Archiver)
+		}
+
+		var legacy transferstore.StoreOptions
+		if err = json.Unmarshal([]byte(`{"type":"s3","s3StoreBucket":"my-bucket","s3StorePrefix":"my-prefix/"}`), &legacy); err != nil || legacy.S3StoreBucket != "my-bucket" || legacy.S3StorePrefix != "my-prefix/" {
+			t.Fatalf("expected options of version 1 to be read from the fields, got: %#v, %v", legacy, err)
+		}
+
+		var legacyArchiver transferarchiver.ArchiverOptions
+		if err = json.Unmarshal([]byte(`{"type":"tar","keyPrefix":"my-prefix/","partSize":4096}`), &legacyArchiver); err != nil || legacyArchiver.KeyPrefix != "my-prefix/" || legacyArchiver.TarArchiverPartSize != 4096 {
+			t.Fatalf("expected archiver options of version 1 to be read from the fields, got: %#v, %v", legacyArchiver, err)
+		}
+
+		if err = json.Unmarshal([]byte(`{"type":"s3","config":{"version":3,"data":{}}}`), &legacy); err == nil {
+			t.Fatal("expected unknown config version to be refused")
+		}
+
+		a, err := transfer.CreateArchiver(ato, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ta, ok := a.(*transferarchiver.TarArchiver); !ok || ta.Options().KeyPrefix != "my-prefix/" || ta.Options().TarArchiverPartSize != 4096 {
+			t.Fatalf("expected archiver to be created from the decoded config and key prefix, got: %#v", a)
+		}
+	})
+}
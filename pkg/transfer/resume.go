@@ -0,0 +1,259 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+//ResumeDir is where the state of transfers is kept such that interrupted transfers can be resumed,
+//the command line interface keeps it in its configuration directory so it survives restarts
+var ResumeDir = filepath.Join(os.TempDir(), "nerd-resume")
+
+//UploadState records an upload that did not complete. Running the same upload again
+//continues with the same dataset, objects that were stored completely are then skipped.
+//Uploads are kept per namespace as the same source may be uploaded to different ones
+type UploadState struct {
+	Namespace string `json:"namespace"`
+	Source    string `json:"source"`
+	Dataset   string `json:"dataset"`
+}
+
+//statePath returns where state about 'id' is kept in the resume directory
+func statePath(kind, id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(ResumeDir, kind, hex.EncodeToString(sum[:]))
+}
+
+//uploadStatePath returns where the state of an upload from 'source' to 'namespace' is kept
+func uploadStatePath(namespace, source string) string {
+	return statePath(filepath.Join("uploads", namespace), source) + ".json"
+}
+
+//LoadUploadState returns the state of an earlier upload from 'source' to 'namespace' that did not
+//complete, it returns nil when there is none
+func LoadUploadState(namespace, source string) (st *UploadState, err error) {
+	d, err := ioutil.ReadFile(uploadStatePath(namespace, source))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to read upload state")
+	}
+
+	st = &UploadState{}
+	if err = json.Unmarshal(d, st); err != nil {
+		return nil, errors.Wrap(err, "failed to decode upload state")
+	}
+
+	if st.Source != source || st.Namespace != namespace {
+		return nil, nil //different source that hashes to the same name
+	}
+
+	return st, nil
+}
+
+//SaveUploadState records that an upload from the source in 'st' did not complete yet
+func SaveUploadState(st *UploadState) (err error) {
+	d, err := json.Marshal(st)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode upload state")
+	}
+
+	return writeState(uploadStatePath(st.Namespace, st.Source), d)
+}
+
+//RemoveUploadState forgets about the upload from 'source' to 'namespace', eg. once it completed
+func RemoveUploadState(namespace, source string) error {
+	if err := os.Remove(uploadStatePath(namespace, source)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove upload state")
+	}
+
+	return nil
+}
+
+//writeState replaces the file at 'p' with 'd' such that it is never partially written
+func writeState(p string, d []byte) (err error) {
+	if err = os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return errors.Wrap(err, "failed to create resume directory")
+	}
+
+	tmpf, err := ioutil.TempFile(filepath.Dir(p), ".state_")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary state file")
+	}
+
+	defer os.Remove(tmpf.Name())
+	defer tmpf.Close()
+	if _, err = tmpf.Write(d); err != nil {
+		return errors.Wrap(err, "failed to write state")
+	}
+
+	if err = tmpf.Close(); err != nil {
+		return errors.Wrap(err, "failed to close state file")
+	}
+
+	if err = os.Rename(tmpf.Name(), p); err != nil {
+		return errors.Wrap(err, "failed to move state file into place")
+	}
+
+	return nil
+}
+
+//resumeFile holds a partially downloaded object. Stores may write ranges out of order
+//so it keeps track of them to know how much of the start is complete when interrupted
+type resumeFile struct {
+	*os.File
+	base  int64
+	proxy io.Writer
+
+	mu    sync.Mutex
+	spans map[int64]int64
+}
+
+//Written returns the number of bytes at the start that an earlier download wrote
+func (f *resumeFile) Written() int64 { return f.base }
+
+func (f *resumeFile) WriteAt(p []byte, off int64) (n int, err error) {
+	n, err = f.File.WriteAt(p, off)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if end := off + int64(n); end > f.spans[off] {
+		f.spans[off] = end
+	}
+
+	f.proxy.Write(p[:n]) //unconditionally also write to the progress proxy
+	return n, err
+}
+
+//complete returns the number of bytes at the start of the file that were written
+func (f *resumeFile) complete() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	offs := make([]int64, 0, len(f.spans))
+	for off := range f.spans {
+		offs = append(offs, off)
+	}
+
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+	end := f.base
+	for _, off := range offs {
+		if off > end {
+			break
+		}
+
+		if f.spans[off] > end {
+			end = f.spans[off]
+		}
+	}
+
+	return end
+}
+
+//downloading holds the partial downloads that are written by this process, the same
+//object may be downloaded more than once at the same time, eg. by parallel pulls
+var downloading = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: map[string]bool{}}
+
+//claimDownload returns whether the partial download at 'p' was claimed, it is released by
+//calling 'release'. Other processes are kept out by locking the opened file 'f'
+func claimDownload(p string, f *os.File) (release func(), ok bool, err error) {
+	downloading.Lock()
+	defer downloading.Unlock()
+	if downloading.paths[p] {
+		return nil, false, nil
+	}
+
+	if err = lockFile(f, false); err == errLocked {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	downloading.paths[p] = true
+	return func() {
+		downloading.Lock()
+		defer downloading.Unlock()
+		delete(downloading.paths, p)
+	}, true, nil
+}
+
+//getResumable downloads the object at 'k' through a file in the resume directory. When the
+//download is interrupted the complete part is recorded such that a retry continues from there,
+//this is only safe for objects that never change. Only one download at a time uses the partial
+//download of an object, others that happen at the same time are downloaded directly
+func (h *StdHandle) getResumable(ctx context.Context, k string, total int64, w io.WriterAt, pw io.Writer) (err error) {
+	p := statePath("downloads", k)
+	if err = os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return errors.Wrap(err, "failed to create resume directory")
+	}
+
+	f, err := os.OpenFile(p+".part", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open partial download")
+	}
+
+	defer f.Close()
+	release, ok, err := claimDownload(p, f)
+	if err != nil {
+		return err
+	} else if !ok {
+		return h.store.Get(ctx, k, newProgressWriter(w, pw))
+	}
+
+	defer release()
+	rf := &resumeFile{File: f, proxy: pw, spans: map[int64]int64{}}
+	if d, err := ioutil.ReadFile(p + ".written"); err == nil {
+		rf.base, _ = strconv.ParseInt(strings.TrimSpace(string(d)), 10, 64)
+	}
+
+	if rf.base < 0 || rf.base > total {
+		rf.base = 0
+	}
+
+	if rf.base < total {
+		if err = h.store.Get(ctx, k, rf); err != nil {
+			if werr := writeState(p+".written", []byte(strconv.FormatInt(rf.complete(), 10))); werr != nil {
+				return errors.Wrapf(err, "failed to record partial download (%v)", werr)
+			}
+
+			return err
+		}
+	}
+
+	if _, err = io.Copy(&sectionWriter{w: w}, io.NewSectionReader(f, 0, total)); err != nil {
+		return errors.Wrap(err, "failed to copy partial download")
+	}
+
+	os.Remove(p + ".written")
+	os.Remove(p + ".part")
+	return nil
+}
+
+//sectionWriter writes sequentially to a WriterAt
+type sectionWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (w *sectionWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
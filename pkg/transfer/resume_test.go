@@ -0,0 +1,301 @@
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+)
+
+//memStore keeps objects in memory, when 'fail' is set downloads of parts stop halfway
+type memStore struct {
+	objs    map[string][]byte
+	fail    bool
+	resumed int
+}
+
+func (s *memStore) Head(ctx context.Context, k string) (int64, error) {
+	d, ok := s.objs[k]
+	if !ok {
+		return 0, errors.New("object does not exist")
+	}
+
+	return int64(len(d)), nil
+}
+
+func (s *memStore) Get(ctx context.Context, k string, w io.WriterAt) error {
+	d, ok := s.objs[k]
+	if !ok {
+		return errors.New("object does not exist")
+	}
+
+	var off int64
+	if rw, ok := w.(interface{ Written() int64 }); ok && rw.Written() > 0 {
+		off = rw.Written()
+		s.resumed++
+	}
+
+	if s.fail && strings.HasPrefix(k, transferarchiver.TarArchiverPartsDir+"/") {
+		w.WriteAt(d[off:off+(int64(len(d))-off)/2], off)
+		return errors.New("connection reset")
+	}
+
+	_, err := w.WriteAt(d[off:], off)
+	return err
+}
+
+func (s *memStore) GetRange(ctx context.Context, k string, off, n int64, w io.Writer) error {
+	_, err := w.Write(s.objs[k][off : off+n])
+	return err
+}
+
+func (s *memStore) Put(ctx context.Context, k string, r io.ReadSeeker) error {
+	d, err := ioutil.ReadAll(r)
+	s.objs[k] = d
+	return err
+}
+
+func (s *memStore) Del(ctx context.Context, k string) error {
+	delete(s.objs, k)
+	return nil
+}
+
+func TestUploadState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resume_tests_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	transfer.ResumeDir = dir
+
+	st, err := transfer.LoadUploadState("my-namespace", "/my/data")
+	if err != nil || st != nil {
+		t.Fatalf("expected no state before an upload, got: %v, %v", st, err)
+	}
+
+	if err = transfer.SaveUploadState(&transfer.UploadState{Namespace: "my-namespace", Source: "/my/data", Dataset: "d-123"}); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err = transfer.LoadUploadState("my-namespace", "/my/data")
+	if err != nil || st == nil || st.Dataset != "d-123" {
+		t.Fatalf("expected state to be loaded, got: %v, %v", st, err)
+	}
+
+	if st, err = transfer.LoadUploadState("other-namespace", "/my/data"); err != nil || st != nil {
+		t.Fatalf("expected no state for the same source in another namespace, got: %v, %v", st, err)
+	}
+
+	if err = transfer.RemoveUploadState("my-namespace", "/my/data"); err != nil {
+		t.Fatal(err)
+	}
+
+	if st, _ = transfer.LoadUploadState("my-namespace", "/my/data"); st != nil {
+		t.Fatal("expected state to be removed")
+	}
+}
+
+func TestResumePull(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	rdir, err := ioutil.TempDir("", "resume_tests_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(rdir)
+	transfer.ResumeDir = rdir
+
+	a, err := transferarchiver.NewTarArchiver(transferarchiver.ArchiverOptions{TarArchiverPartSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &memStore{objs: map[string][]byte{}}
+	h, err := transfer.CreateStdHandle("my-dataset", store, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := ioutil.TempDir("", "resume_tests_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(src)
+	content := bytes.Repeat([]byte("hello, world "), 1000)
+	if err = ioutil.WriteFile(filepath.Join(src, "hello.txt"), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Push(ctx, src, rep); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("push again skips stored parts", func(t *testing.T) {
+		n := len(store.objs)
+		if err = h.Push(ctx, src, rep); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(store.objs) != n {
+			t.Fatalf("expected the same objects after pushing unchanged content, got %d instead of %d", len(store.objs), n)
+		}
+	})
+
+	t.Run("pull continues after an interrupted download", func(t *testing.T) {
+		dst := filepath.Join(rdir, "dst")
+		store.fail = true
+		if err = h.Pull(ctx, dst, rep); err == nil {
+			t.Fatal("expected the interrupted pull to fail")
+		}
+
+		store.fail = false
+		if err = os.RemoveAll(dst); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = h.Pull(ctx, dst, rep); err != nil {
+			t.Fatal(err)
+		}
+
+		if store.resumed == 0 {
+			t.Fatal("expected the download to continue from what was written before")
+		}
+
+		d, err := ioutil.ReadFile(filepath.Join(dst, "hello.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(d, content) {
+			t.Fatal("expected pulled content to equal what was pushed")
+		}
+	})
+}
+
+//gatedStore holds downloads of files until two of them run at the same time, it counts them and
+//records how many were written to a partial download at once
+type gatedStore struct {
+	*transferstore.FSStore
+
+	mu         sync.Mutex
+	gets       int
+	partial    int
+	maxPartial int
+	both       chan struct{}
+}
+
+func (s *gatedStore) Get(ctx context.Context, k string, w io.WriterAt) error {
+	if !strings.Contains(k, "/"+transferarchiver.FileArchiverFilesPrefix) {
+		return s.FSStore.Get(ctx, k, w)
+	}
+
+	_, partial := w.(interface{ Written() int64 })
+	s.mu.Lock()
+	if s.gets++; s.gets == 2 {
+		close(s.both)
+	}
+
+	if partial {
+		if s.partial++; s.partial > s.maxPartial {
+			s.maxPartial = s.partial
+		}
+	}
+
+	s.mu.Unlock()
+	select {
+	case <-s.both:
+	case <-time.After(time.Second):
+	}
+
+	err := s.FSStore.Get(ctx, k, w)
+	s.mu.Lock()
+	if partial {
+		s.partial--
+	}
+
+	s.mu.Unlock()
+	return err
+}
+
+func TestResumePullIdentical(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, src, clean := testSource(t, "a.txt")
+	defer clean()
+
+	transfer.ResumeDir = filepath.Join(dir, "resume")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("hello, world"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, fss := testFSStore(t, dir)
+	store := &gatedStore{FSStore: fss, both: make(chan struct{})}
+	a, err := transferarchiver.NewFileArchiver(transferarchiver.ArchiverOptions{KeyPrefix: "my-dataset/"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := transfer.CreateStdHandle("my-dataset", store, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPush(t, h, src)
+
+	//both files share an object, it is downloaded once by each of the pulls that run at the same time
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func(dst string) {
+			errs <- h.Pull(ctx, dst, rep)
+		}(filepath.Join(dir, "dst-"+strconv.Itoa(i)))
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if store.gets != 2 {
+		t.Fatalf("expected the shared object to be downloaded once per pull, got: %d", store.gets)
+	}
+
+	if store.maxPartial != 1 {
+		t.Fatalf("expected one download at a time to use the partial download, got: %d", store.maxPartial)
+	}
+
+	for i := 0; i < 2; i++ {
+		for _, name := range []string{"a.txt", filepath.Join("sub", "b.txt")} {
+			d, err := ioutil.ReadFile(filepath.Join(dir, "dst-"+strconv.Itoa(i), name))
+			if err != nil || string(d) != "hello, world" {
+				t.Fatalf("expected pulled file '%s' to equal what was pushed, got: %q, %v", name, d, err)
+			}
+		}
+	}
+
+	parts, err := filepath.Glob(filepath.Join(transfer.ResumeDir, "downloads", "*"))
+	if err != nil || len(parts) != 0 {
+		t.Fatalf("expected no partial downloads to remain, got: %v, %v", parts, err)
+	}
+}
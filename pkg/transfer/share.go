@@ -0,0 +1,194 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	slashpath "path"
+
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/pkg/errors"
+)
+
+//ShareKeyPrefix is the prefix of the objects that hold share manifests, it is placed below
+//the key prefix of the dataset
+const ShareKeyPrefix = "shares/"
+
+//shareKeyPrefix returns the prefix below which the share manifests of a dataset are stored
+func shareKeyPrefix(name string, opts transferarchiver.ArchiverOptions) string {
+	return slashpath.Join(opts.KeyPrefix, ShareKeyPrefix, name) + "/"
+}
+
+//clearShares removes the share manifests of the dataset, links to them stop working
+func (h *StdHandle) clearShares(ctx context.Context, reporter Reporter) (err error) {
+	ao, ok := h.archiver.(archiverOptioner)
+	if !ok {
+		return nil //cannot be shared
+	}
+
+	l, ok := h.store.(Lister)
+	if !ok {
+		return nil
+	}
+
+	keys := []string{}
+	if err = l.List(ctx, shareKeyPrefix(h.name, ao.Options()), func(k string, size int64, modTime time.Time) error {
+		keys = append(keys, k)
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "failed to list share manifests")
+	}
+
+	for _, k := range keys {
+		if err = h.store.Del(ctx, k); err != nil {
+			return errors.Wrapf(err, "failed to delete share manifest '%s'", k)
+		}
+
+		reporter.HandledKey(k)
+	}
+
+	return nil
+}
+
+//archiverOptioner is implemented by archivers that can tell the options they were setup with
+type archiverOptioner interface {
+	Options() transferarchiver.ArchiverOptions
+}
+
+//ShareManifest describes a dataset such that it can be downloaded through links to its objects
+type ShareManifest struct {
+	Name            string                              `json:"name"`
+	ArchiverOptions transferarchiver.ArchiverOptions    `json:"archiverOptions"`
+	Digests         map[string]string                   `json:"digests,omitempty"`
+	Objects         map[string]transferstore.LinkObject `json:"objects"`
+	Expires         time.Time                           `json:"expires"`
+}
+
+//Share stores a manifest with links to all objects of the dataset and returns a link to
+//it, the links allow anyone to download the dataset until they expire
+func (h *StdHandle) Share(ctx context.Context, expires time.Duration) (link string, err error) {
+	ps, ok := h.store.(Presigner)
+	if !ok {
+		return "", errors.New("store cannot create links to objects")
+	}
+
+	ao, ok := h.archiver.(archiverOptioner)
+	if !ok {
+		return "", errors.New("datasets that reference existing objects cannot be shared")
+	}
+
+	m := &ShareManifest{
+		Name:            h.name,
+		ArchiverOptions: ao.Options(),
+		Digests:         h.digests,
+		Objects:         map[string]transferstore.LinkObject{},
+		Expires:         time.Now().Add(expires),
+	}
+
+	add := func(k string) error {
+		if _, ok := m.Objects[k]; ok {
+			return nil
+		}
+
+		size, err := h.store.Head(ctx, k)
+		if err == transferstore.ErrObjectNotExists {
+			return nil //eg. archives that were stored without a table of contents
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to get metadata of '%s'", k)
+		}
+
+		u, err := ps.Presign(k, expires)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create link to '%s'", k)
+		}
+
+		m.Objects[k] = transferstore.LinkObject{URL: u, Size: size}
+		return nil
+	}
+
+	if err = h.archiver.Index(add); err != nil {
+		return "", errors.Wrap(err, "failed to index objects")
+	}
+
+	if pi, ok := h.archiver.(partIndexer); ok {
+		if err = pi.PartIndex(ctx, h.getQuiet(ctx), add); err != nil {
+			return "", errors.Wrap(err, "failed to index parts")
+		}
+	}
+
+	d, err := json.Marshal(m)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode share manifest")
+	}
+
+	rnd := make([]byte, 8)
+	if _, err = rand.Read(rnd); err != nil {
+		return "", errors.Wrap(err, "failed to read random bytes")
+	}
+
+	k := shareKeyPrefix(h.name, ao.Options()) + fmt.Sprintf("%x.json", rnd)
+	if err = h.store.Put(ctx, k, bytes.NewReader(d)); err != nil {
+		return "", errors.Wrap(err, "failed to store share manifest")
+	}
+
+	if link, err = ps.Presign(k, expires); err != nil {
+		return "", errors.Wrap(err, "failed to create link to share manifest")
+	}
+
+	return link, nil
+}
+
+//FetchShare downloads the share manifest that 'link' points to
+func FetchShare(ctx context.Context, link string) (m *ShareManifest, err error) {
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to request share manifest")
+	}
+
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		return nil, transferstore.ErrLinkDenied
+	default:
+		return nil, errors.Errorf("unexpected response status '%s'", resp.Status)
+	}
+
+	m = &ShareManifest{}
+	if err = json.NewDecoder(resp.Body).Decode(m); err != nil {
+		return nil, errors.Wrap(err, "failed to decode share manifest")
+	}
+
+	if time.Now().After(m.Expires) {
+		return nil, errors.Wrapf(transferstore.ErrLinkDenied, "links expired at %s", m.Expires.Format(time.RFC3339))
+	}
+
+	return m, nil
+}
+
+//Handle returns a handle that downloads the shared dataset through the links in the manifest
+func (m *ShareManifest) Handle() (h *StdHandle, err error) {
+	store := transferstore.NewLinkStore(m.Objects)
+	archiver, err := CreateArchiver(m.ArchiverOptions, store)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to setup archiver '%s'", m.ArchiverOptions.Type)
+	}
+
+	if h, err = CreateStdHandle(m.Name, store, archiver, nil); err != nil {
+		return nil, err
+	}
+
+	h.ExpectDigests(m.Digests)
+	return h, nil
+}
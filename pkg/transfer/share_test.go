@@ -0,0 +1,103 @@
+package transfer_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/pkg/errors"
+)
+
+//linkStore creates links to objects that are served from the directory of a file system store
+type linkStore struct {
+	*transferstore.FSStore
+	url string
+}
+
+func (s *linkStore) Presign(k string, expires time.Duration) (string, error) {
+	return s.url + "/" + k, nil
+}
+
+func TestShare(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, src, clean := testSource(t, filepath.Join("sub", "hello.txt"))
+	defer clean()
+
+	sto, fs := testFSStore(t, dir)
+	objects := sto.FSStoreDir
+	srv := httptest.NewServer(http.FileServer(http.Dir(objects)))
+	defer srv.Close()
+
+	for _, partSize := range []int64{0, 4096} {
+		a, err := transferarchiver.NewTarArchiver(transferarchiver.ArchiverOptions{KeyPrefix: "my-prefix/", TarArchiverPartSize: partSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := transfer.CreateStdHandle("my-dataset", &linkStore{FSStore: fs, url: srv.URL}, a, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testPush(t, h, src)
+		link, err := h.Share(ctx, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(link, "/my-prefix/shares/my-dataset/") {
+			t.Fatalf("expected share manifest to be stored below the dataset prefix, got: %s", link)
+		}
+
+		m, err := transfer.FetchShare(ctx, link)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sh, err := m.Handle()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dst := filepath.Join(dir, "dst")
+		if err = sh.Pull(ctx, dst, rep); err != nil {
+			t.Fatalf("expected shared dataset to be downloaded with part size %d, got: %v", partSize, err)
+		}
+
+		d, err := ioutil.ReadFile(filepath.Join(dst, "sub", "hello.txt"))
+		if err != nil || string(d) != "hello, world" {
+			t.Fatalf("expected shared file content, got: %q, %v", d, err)
+		}
+
+		if err = sh.Clear(ctx, rep); errors.Cause(err) != transferstore.ErrReadOnly {
+			t.Fatalf("expected shared dataset to be read-only, got: %v", err)
+		}
+
+		if _, err = transfer.FetchShare(ctx, srv.URL+"/shares/unknown.json"); err == nil {
+			t.Fatal("expected unknown link to fail")
+		}
+
+		if err = h.Clear(ctx, rep); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = transfer.FetchShare(ctx, link); err == nil {
+			t.Fatal("expected share of a cleared dataset to be removed")
+		}
+
+		if err = os.RemoveAll(dst); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
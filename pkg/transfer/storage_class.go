@@ -0,0 +1,102 @@
+package transfer
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+//contentKeys returns the keys of objects that hold the content of the dataset. Objects that
+//only describe it are left out, as are pooled parts that other datasets may use as well
+func (h *StdHandle) contentKeys(ctx context.Context) (keys []string, err error) {
+	mk, _ := h.archiver.(metadataKeyer)
+	seen := map[string]bool{}
+	add := func(k string) error {
+		if seen[k] || h.pooled(k) || (mk != nil && mk.Metadata(k)) {
+			return nil
+		}
+
+		seen[k] = true
+		keys = append(keys, k)
+		return nil
+	}
+
+	if err = h.archiver.Index(add); err != nil {
+		return nil, errors.Wrap(err, "failed to index objects")
+	}
+
+	if pi, ok := h.archiver.(partIndexer); ok {
+		if err = pi.PartIndex(ctx, h.getQuiet(ctx), add); err != nil {
+			return nil, errors.Wrap(err, "failed to index parts")
+		}
+	}
+
+	return keys, nil
+}
+
+//storageClasser returns the store as a StorageClasser
+func (h *StdHandle) storageClasser() (StorageClasser, error) {
+	sc, ok := h.store.(StorageClasser)
+	if !ok {
+		return nil, errors.New("store does not support storage classes")
+	}
+
+	return sc, nil
+}
+
+//Transition moves the content of the dataset to storage class 'class', eg. to archive a
+//dataset that is no longer used. Its table of contents is kept readable
+func (h *StdHandle) Transition(ctx context.Context, class string, rep Reporter) (err error) {
+	sc, err := h.storageClasser()
+	if err != nil {
+		return err
+	}
+
+	keys, err := h.contentKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err = sc.SetStorageClass(ctx, k, class); err != nil {
+			return errors.Wrapf(err, "failed to move '%s' to storage class '%s'", k, class)
+		}
+
+		rep.HandledKey(k)
+	}
+
+	if h.delegate != nil {
+		if err = h.delegate.PostTransition(ctx, class); err != nil {
+			return errors.Wrap(err, "failed to run post transition delegate")
+		}
+	}
+
+	return nil
+}
+
+//Restore makes archived content readable for 'days' days, it returns whether all content can
+//be read already. Restoring takes hours, calling it again reports whether it finished
+func (h *StdHandle) Restore(ctx context.Context, days int64, rep Reporter) (ready bool, err error) {
+	sc, err := h.storageClasser()
+	if err != nil {
+		return false, err
+	}
+
+	keys, err := h.contentKeys(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	ready = true
+	for _, k := range keys {
+		r, err := sc.Restore(ctx, k, days)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to restore '%s'", k)
+		}
+
+		ready = ready && r
+		rep.HandledKey(k)
+	}
+
+	return ready, nil
+}
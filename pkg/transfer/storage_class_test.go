@@ -0,0 +1,84 @@
+package transfer_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+)
+
+//classStore keeps the storage class of objects next to a file system store
+type classStore struct {
+	*transferstore.FSStore
+	classes map[string]string
+}
+
+func (s *classStore) SetStorageClass(ctx context.Context, k string, class string) error {
+	s.classes[k] = class
+	return nil
+}
+
+func (s *classStore) Restore(ctx context.Context, k string, days int64) (ready bool, err error) {
+	return s.classes[k] != "GLACIER", nil
+}
+
+func TestStorageClass(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, src, clean := testSource(t, "hello.txt")
+	defer clean()
+
+	_, fs := testFSStore(t, dir)
+	for _, partSize := range []int64{0, 4096} {
+		store := &classStore{FSStore: fs, classes: map[string]string{}}
+		a, err := transferarchiver.NewTarArchiver(transferarchiver.ArchiverOptions{TarArchiverPartSize: partSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := transfer.CreateStdHandle("my-dataset", store, a, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testPush(t, h, src)
+		if ready, err := h.Restore(ctx, 1, rep); err != nil || !ready {
+			t.Fatalf("expected content that is not archived to be readable, got: %v, %v", ready, err)
+		}
+
+		if err = h.Transition(ctx, "GLACIER", rep); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(store.classes) != 1 {
+			t.Fatalf("expected only the content object to be moved with part size %d, got: %v", partSize, store.classes)
+		}
+
+		for k := range store.classes {
+			if base := filepath.Base(k); base == transferarchiver.TarArchiverTOCKey || base == transferarchiver.TarArchiverIndexKey {
+				t.Fatalf("expected metadata to stay readable, got: %v", store.classes)
+			}
+		}
+
+		if ready, err := h.Restore(ctx, 1, rep); err != nil || ready {
+			t.Fatalf("expected archived content to require a restore, got: %v, %v", ready, err)
+		}
+
+		if err = h.Clear(ctx, rep); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := transfer.CreateStdHandle("my-dataset", fs, &transferarchiver.TarArchiver{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Transition(ctx, "GLACIER", rep); err == nil {
+		t.Fatal("expected store without storage classes to fail")
+	}
+}
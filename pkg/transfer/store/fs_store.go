@@ -0,0 +1,244 @@
+package transferstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	slashpath "path"
+
+	"github.com/pkg/errors"
+)
+
+//FSStore keeps objects as files in a local directory, it allows datasets to be
+//stored without object storage, eg. for tests or on a shared file system
+type FSStore struct {
+	dir string
+}
+
+//NewFSStore creates a file system implementation of the object store
+func NewFSStore(cfg StoreOptions) (store *FSStore, err error) {
+	if cfg.FSStoreDir == "" {
+		return nil, errors.New("store directory must be configured")
+	}
+
+	store = &FSStore{dir: cfg.FSStoreDir}
+	if err = os.MkdirAll(store.dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create store directory")
+	}
+
+	return store, nil
+}
+
+//path returns the file that holds the object with key 'k'
+func (store *FSStore) path(k string) (string, error) {
+	k = slashpath.Clean(strings.TrimLeft(k, "/"))
+	if k == "." || k == ".." || strings.HasPrefix(k, "../") {
+		return "", errors.Errorf("invalid object key '%s'", k)
+	}
+
+	return filepath.Join(store.dir, filepath.FromSlash(k)), nil
+}
+
+//open opens the file of the object with key 'k' for reading
+func (store *FSStore) open(k string) (f *os.File, err error) {
+	p, err := store.path(k)
+	if err != nil {
+		return nil, err
+	}
+
+	if f, err = os.Open(p); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrObjectNotExists
+		}
+
+		return nil, errors.Wrap(err, "failed to open object file")
+	}
+
+	return f, nil
+}
+
+//Head returns metadata for the object
+func (store *FSStore) Head(ctx context.Context, k string) (size int64, err error) {
+	f, err := store.open(k)
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to stat object file")
+	}
+
+	return fi.Size(), nil
+}
+
+//ETag returns the size of the object and a tag that changes whenever the object is written again
+func (store *FSStore) ETag(ctx context.Context, k string) (size int64, etag string, err error) {
+	f, err := store.open(k)
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, "", errors.Wrap(err, "failed to stat object file")
+	}
+
+	return fi.Size(), fmt.Sprintf("%x-%x", fi.ModTime().UnixNano(), fi.Size()), nil
+}
+
+//Get a object from the store with key 'k' and write it to 'w', when 'w' holds the start
+//of the object already only the remainder is copied
+func (store *FSStore) Get(ctx context.Context, k string, w io.WriterAt) (err error) {
+	f, err := store.open(k)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	var off int64
+	if rw, ok := w.(ResumableWriterAt); ok {
+		off = rw.Written()
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "failed to stat object file")
+	}
+
+	if _, err = io.Copy(
+		&offsetWriter{w: w, off: off},
+		&ctxReader{ctx: ctx, r: io.NewSectionReader(f, off, fi.Size()-off)},
+	); err != nil {
+		return errors.Wrap(err, "failed to copy object")
+	}
+
+	return nil
+}
+
+//GetRange writes 'n' bytes of the object with key 'k', starting at offset 'off', to 'w'
+func (store *FSStore) GetRange(ctx context.Context, k string, off, n int64, w io.Writer) (err error) {
+	f, err := store.open(k)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	if _, err = io.Copy(w, &ctxReader{ctx: ctx, r: io.NewSectionReader(f, off, n)}); err != nil {
+		return errors.Wrap(err, "failed to copy object range")
+	}
+
+	return nil
+}
+
+//Put an object into the store at key 'k' by reading from 'r', the object is
+//written to a temporary file first such that it is never partially visible
+func (store *FSStore) Put(ctx context.Context, k string, r io.ReadSeeker) (err error) {
+	p, err := store.path(k)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return errors.Wrap(err, "failed to create object directory")
+	}
+
+	tmpf, err := ioutil.TempFile(filepath.Dir(p), ".put_")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary object file")
+	}
+
+	defer os.Remove(tmpf.Name())
+	defer tmpf.Close()
+	if _, err = io.Copy(tmpf, &ctxReader{ctx: ctx, r: r}); err != nil {
+		return errors.Wrap(err, "failed to write object")
+	}
+
+	if err = tmpf.Close(); err != nil {
+		return errors.Wrap(err, "failed to close object file")
+	}
+
+	if err = os.Rename(tmpf.Name(), p); err != nil {
+		return errors.Wrap(err, "failed to move object file into place")
+	}
+
+	return nil
+}
+
+//Del will remove an object from the store at key 'k', like with S3 it is not an
+//error when the object doesn't exist
+func (store *FSStore) Del(ctx context.Context, k string) error {
+	p, err := store.path(k)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to delete object file")
+	}
+
+	return nil
+}
+
+//List calls 'fn' for every object with a key that starts with 'prefix'
+func (store *FSStore) List(ctx context.Context, prefix string, fn func(k string, size int64, modTime time.Time) error) (err error) {
+	return filepath.Walk(store.dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if !fi.Mode().IsRegular() || strings.HasPrefix(fi.Name(), ".put_") {
+			return nil //directories and objects that are being written
+		}
+
+		rel, err := filepath.Rel(store.dir, p)
+		if err != nil {
+			return errors.Wrap(err, "failed to determine object key")
+		}
+
+		k := filepath.ToSlash(rel)
+		if !strings.HasPrefix(k, prefix) {
+			return nil
+		}
+
+		return fn(k, fi.Size(), fi.ModTime())
+	})
+}
+
+//offsetWriter writes sequentially to a WriterAt, starting at 'off'
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (w *offsetWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
+
+//ctxReader stops reading when its context is cancelled
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
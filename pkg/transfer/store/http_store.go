@@ -0,0 +1,302 @@
+package transferstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	slashpath "path"
+
+	"github.com/pkg/errors"
+)
+
+//ErrAccessDenied is returned when the server refuses the credentials of the store
+var ErrAccessDenied = errors.New("access to the store was denied, check its credentials")
+
+//HTTPStore keeps objects on a HTTP server that supports GET (with ranges), PUT and DELETE
+//requests for the URL of each object, eg. a WebDAV server. Requests use basic auth when a
+//username is configured or bearer auth when a token is
+type HTTPStore struct {
+	base   *url.URL
+	client *http.Client
+	opts   StoreOptions
+}
+
+//NewHTTPStore creates a store that keeps objects below the URL in the options
+func NewHTTPStore(opts StoreOptions) (store *HTTPStore, err error) {
+	store = &HTTPStore{client: http.DefaultClient, opts: opts}
+	if store.base, err = url.Parse(opts.HTTPStoreURL); err != nil {
+		return nil, errors.Wrap(err, "failed to parse store url")
+	}
+
+	if store.base.Scheme != "http" && store.base.Scheme != "https" {
+		return nil, errors.Errorf("store url '%s' must start with http:// or https://", opts.HTTPStoreURL)
+	}
+
+	if opts.HTTPStoreUsername != "" && opts.HTTPStoreToken != "" {
+		return nil, errors.New("store can use either basic auth or a bearer token, not both")
+	}
+
+	return store, nil
+}
+
+//url returns the URL of the object with key 'k', each part of the key is escaped
+func (store *HTTPStore) url(k string) (string, error) {
+	k = slashpath.Clean(strings.TrimLeft(k, "/"))
+	if k == "." || k == ".." || strings.HasPrefix(k, "../") {
+		return "", errors.Errorf("invalid object key '%s'", k)
+	}
+
+	parts := strings.Split(k, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+
+	return strings.TrimSuffix(store.base.String(), "/") + "/" + strings.Join(parts, "/"), nil
+}
+
+//do sends a request with 'method' for the object with key 'k', the response body must be closed
+func (store *HTTPStore) do(ctx context.Context, method, k string, body io.Reader, fn func(req *http.Request)) (resp *http.Response, err error) {
+	u, err := store.url(k)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	switch {
+	case store.opts.HTTPStoreUsername != "":
+		req.SetBasicAuth(store.opts.HTTPStoreUsername, store.opts.HTTPStorePassword)
+	case store.opts.HTTPStoreToken != "":
+		req.Header.Set("Authorization", "Bearer "+store.opts.HTTPStoreToken)
+	}
+
+	if fn != nil {
+		fn(req)
+	}
+
+	if resp, err = store.client.Do(req.WithContext(ctx)); err != nil {
+		return nil, errors.Wrapf(err, "failed to send %s request", method)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		resp.Body.Close()
+		return nil, ErrAccessDenied
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrObjectNotExists
+	}
+
+	return resp, nil
+}
+
+//head returns the response headers of the object with key 'k'
+func (store *HTTPStore) head(ctx context.Context, k string) (hdr http.Header, err error) {
+	resp, err := store.do(ctx, http.MethodHead, k, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected response status '%s'", resp.Status)
+	}
+
+	return resp.Header, nil
+}
+
+//Head returns metadata for the object
+func (store *HTTPStore) Head(ctx context.Context, k string) (size int64, err error) {
+	hdr, err := store.head(ctx, k)
+	if err != nil {
+		return 0, err
+	}
+
+	if size, err = strconv.ParseInt(hdr.Get("Content-Length"), 10, 64); err != nil {
+		return 0, errors.Wrap(err, "failed to parse object size")
+	}
+
+	return size, nil
+}
+
+//ETag returns the size and the entity tag that the server reports for the object, the tag changes when the object does
+func (store *HTTPStore) ETag(ctx context.Context, k string) (size int64, etag string, err error) {
+	hdr, err := store.head(ctx, k)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if size, err = strconv.ParseInt(hdr.Get("Content-Length"), 10, 64); err != nil {
+		return 0, "", errors.Wrap(err, "failed to parse object size")
+	}
+
+	return size, hdr.Get("ETag"), nil
+}
+
+//Get a object from the store with key 'k' and write it to 'w', when 'w' holds the start
+//of the object already only the remainder is downloaded
+func (store *HTTPStore) Get(ctx context.Context, k string, w io.WriterAt) (err error) {
+	var off int64
+	if rw, ok := w.(ResumableWriterAt); ok {
+		off = rw.Written()
+	}
+
+	return store.get(ctx, k, off, -1, &sequentialWriter{w: w, off: off})
+}
+
+//GetRange writes 'n' bytes of the object with key 'k', starting at offset 'off', to 'w'
+func (store *HTTPStore) GetRange(ctx context.Context, k string, off, n int64, w io.Writer) (err error) {
+	if n < 1 {
+		return nil
+	}
+
+	return store.get(ctx, k, off, n, w)
+}
+
+//get downloads 'n' bytes from offset 'off' of the object, or everything from the offset when 'n' is negative
+func (store *HTTPStore) get(ctx context.Context, k string, off, n int64, w io.Writer) (err error) {
+	resp, err := store.do(ctx, http.MethodGet, k, nil, func(req *http.Request) {
+		switch {
+		case n > 0:
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+		case off > 0:
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", off))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		//the server ignored the range, skip to the offset
+		if _, err = io.CopyN(ioutil.Discard, resp.Body, off); err != nil {
+			return errors.Wrap(err, "failed to skip to offset")
+		}
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		if n < 0 {
+			return nil //the object was downloaded completely before
+		}
+
+		return errors.Errorf("range of %d bytes at offset %d is not satisfiable", n, off)
+	default:
+		return errors.Errorf("unexpected response status '%s'", resp.Status)
+	}
+
+	if n < 0 {
+		_, err = io.Copy(w, resp.Body)
+	} else {
+		_, err = io.CopyN(w, resp.Body, n)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed to copy object")
+	}
+
+	return nil
+}
+
+//Put an object in the store, WebDAV servers refuse objects in collections that don't exist
+//so these are created when the server reports a conflict
+func (store *HTTPStore) Put(ctx context.Context, k string, r io.ReadSeeker) (err error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.Wrap(err, "failed to seek to end of object")
+	}
+
+	put := func() (status int, err error) {
+		if _, err = r.Seek(0, io.SeekStart); err != nil {
+			return 0, errors.Wrap(err, "failed to seek to beginning of object")
+		}
+
+		var body io.Reader = http.NoBody //otherwise an empty object would be sent in chunks
+		if size > 0 {
+			body = ioutil.NopCloser(r)
+		}
+
+		resp, err := store.do(ctx, http.MethodPut, k, body, func(req *http.Request) {
+			req.ContentLength = size
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	status, err := put()
+	if err != nil {
+		return err
+	}
+
+	if status == http.StatusConflict {
+		if err = store.mkcol(ctx, slashpath.Dir(k)); err != nil {
+			return err
+		}
+
+		if status, err = put(); err != nil {
+			return err
+		}
+	}
+
+	if status < 200 || status > 299 {
+		return errors.Errorf("unexpected response status '%d' for object '%s'", status, k)
+	}
+
+	return nil
+}
+
+//mkcol creates the collection 'dir' and its parents
+func (store *HTTPStore) mkcol(ctx context.Context, dir string) (err error) {
+	if dir == "." || dir == "/" {
+		return nil
+	}
+
+	if err = store.mkcol(ctx, slashpath.Dir(dir)); err != nil {
+		return err
+	}
+
+	resp, err := store.do(ctx, "MKCOL", dir, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+	case resp.StatusCode == http.StatusMethodNotAllowed: //the collection exists already
+	default:
+		return errors.Errorf("unexpected response status '%s' while creating collection '%s'", resp.Status, dir)
+	}
+
+	return nil
+}
+
+//Del removes an object from the store, objects that don't exist are not an error
+func (store *HTTPStore) Del(ctx context.Context, k string) (err error) {
+	resp, err := store.do(ctx, http.MethodDelete, k, nil, nil)
+	if err == ErrObjectNotExists {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected response status '%s'", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package transferstore_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	slashpath "path"
+
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/pkg/errors"
+)
+
+//davServer is a minimal WebDAV server that keeps objects in memory, like WebDAV servers
+//it refuses objects in collections that were not created first
+type davServer struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	cols    map[string]bool
+	user    string
+	pass    string
+}
+
+func (s *davServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if u, p, ok := r.BasicAuth(); !ok || u != s.user || p != s.pass {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := strings.TrimSuffix(r.URL.Path, "/")
+	switch r.Method {
+	case "MKCOL":
+		if s.cols[p] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		s.cols[p] = true
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut:
+		if dir := slashpath.Dir(p); dir != "/dav" && !s.cols[dir] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		d, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		s.objects[p] = d
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, ok := s.objects[p]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		delete(s.objects, p)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		d, ok := s.objects[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		http.ServeContent(w, r, p, time.Time{}, bytes.NewReader(d))
+	}
+}
+
+func TestHTTPStore(t *testing.T) {
+	ctx := context.Background()
+	dav := &davServer{objects: map[string][]byte{}, cols: map[string]bool{}, user: "alice", pass: "secret"}
+	srv := httptest.NewServer(dav)
+	defer srv.Close()
+
+	if _, err := transferstore.NewHTTPStore(transferstore.StoreOptions{HTTPStoreURL: "ftp://example.com"}); err == nil {
+		t.Fatal("expected non-http urls to be refused")
+	}
+
+	denied, err := transferstore.NewHTTPStore(transferstore.StoreOptions{HTTPStoreURL: srv.URL + "/dav"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = denied.Put(ctx, "my-object", bytes.NewReader([]byte("x"))); errors.Cause(err) != transferstore.ErrAccessDenied {
+		t.Fatalf("expected access to be denied without credentials, got: %v", err)
+	}
+
+	store, err := transferstore.NewHTTPStore(transferstore.StoreOptions{
+		HTTPStoreURL:      srv.URL + "/dav",
+		HTTPStoreUsername: "alice",
+		HTTPStorePassword: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.Head(ctx, "bogus"); err != transferstore.ErrObjectNotExists {
+		t.Fatalf("expected object not to exist, got: %v", err)
+	}
+
+	if err = store.Put(ctx, "my-prefix/sub/hello.txt", bytes.NewReader([]byte("hello, world"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if !dav.cols["/dav/my-prefix"] || !dav.cols["/dav/my-prefix/sub"] {
+		t.Fatalf("expected collections to be created, got: %v", dav.cols)
+	}
+
+	if size, err := store.Head(ctx, "my-prefix/sub/hello.txt"); err != nil || size != 12 {
+		t.Fatalf("expected size of stored object, got: %d, %v", size, err)
+	}
+
+	f, err := ioutil.TempFile("", "http_store_tests_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err = store.Get(ctx, "my-prefix/sub/hello.txt", f); err != nil {
+		t.Fatal(err)
+	}
+
+	if d, err := ioutil.ReadFile(f.Name()); err != nil || string(d) != "hello, world" {
+		t.Fatalf("unexpected content: %q, %v", d, err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err = store.GetRange(ctx, "my-prefix/sub/hello.txt", 7, 5, buf); err != nil || buf.String() != "world" {
+		t.Fatalf("expected part of the object to be read with a ranged request, got: %q, %v", buf.String(), err)
+	}
+
+	if err = store.Del(ctx, "my-prefix/sub/hello.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Del(ctx, "my-prefix/sub/hello.txt"); err != nil {
+		t.Fatalf("expected deleting an object that doesn't exist to succeed, got: %v", err)
+	}
+
+	if len(dav.objects) != 0 {
+		t.Fatalf("expected all objects to be removed, got: %d", len(dav.objects))
+	}
+}
@@ -0,0 +1,151 @@
+package transferstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	//ErrReadOnly is returned when objects are written to a store that can only be read
+	ErrReadOnly = errors.New("store is read-only")
+
+	//ErrLinkDenied is returned when a link no longer gives access to an object, eg. because it expired
+	ErrLinkDenied = errors.New("access through the link was denied, it might have expired")
+)
+
+//LinkObject is an object that can be downloaded from a URL, eg. a presigned URL of an S3 object
+type LinkObject struct {
+	URL  string `json:"url"`
+	Size int64  `json:"size"`
+}
+
+//LinkStore reads objects through links, it allows content to be downloaded without
+//credentials for the store that holds it
+type LinkStore struct {
+	objects map[string]LinkObject
+	client  *http.Client
+}
+
+//NewLinkStore creates a read-only store of 'objects'
+func NewLinkStore(objects map[string]LinkObject) *LinkStore {
+	return &LinkStore{objects: objects, client: http.DefaultClient}
+}
+
+func (store *LinkStore) object(k string) (LinkObject, error) {
+	obj, ok := store.objects[k]
+	if !ok {
+		return obj, ErrObjectNotExists
+	}
+
+	return obj, nil
+}
+
+//Head returns metadata for the object
+func (store *LinkStore) Head(ctx context.Context, k string) (size int64, err error) {
+	obj, err := store.object(k)
+	if err != nil {
+		return 0, err
+	}
+
+	return obj.Size, nil
+}
+
+//Get a object from the store with key 'k' and write it to 'w', when 'w' holds the start
+//of the object already only the remainder is downloaded
+func (store *LinkStore) Get(ctx context.Context, k string, w io.WriterAt) (err error) {
+	obj, err := store.object(k)
+	if err != nil {
+		return err
+	}
+
+	var off int64
+	if rw, ok := w.(ResumableWriterAt); ok {
+		off = rw.Written()
+	}
+
+	if off >= obj.Size {
+		return nil
+	}
+
+	return store.get(ctx, obj, off, obj.Size-off, &sequentialWriter{w: w, off: off})
+}
+
+//GetRange writes 'n' bytes of the object with key 'k', starting at offset 'off', to 'w'
+func (store *LinkStore) GetRange(ctx context.Context, k string, off, n int64, w io.Writer) (err error) {
+	obj, err := store.object(k)
+	if err != nil {
+		return err
+	}
+
+	if n < 1 {
+		return nil
+	}
+
+	return store.get(ctx, obj, off, n, w)
+}
+
+//get downloads 'n' bytes from offset 'off' of the linked object
+func (store *LinkStore) get(ctx context.Context, obj LinkObject, off, n int64, w io.Writer) (err error) {
+	req, err := http.NewRequest(http.MethodGet, obj.URL, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+
+	if off > 0 || n < obj.Size {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+	}
+
+	resp, err := store.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "failed to request object")
+	}
+
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		//the server ignored the range, skip to the offset
+		if _, err = io.CopyN(ioutil.Discard, resp.Body, off); err != nil {
+			return errors.Wrap(err, "failed to skip to offset")
+		}
+	case http.StatusPartialContent:
+	case http.StatusNotFound:
+		return ErrObjectNotExists
+	case http.StatusForbidden:
+		return ErrLinkDenied
+	default:
+		return errors.Errorf("unexpected response status '%s'", resp.Status)
+	}
+
+	if _, err = io.CopyN(w, resp.Body, n); err != nil {
+		return errors.Wrap(err, "failed to copy object")
+	}
+
+	return nil
+}
+
+//Put is not supported, the store is read-only
+func (store *LinkStore) Put(ctx context.Context, k string, r io.ReadSeeker) error {
+	return ErrReadOnly
+}
+
+//Del is not supported, the store is read-only
+func (store *LinkStore) Del(ctx context.Context, k string) error {
+	return ErrReadOnly
+}
+
+//sequentialWriter writes to a WriterAt in order, starting at 'off'
+type sequentialWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (w *sequentialWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
@@ -1,16 +1,39 @@
 package transferstore
 
+import (
+	"encoding/json"
+
+	"github.com/nerdalize/nerd/pkg/transfer/config"
+	"github.com/pkg/errors"
+)
+
 //StoreType determines what type the object store will be
 type StoreType string
 
 const (
 	//StoreTypeS3 uses a AWS S3 store
 	StoreTypeS3 StoreType = "s3"
+
+	//StoreTypeFS keeps objects as files in a local directory
+	StoreTypeFS StoreType = "fs"
+
+	//StoreTypeHTTP keeps objects on a HTTP or WebDAV server
+	StoreTypeHTTP StoreType = "http"
 )
 
-//StoreOptions contain options for all stores
+//ConfigVersion is the version of the config that the standard stores keep their options in.
+//Version 1 kept them as fields next to the type, these are still read
+const ConfigVersion = 2
+
+//standard returns whether the store is implemented in this repository
+func (typ StoreType) standard() bool {
+	return typ == StoreTypeS3 || typ == StoreTypeFS || typ == StoreTypeHTTP
+}
+
+//StoreOptions contain options for all stores, the standard stores keep them in the fields
+//which are stored as a versioned config
 type StoreOptions struct {
-	Type StoreType `json:"type"`
+	Type StoreType `json:"type,omitempty"`
 
 	S3StoreBucket    string `json:"s3StoreBucket"`
 	S3StorePrefix    string `json:"s3StorePrefix"`
@@ -18,4 +41,112 @@ type StoreOptions struct {
 	S3StoreAccessKey string `json:"s3StoreAccessKey"`
 	S3StoreSecretKey string `json:"s3StoreSecretKey"`
 	S3SessionToken   string `json:"s3SessionToken"`
+
+	//S3StoreCredentialsSecret names a secret that holds the credentials, these are
+	//then looked up when the store is setup instead of being stored with the dataset
+	S3StoreCredentialsSecret string `json:"s3StoreCredentialsSecret,omitempty"`
+
+	//S3StoreServerSideEncryption encrypts objects at rest with 'AES256' or 'aws:kms', in the
+	//latter case S3StoreSSEKMSKeyID selects the key instead of the account default
+	S3StoreServerSideEncryption string `json:"s3StoreServerSideEncryption,omitempty"`
+	S3StoreSSEKMSKeyID          string `json:"s3StoreSSEKMSKeyID,omitempty"`
+
+	//S3StoreStorageClass is the storage class objects are written with, eg. 'STANDARD_IA'
+	//for cheaper infrequent access. Datasets that are archived record their colder class
+	S3StoreStorageClass string `json:"s3StoreStorageClass,omitempty"`
+
+	//FSStoreDir is the directory that holds the objects of the file system store
+	FSStoreDir string `json:"fsStoreDir,omitempty"`
+
+	//HTTPStoreURL is the URL below which the HTTP store keeps objects, requests use basic auth
+	//with the username and password or bearer auth with the token. HTTPStoreCredentialsSecret
+	//names a secret that holds these instead such that they are not stored with the dataset
+	HTTPStoreURL               string `json:"httpStoreURL,omitempty"`
+	HTTPStoreUsername          string `json:"httpStoreUsername,omitempty"`
+	HTTPStorePassword          string `json:"httpStorePassword,omitempty"`
+	HTTPStoreToken             string `json:"httpStoreToken,omitempty"`
+	HTTPStoreCredentialsSecret string `json:"httpStoreCredentialsSecret,omitempty"`
+
+	//Config holds the options of stores that are registered outside of this repository, for
+	//the standard stores it is decoded into the fields above by LoadConfig
+	Config *transferconfig.Config `json:"config,omitempty"`
+}
+
+//plainStoreOptions is encoded without the versioned config
+type plainStoreOptions StoreOptions
+
+//MarshalJSON encodes the options of the standard stores as a versioned config
+func (o StoreOptions) MarshalJSON() ([]byte, error) {
+	if !o.Type.standard() {
+		return json.Marshal(plainStoreOptions(o))
+	}
+
+	cfg, err := o.VersionedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		Type   StoreType              `json:"type"`
+		Config *transferconfig.Config `json:"config"`
+	}{o.Type, cfg})
+}
+
+//VersionedConfig returns the config that the store is created from, the options of the standard
+//stores are encoded into it from the fields
+func (o StoreOptions) VersionedConfig() (*transferconfig.Config, error) {
+	if !o.Type.standard() {
+		return o.Config, nil
+	}
+
+	p := plainStoreOptions(o)
+	p.Type, p.Config = "", nil
+	return transferconfig.New(ConfigVersion, p)
+}
+
+//UnmarshalJSON decodes the options, options of the standard stores that were encoded
+//as fields before they were versioned are read as well
+func (o *StoreOptions) UnmarshalJSON(d []byte) error {
+	var p plainStoreOptions
+	if err := json.Unmarshal(d, &p); err != nil {
+		return err
+	}
+
+	*o = StoreOptions(p)
+	return o.LoadConfig()
+}
+
+//LoadConfig decodes the config of a standard store into the fields of the options, options
+//in the config take precedence over the fields
+func (o *StoreOptions) LoadConfig() error {
+	if !o.Type.standard() || o.Config == nil {
+		return nil
+	}
+
+	if o.Config.Version != ConfigVersion {
+		return errors.Errorf("unsupported config version %d for store '%s'", o.Config.Version, o.Type)
+	}
+
+	p := plainStoreOptions(*o)
+	if err := o.Config.Decode(&p); err != nil {
+		return err
+	}
+
+	p.Type, p.Config = o.Type, nil
+	*o = StoreOptions(p)
+	return nil
+}
+
+//DecodeStoreOptions returns the options of a standard store of type 'typ' that are decoded from 'cfg'
+func DecodeStoreOptions(typ StoreType, cfg *transferconfig.Config) (opts StoreOptions, err error) {
+	if !typ.standard() {
+		return opts, errors.Errorf("store '%s' is not a standard store", typ)
+	}
+
+	opts = StoreOptions{Type: typ, Config: cfg}
+	if err = opts.LoadConfig(); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -25,15 +26,35 @@ var (
 	//ErrObjectNotExists is returned when a object does not exist
 	ErrObjectNotExists = errors.New("object does not exist")
 
-	awsErrCodeNotFound  = "ErrCodeNoSuchKey"
-	awsErrCodeForbidden = "Forbidden"
+	//ErrObjectArchived is returned when an object is in an archive storage class, eg. 'GLACIER',
+	//and needs to be restored before it can be read
+	ErrObjectArchived = errors.New("object is archived and must be restored before it can be read")
+
+	awsErrCodeNotFound           = "ErrCodeNoSuchKey"
+	awsErrCodeForbidden          = "Forbidden"
+	awsErrCodeInvalidObjectState = "InvalidObjectState"
+	awsErrCodeRestoreInProgress  = "RestoreAlreadyInProgress"
+
+	//objects larger than this can only be copied in parts
+	s3MaxCopySize  int64 = 5 * 1024 * 1024 * 1024
+	s3CopyPartSize int64 = 512 * 1024 * 1024
 )
 
+//StorageClassArchived returns whether objects in storage class 'class' need to be restored
+//before they can be read
+func StorageClassArchived(class string) bool {
+	return class == s3.ObjectStorageClassGlacier || class == "DEEP_ARCHIVE"
+}
+
 //S3Store provides an S3 Backed store
 type S3Store struct {
 	bucket string
 	prefix string
 
+	sse          string
+	kmsKeyID     string
+	storageClass string
+
 	sess *session.Session
 	dwn  s3manageriface.DownloaderAPI
 	upl  s3manageriface.UploaderAPI
@@ -43,14 +64,27 @@ type S3Store struct {
 //NewS3Store creates an s3 implementation of the object store
 func NewS3Store(cfg StoreOptions) (store *S3Store, err error) {
 	store = &S3Store{
-		bucket: cfg.S3StoreBucket,
-		prefix: cfg.S3StorePrefix,
+		bucket:       cfg.S3StoreBucket,
+		prefix:       cfg.S3StorePrefix,
+		sse:          cfg.S3StoreServerSideEncryption,
+		kmsKeyID:     cfg.S3StoreSSEKMSKeyID,
+		storageClass: cfg.S3StoreStorageClass,
 	}
 
 	if store.prefix != "" && !strings.HasSuffix(store.prefix, "/") {
 		return nil, errors.Errorf("store prefix must end with a forward slash")
 	}
 
+	switch store.sse {
+	case "", s3.ServerSideEncryptionAes256:
+		if store.kmsKeyID != "" {
+			return nil, errors.Errorf("a KMS key requires '%s' server side encryption", s3.ServerSideEncryptionAwsKms)
+		}
+	case s3.ServerSideEncryptionAwsKms:
+	default:
+		return nil, errors.Errorf("unsupported server side encryption '%s', use '%s' or '%s'", store.sse, s3.ServerSideEncryptionAes256, s3.ServerSideEncryptionAwsKms)
+	}
+
 	if cfg.S3StoreAWSRegion == "" {
 		cfg.S3StoreAWSRegion = endpoints.UsEast1RegionID //this will make the sdk use the global s3 endpoint
 	}
@@ -86,34 +120,65 @@ func NewS3Store(cfg StoreOptions) (store *S3Store, err error) {
 
 //Head returns metadata for the object
 func (store *S3Store) Head(ctx context.Context, k string) (size int64, err error) {
-	var out *s3.HeadObjectOutput
+	out, err := store.head(ctx, k)
+	if err != nil {
+		return 0, err
+	}
+
+	size = aws.Int64Value(out.ContentLength)
+	return size, nil
+}
+
+//ETag returns the size and entity tag of the object, the tag changes whenever the object is written again
+func (store *S3Store) ETag(ctx context.Context, k string) (size int64, etag string, err error) {
+	out, err := store.head(ctx, k)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return aws.Int64Value(out.ContentLength), aws.StringValue(out.ETag), nil
+}
+
+func (store *S3Store) head(ctx context.Context, k string) (out *s3.HeadObjectOutput, err error) {
 	if out, err = store.api.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(store.bucket),
 		Key:    aws.String(k),
 	}); err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			if aerr.Code() == awsErrCodeNotFound || aerr.Code() == awsErrCodeForbidden {
-				return 0, ErrObjectNotExists
+				return nil, ErrObjectNotExists
 			}
 		}
 
-		return 0, errors.Wrapf(err, "failed to download object")
+		return nil, errors.Wrapf(err, "failed to download object")
 	}
 
-	size = aws.Int64Value(out.ContentLength)
-	return size, nil
+	return out, nil
 }
 
-//Get a object from the store with key 'k' and write it to 'w'
+//Get a object from the store with key 'k' and write it to 'w', when 'w' holds the start
+//of the object already only the remainder is downloaded
 func (store *S3Store) Get(ctx context.Context, k string, w io.WriterAt) (err error) {
-	if _, err = store.dwn.DownloadWithContext(ctx, w, &s3.GetObjectInput{
+	in := &s3.GetObjectInput{
 		Bucket: aws.String(store.bucket),
 		Key:    aws.String(k),
-	}); err != nil {
+	}
+
+	if rw, ok := w.(ResumableWriterAt); ok && rw.Written() > 0 {
+		//with a range the downloader uses a single request that writes from offset zero
+		in.Range = aws.String(fmt.Sprintf("bytes=%d-", rw.Written()))
+		w = &offsetWriterAt{WriterAt: w, off: rw.Written()}
+	}
+
+	if _, err = store.dwn.DownloadWithContext(ctx, w, in); err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			if aerr.Code() == awsErrCodeNotFound || aerr.Code() == awsErrCodeForbidden {
 				return ErrObjectNotExists
 			}
+
+			if aerr.Code() == awsErrCodeInvalidObjectState {
+				return errors.Wrapf(ErrObjectArchived, "failed to download '%s'", k)
+			}
 		}
 
 		return errors.Wrapf(err, "failed to multi-part download object")
@@ -122,13 +187,55 @@ func (store *S3Store) Get(ctx context.Context, k string, w io.WriterAt) (err err
 	return nil
 }
 
-//Put an object into the store at key 'k' by reading from 'r'
+//GetRange writes 'n' bytes of the object with key 'k', starting at offset 'off', to 'w'
+func (store *S3Store) GetRange(ctx context.Context, k string, off, n int64, w io.Writer) (err error) {
+	if n < 1 {
+		return nil //nothing to read, S3 doesn't allow empty ranges
+	}
+
+	var out *s3.GetObjectOutput
+	if out, err = store.api.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(k),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, off+n-1)),
+	}); err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			if aerr.Code() == awsErrCodeNotFound || aerr.Code() == awsErrCodeForbidden {
+				return ErrObjectNotExists
+			}
+
+			if aerr.Code() == awsErrCodeInvalidObjectState {
+				return errors.Wrapf(ErrObjectArchived, "failed to download '%s'", k)
+			}
+		}
+
+		return errors.Wrapf(err, "failed to download object range")
+	}
+
+	defer out.Body.Close()
+	if _, err = io.Copy(w, out.Body); err != nil {
+		return errors.Wrap(err, "failed to copy object range")
+	}
+
+	return nil
+}
+
+//Put an object into the store at key 'k' by reading from 'r'. Objects are never written
+//to an archive storage class directly, eg. when content is appended to an archived dataset
 func (store *S3Store) Put(ctx context.Context, k string, r io.ReadSeeker) (err error) {
+	class := store.storageClass
+	if StorageClassArchived(class) {
+		class = ""
+	}
+
 	if store.upl != nil {
 		if _, err := store.upl.UploadWithContext(ctx, &s3manager.UploadInput{
-			Body:   r,
-			Bucket: aws.String(store.bucket),
-			Key:    aws.String(k),
+			Body:                 r,
+			Bucket:               aws.String(store.bucket),
+			Key:                  aws.String(k),
+			ServerSideEncryption: optString(store.sse),
+			SSEKMSKeyId:          optString(store.kmsKeyID),
+			StorageClass:         optString(class),
 		}); err != nil {
 			return errors.Wrap(err, "failed to multi-part upload object")
 		}
@@ -137,9 +244,12 @@ func (store *S3Store) Put(ctx context.Context, k string, r io.ReadSeeker) (err e
 	}
 
 	if _, err := store.api.PutObjectWithContext(ctx, &s3.PutObjectInput{
-		Body:   r,
-		Bucket: aws.String(store.bucket),
-		Key:    aws.String(k),
+		Body:                 r,
+		Bucket:               aws.String(store.bucket),
+		Key:                  aws.String(k),
+		ServerSideEncryption: optString(store.sse),
+		SSEKMSKeyId:          optString(store.kmsKeyID),
+		StorageClass:         optString(class),
 	}); err != nil {
 		return errors.Wrap(err, "failed to upload object")
 	}
@@ -147,6 +257,153 @@ func (store *S3Store) Put(ctx context.Context, k string, r io.ReadSeeker) (err e
 	return nil
 }
 
+//optString returns nil for empty strings such that the bucket defaults apply
+func optString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return aws.String(s)
+}
+
+//SetStorageClass moves the object at key 'k' to storage class 'class' by copying it onto
+//itself, it is encrypted the same way as new objects. Objects that are archived already
+//need to be restored first
+func (store *S3Store) SetStorageClass(ctx context.Context, k string, class string) (err error) {
+	out, err := store.head(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	if aws.StringValue(out.StorageClass) == class || (class == s3.StorageClassStandard && out.StorageClass == nil) {
+		return nil //already in the class
+	}
+
+	if StorageClassArchived(aws.StringValue(out.StorageClass)) && !strings.Contains(aws.StringValue(out.Restore), `ongoing-request="false"`) {
+		return errors.Wrapf(ErrObjectArchived, "failed to move '%s'", k)
+	}
+
+	src := aws.String(store.bucket + "/" + k)
+	size := aws.Int64Value(out.ContentLength)
+	if size <= s3MaxCopySize {
+		if _, err = store.api.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+			Bucket:               aws.String(store.bucket),
+			Key:                  aws.String(k),
+			CopySource:           src,
+			MetadataDirective:    aws.String(s3.MetadataDirectiveCopy),
+			ServerSideEncryption: optString(store.sse),
+			SSEKMSKeyId:          optString(store.kmsKeyID),
+			StorageClass:         aws.String(class),
+		}); err != nil {
+			return errors.Wrap(err, "failed to copy object")
+		}
+
+		return nil
+	}
+
+	//large objects are copied in parts by a multipart upload
+	up, err := store.api.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(store.bucket),
+		Key:                  aws.String(k),
+		ServerSideEncryption: optString(store.sse),
+		SSEKMSKeyId:          optString(store.kmsKeyID),
+		StorageClass:         aws.String(class),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to start multi-part copy")
+	}
+
+	parts := []*s3.CompletedPart{}
+	for off, n := int64(0), int64(1); off < size; off, n = off+s3CopyPartSize, n+1 {
+		end := off + s3CopyPartSize - 1
+		if end >= size {
+			end = size - 1
+		}
+
+		var pout *s3.UploadPartCopyOutput
+		if pout, err = store.api.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+			Bucket:          aws.String(store.bucket),
+			Key:             aws.String(k),
+			CopySource:      src,
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", off, end)),
+			PartNumber:      aws.Int64(n),
+			UploadId:        up.UploadId,
+		}); err != nil {
+			store.api.AbortMultipartUploadWithContext(context.Background(), &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(store.bucket),
+				Key:      aws.String(k),
+				UploadId: up.UploadId,
+			})
+
+			return errors.Wrap(err, "failed to copy object part")
+		}
+
+		parts = append(parts, &s3.CompletedPart{ETag: pout.CopyPartResult.ETag, PartNumber: aws.Int64(n)})
+	}
+
+	if _, err = store.api.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(store.bucket),
+		Key:             aws.String(k),
+		UploadId:        up.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	}); err != nil {
+		return errors.Wrap(err, "failed to complete multi-part copy")
+	}
+
+	return nil
+}
+
+//Restore makes an archived object readable for 'days' days, it returns whether the object
+//can be read already. Restores take hours to complete and can be started again without harm
+func (store *S3Store) Restore(ctx context.Context, k string, days int64) (ready bool, err error) {
+	out, err := store.head(ctx, k)
+	if err != nil {
+		return false, err
+	}
+
+	if !StorageClassArchived(aws.StringValue(out.StorageClass)) {
+		return true, nil
+	}
+
+	restore := aws.StringValue(out.Restore)
+	if strings.Contains(restore, `ongoing-request="false"`) {
+		return true, nil
+	}
+
+	if strings.Contains(restore, `ongoing-request="true"`) {
+		return false, nil
+	}
+
+	if _, err = store.api.RestoreObjectWithContext(ctx, &s3.RestoreObjectInput{
+		Bucket:         aws.String(store.bucket),
+		Key:            aws.String(k),
+		RestoreRequest: &s3.RestoreRequest{Days: aws.Int64(days)},
+	}); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == awsErrCodeRestoreInProgress {
+			return false, nil
+		}
+
+		return false, errors.Wrap(err, "failed to restore object")
+	}
+
+	return false, nil
+}
+
+//Presign returns a URL that allows the object at key 'k' to be downloaded without credentials
+//until it expires, S3 doesn't allow links that are valid for longer than 7 days
+func (store *S3Store) Presign(k string, expires time.Duration) (u string, err error) {
+	req, _ := store.api.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(k),
+	})
+
+	if u, err = req.Presign(expires); err != nil {
+		return "", errors.Wrap(err, "failed to presign object request")
+	}
+
+	return u, nil
+}
+
 //Del will remove an object from the store at key 'k'
 func (store *S3Store) Del(ctx context.Context, k string) error {
 	if _, err := store.api.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
@@ -165,6 +422,31 @@ func (store *S3Store) Del(ctx context.Context, k string) error {
 	return nil
 }
 
+//List calls 'fn' for every object with a key that starts with 'prefix'
+func (store *S3Store) List(ctx context.Context, prefix string, fn func(k string, size int64, modTime time.Time) error) (err error) {
+	var ferr error
+	if err = store.api.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(store.bucket),
+		Prefix: aws.String(prefix),
+	}, func(out *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range out.Contents {
+			if ferr = fn(aws.StringValue(obj.Key), aws.Int64Value(obj.Size), aws.TimeValue(obj.LastModified)); ferr != nil {
+				return false
+			}
+		}
+
+		return true
+	}); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchBucket {
+			return ErrObjectNotExists
+		}
+
+		return errors.Wrap(err, "failed to list objects")
+	}
+
+	return ferr
+}
+
 //TempS3Bucket creates a temporary s3 bucket that can be removed again
 //by calling clean(). This is mainly usefull for testing purposes throughout
 //the codebase of this project. The name will be a randomly generated name
@@ -0,0 +1,25 @@
+package transferstore
+
+import (
+	"io"
+)
+
+//ResumableWriterAt is implemented by writers that already hold the start of an object, eg.
+//because an earlier download was interrupted. Stores only retrieve the remainder for them
+type ResumableWriterAt interface {
+	io.WriterAt
+
+	//Written returns the number of bytes at the start of the object that are present
+	Written() int64
+}
+
+//offsetWriterAt writes at a fixed offset of the underlying writer, it allows the
+//remainder of an object to be written after the part that is present already
+type offsetWriterAt struct {
+	io.WriterAt
+	off int64
+}
+
+func (w *offsetWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	return w.WriterAt.WriteAt(p, w.off+off)
+}
@@ -2,9 +2,9 @@ package transfer
 
 import (
 	"context"
-	"errors"
 	"io"
 	"io/ioutil"
+	"time"
 
 	"github.com/nerdalize/nerd/pkg/transfer/archiver"
 	"github.com/nerdalize/nerd/pkg/transfer/store"
@@ -66,10 +66,36 @@ func (r *DiscardReporter) StopUnarchivingProgress() {}
 type Store interface {
 	Head(ctx context.Context, k string) (size int64, err error)
 	Get(ctx context.Context, key string, w io.WriterAt) error
+	GetRange(ctx context.Context, key string, off, n int64, w io.Writer) error
 	Put(ctx context.Context, key string, r io.ReadSeeker) error
 	Del(ctx context.Context, key string) error
 }
 
+//Lister is implemented by stores that can enumerate their objects
+type Lister interface {
+	List(ctx context.Context, prefix string, fn func(k string, size int64, modTime time.Time) error) error
+}
+
+//ETagger is implemented by stores that can tell the version of an object, the tag
+//changes whenever the object is written again. The size is returned as well such that
+//both are known from a single request
+type ETagger interface {
+	ETag(ctx context.Context, k string) (size int64, etag string, err error)
+}
+
+//StorageClasser is implemented by stores that keep objects in storage classes with different
+//costs, objects in archive classes need to be restored before they can be read again
+type StorageClasser interface {
+	SetStorageClass(ctx context.Context, k string, class string) error
+	Restore(ctx context.Context, k string, days int64) (ready bool, err error)
+}
+
+//Presigner is implemented by stores that can create links to objects, these allow objects
+//to be downloaded without credentials until the link expires
+type Presigner interface {
+	Presign(k string, expires time.Duration) (url string, err error)
+}
+
 //A Handle provides interactions with a dataset
 type Handle interface {
 	io.Closer
@@ -77,6 +103,16 @@ type Handle interface {
 	Clear(ctx context.Context, reporter Reporter) error
 	Push(ctx context.Context, fromPath string, rep Reporter) error
 	Pull(ctx context.Context, toPath string, rep Reporter) error
+	PullPaths(ctx context.Context, toPath string, paths []string, rep Reporter) error
+	PushTar(ctx context.Context, r io.Reader, rep Reporter) error
+	Append(ctx context.Context, fromPath string, rep Reporter) error
+	PullTar(ctx context.Context, w io.Writer, rep Reporter) error
+	Contents(ctx context.Context) (*transferarchiver.TOC, error)
+	ReadFile(ctx context.Context, p string, w io.Writer) error
+	Verify(ctx context.Context, rep Reporter) ([]Mismatch, error)
+	Transition(ctx context.Context, class string, rep Reporter) error
+	Restore(ctx context.Context, days int64, rep Reporter) (ready bool, err error)
+	Share(ctx context.Context, expires time.Duration) (link string, err error)
 }
 
 //Manager provides access to Transfer handles, this allows parallel
@@ -88,29 +124,16 @@ type Manager interface {
 	Info(ctx context.Context, name string) (size uint64, err error)
 }
 
-//Archiver allows archiving a directory
+//Archiver allows archiving a directory. Index only calls 'fn' for the objects that are known
+//without reading any of them, archivers that list further objects in those, eg. parts or
+//files, must implement PartIndex as well or the objects are left behind when the dataset
+//is cleared, shared or transitioned
 type Archiver interface {
 	Index(fn func(k string) error) error
+	Contents(ctx context.Context, fn func(k string, w io.WriterAt) error) (*transferarchiver.TOC, error)
 	Archive(ctx context.Context, path string, rep transferarchiver.Reporter, fn func(k string, r io.ReadSeeker, nbytes int64) error) error
+	ArchiveTar(ctx context.Context, r io.Reader, rep transferarchiver.Reporter, fn func(k string, r io.ReadSeeker, nbytes int64) error) error
+	Append(ctx context.Context, path string, rep transferarchiver.Reporter, get func(k string, w io.WriterAt) error, fn func(k string, r io.ReadSeeker, nbytes int64) error) (layer string, err error)
 	Unarchive(ctx context.Context, path string, rep transferarchiver.Reporter, fn func(k string, w io.WriterAt) error) error
-}
-
-//CreateArchiver will creates one of the standard storews with the provided options
-func CreateArchiver(opts transferarchiver.ArchiverOptions) (Archiver, error) {
-	switch opts.Type {
-	case transferarchiver.ArchiverTypeTar:
-		return transferarchiver.NewTarArchiver(opts)
-	default:
-		return nil, errors.New("unsupported archiver")
-	}
-}
-
-//CreateStore will creates of the standard stores based on the store options
-func CreateStore(opts transferstore.StoreOptions) (Store, error) {
-	switch opts.Type {
-	case transferstore.StoreTypeS3:
-		return transferstore.NewS3Store(opts)
-	default:
-		return nil, errors.New("unsupported store")
-	}
+	UnarchiveTar(ctx context.Context, w io.Writer, rep transferarchiver.Reporter, fn func(k string, w io.WriterAt) error) error
 }
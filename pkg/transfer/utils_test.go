@@ -0,0 +1,52 @@
+package transfer_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+)
+
+//testSource creates a temporary directory with a 'src' directory in it that holds
+//a file at 'name' with the content "hello, world"
+func testSource(tb testing.TB, name string) (dir, src string, clean func()) {
+	dir, err := ioutil.TempDir("", "transfer_tests_")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	src = filepath.Join(dir, "src")
+	if err = os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0700); err != nil {
+		tb.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(src, name), []byte("hello, world"), 0600); err != nil {
+		tb.Fatal(err)
+	}
+
+	return dir, src, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+//testFSStore creates a store that keeps its objects in the 'objects' directory of 'dir'
+func testFSStore(tb testing.TB, dir string) (opts transferstore.StoreOptions, store *transferstore.FSStore) {
+	opts = transferstore.StoreOptions{Type: transferstore.StoreTypeFS, FSStoreDir: filepath.Join(dir, "objects")}
+	store, err := transferstore.NewFSStore(opts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return opts, store
+}
+
+//testPush pushes the content of 'src' to the dataset of handle 'h'
+func testPush(tb testing.TB, h transfer.Handle, src string) {
+	if err := h.Push(context.Background(), src, transfer.NewDiscardReporter()); err != nil {
+		tb.Fatal(err)
+	}
+}
@@ -0,0 +1,254 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/pkg/errors"
+)
+
+//Mismatch describes an object, or a file inside it, whose content does not match the
+//digest that was recorded when it was pushed
+type Mismatch struct {
+	Key      string //key of the object
+	Path     string //path of the file in the dataset, empty when the object as a whole mismatches
+	Expected string
+	Actual   string
+}
+
+//Verify downloads every object of the dataset and checks it against the digest that was recorded
+//when it was pushed, files are checked against the digests in the table of contents. Nothing is
+//extracted, the objects are only streamed through. It returns what did not match
+func (h *StdHandle) Verify(ctx context.Context, rep Reporter) (mismatches []Mismatch, err error) {
+	toc, err := h.Contents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]transferarchiver.TOCEntry{}
+	for _, e := range toc.Entries {
+		if e.Mode.IsRegular() && e.SHA256 != "" && e.Key != "" {
+			files[e.Key] = append(files[e.Key], e)
+		}
+	}
+
+	keys := []string{}
+	seen := map[string]bool{}
+	add := func(k string) error {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+
+		return nil
+	}
+
+	if err = h.archiver.Index(add); err != nil {
+		return nil, errors.Wrap(err, "failed to index objects")
+	}
+
+	if pi, ok := h.archiver.(partIndexer); ok {
+		if err = pi.PartIndex(ctx, h.getQuiet(ctx), add); err != nil {
+			return nil, errors.Wrap(err, "failed to index parts")
+		}
+	}
+
+	for k := range files {
+		add(k) //eg. referenced objects that are not part of the index
+	}
+
+	for _, k := range keys {
+		mm, err := h.verifyObject(ctx, k, files[k], rep)
+		if err != nil {
+			return nil, err
+		}
+
+		mismatches = append(mismatches, mm...)
+	}
+
+	return mismatches, nil
+}
+
+//verifyObject streams the object with key 'k' to check its digest and those of the 'files' inside it
+func (h *StdHandle) verifyObject(ctx context.Context, k string, files []transferarchiver.TOCEntry, rep Reporter) (mismatches []Mismatch, err error) {
+	total, err := h.store.Head(ctx, k)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get metadata of '%s'", k)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Offset < files[j].Offset })
+	vw := &verifyWriter{key: k, object: sha256.New(), files: files}
+
+	pw := rep.StartDownloadProgress(k, total)
+	defer rep.StopDownloadProgress()
+	if total > 0 {
+		if err = h.store.GetRange(ctx, k, 0, total, io.MultiWriter(vw, pw)); err != nil {
+			return nil, errors.Wrapf(err, "failed to get '%s'", k)
+		}
+	}
+
+	vw.finish()
+	expected, ok, err := h.expectedDigest(k)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		if actual := hex.EncodeToString(vw.object.Sum(nil)); actual != expected {
+			vw.mismatches = append([]Mismatch{{Key: k, Expected: expected, Actual: actual}}, vw.mismatches...)
+		}
+	}
+
+	rep.HandledKey(k)
+	return vw.mismatches, nil
+}
+
+//verifyWriter hashes the object that is written to it as a whole, and separately the files
+//at their offsets inside of it
+type verifyWriter struct {
+	key    string
+	object hash.Hash
+	pos    int64
+
+	files      []transferarchiver.TOCEntry //sorted by offset
+	file       hash.Hash
+	mismatches []Mismatch
+}
+
+func (w *verifyWriter) Write(p []byte) (n int, err error) {
+	w.object.Write(p)
+	n = len(p)
+	for len(w.files) > 0 {
+		e := w.files[0]
+		if w.pos < e.Offset { //bytes before the file, eg. tar headers
+			skip := e.Offset - w.pos
+			if skip > int64(len(p)) {
+				break
+			}
+
+			p, w.pos = p[skip:], e.Offset
+		}
+
+		take := e.Offset + e.Size - w.pos
+		if take > int64(len(p)) {
+			take = int64(len(p))
+		}
+
+		if w.file == nil {
+			w.file = sha256.New()
+		}
+
+		w.file.Write(p[:take])
+		p, w.pos = p[take:], w.pos+take
+		if w.pos < e.Offset+e.Size {
+			break //file continues in the next write
+		}
+
+		w.check(hex.EncodeToString(w.file.Sum(nil)))
+	}
+
+	w.pos += int64(len(p))
+	return n, nil
+}
+
+//check compares the digest of the current file and moves on to the next
+func (w *verifyWriter) check(actual string) {
+	e := w.files[0]
+	if actual != e.SHA256 {
+		w.mismatches = append(w.mismatches, Mismatch{Key: w.key, Path: e.Path, Expected: e.SHA256, Actual: actual})
+	}
+
+	w.files, w.file = w.files[1:], nil
+}
+
+//finish reports files that the object ended before, eg. because it was truncated
+func (w *verifyWriter) finish() {
+	for len(w.files) > 0 {
+		if e := w.files[0]; e.Size == 0 && e.Offset <= w.pos {
+			w.check(hex.EncodeToString(sha256.New().Sum(nil))) //empty file at the very end
+			continue
+		}
+
+		w.check("")
+	}
+}
+
+//digestWriter hashes an object while it is written to the wrapped writer. Stores may write
+//ranges out of order, eg. when downloading concurrently, ranges beyond what was hashed are
+//held until the bytes before them were written
+type digestWriter struct {
+	io.WriterAt
+	key      string
+	expected string
+
+	mu      sync.Mutex
+	hash    hash.Hash
+	pos     int64
+	pending map[int64][]byte
+}
+
+func newDigestWriter(k string, w io.WriterAt) *digestWriter {
+	return &digestWriter{WriterAt: w, key: k, hash: sha256.New(), pending: map[int64][]byte{}}
+}
+
+func (w *digestWriter) WriteAt(p []byte, off int64) (n int, err error) {
+	n, err = w.WriterAt.WriteAt(p, off)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if off > w.pos {
+		if n > len(w.pending[off]) {
+			w.pending[off] = append([]byte(nil), p[:n]...)
+		}
+
+		return n, err
+	}
+
+	w.add(p[:n], off)
+	for progressed := true; progressed; {
+		progressed = false
+		for poff, pp := range w.pending {
+			if poff <= w.pos {
+				delete(w.pending, poff)
+				w.add(pp, poff)
+				progressed = true
+			}
+		}
+	}
+
+	return n, err
+}
+
+//add hashes the bytes of 'p', written at 'off', that were not hashed yet
+func (w *digestWriter) add(p []byte, off int64) {
+	if end := off + int64(len(p)); end > w.pos {
+		w.hash.Write(p[w.pos-off:])
+		w.pos = end
+	}
+}
+
+//check compares the digest of what was written with the expected one, nothing is
+//checked when no digest is expected
+func (w *digestWriter) check() error {
+	if w.expected == "" {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) > 0 {
+		return errors.Wrapf(ErrDigestMismatch, "object '%s' was not written completely", w.key)
+	}
+
+	if actual := hex.EncodeToString(w.hash.Sum(nil)); actual != w.expected {
+		return errors.Wrapf(ErrDigestMismatch, "object '%s' has digest %s instead of %s", w.key, actual, w.expected)
+	}
+
+	return nil
+}
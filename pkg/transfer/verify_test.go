@@ -0,0 +1,146 @@
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	transfer "github.com/nerdalize/nerd/pkg/transfer"
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/pkg/errors"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, src, clean := testSource(t, "hello.txt")
+	defer clean()
+
+	sto, store := testFSStore(t, dir)
+	objects := sto.FSStoreDir
+	if err := ioutil.WriteFile(filepath.Join(src, "empty.txt"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, partSize := range []int64{0, 4096} {
+		a, err := transferarchiver.NewTarArchiver(transferarchiver.ArchiverOptions{TarArchiverPartSize: partSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := transfer.CreateStdHandle("my-dataset", store, a, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testPush(t, h, src)
+		mismatches, err := h.Verify(ctx, rep)
+		if err != nil || len(mismatches) != 0 {
+			t.Fatalf("expected pushed content to verify, got: %v, %v", mismatches, err)
+		}
+
+		//change a single byte of the stored file content
+		if err = filepath.Walk(objects, func(p string, fi os.FileInfo, err error) error {
+			if err != nil || !fi.Mode().IsRegular() {
+				return err
+			}
+
+			d, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+
+			if i := bytes.Index(d, []byte("hello, world")); i >= 0 {
+				d[i] = 'j'
+				return ioutil.WriteFile(p, d, 0600)
+			}
+
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		mismatches, err = h.Verify(ctx, rep)
+		if err != nil || len(mismatches) != 2 || mismatches[0].Path != "" || mismatches[1].Path != "hello.txt" {
+			t.Fatalf("expected the object and file to mismatch with part size %d, got: %v, %v", partSize, mismatches, err)
+		}
+
+		if err = h.Pull(ctx, filepath.Join(dir, "dst"), rep); errors.Cause(err) != transfer.ErrDigestMismatch {
+			t.Fatalf("expected pull of changed content to fail, got: %v", err)
+		}
+
+		if err = os.RemoveAll(objects); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = os.RemoveAll(filepath.Join(dir, "dst")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+//reverseStore writes objects back to front in small ranges, like concurrent downloads may
+type reverseStore struct {
+	*transferstore.FSStore
+}
+
+func (s *reverseStore) Get(ctx context.Context, k string, w io.WriterAt) error {
+	buf := &bytes.Buffer{}
+	if err := s.FSStore.Get(ctx, k, &sequentialBuffer{buf}); err != nil {
+		return err
+	}
+
+	d := buf.Bytes()
+	for end := len(d); end > 0; end -= 3 {
+		start := end - 3
+		if start < 0 {
+			start = 0
+		}
+
+		if _, err := w.WriteAt(d[start:end], int64(start)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//sequentialBuffer collects what is written in order
+type sequentialBuffer struct{ *bytes.Buffer }
+
+func (b *sequentialBuffer) WriteAt(p []byte, off int64) (int, error) { return b.Write(p) }
+
+func TestVerifyWhileStreaming(t *testing.T) {
+	ctx := context.Background()
+	rep := transfer.NewDiscardReporter()
+
+	dir, src, clean := testSource(t, "hello.txt")
+	defer clean()
+
+	_, fs := testFSStore(t, dir)
+	a, err := transferarchiver.NewTarArchiver(transferarchiver.ArchiverOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := transfer.CreateStdHandle("my-dataset", &reverseStore{fs}, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPush(t, h, src)
+	if err = h.Pull(ctx, filepath.Join(dir, "dst"), rep); err != nil {
+		t.Fatalf("expected content that is written out of order to verify, got: %v", err)
+	}
+
+	h.ExpectDigests(map[string]string{"other-object": "aa"})
+	if err = h.PullTar(ctx, ioutil.Discard, rep); errors.Cause(err) != transfer.ErrDigestMissing {
+		t.Fatalf("expected pull without a recorded digest to fail, got: %v", err)
+	}
+}
@@ -38,3 +38,19 @@ func IsDatasetSpecErr(err error) bool {
 	te, ok := err.(iface)
 	return ok && te.IsDatasetSpec()
 }
+
+type errAttemptNotExists struct{ error }
+
+func (e errAttemptNotExists) IsAttemptNotExists() bool { return true }
+
+//IsNotExists makes the error recognized by kubevisor.IsNotExistsErr, like jobs that don't exist
+func (e errAttemptNotExists) IsNotExists() bool { return true }
+
+//IsAttemptNotExistsErr is returned when the logs of an attempt are requested that has no pod
+func IsAttemptNotExistsErr(err error) bool {
+	type iface interface {
+		IsAttemptNotExists() bool
+	}
+	te, ok := err.(iface)
+	return ok && te.IsAttemptNotExists()
+}
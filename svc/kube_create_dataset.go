@@ -35,6 +35,7 @@ func (k *Kube) CreateDataset(ctx context.Context, in *CreateDatasetInput) (out *
 		ObjectMeta: metav1.ObjectMeta{},
 		Spec: datasetsv1.DatasetSpec{
 			Size:            in.Size,
+			State:           datasetsv1.DatasetStateCreating,
 			StoreOptions:    in.StoreOptions,
 			ArchiverOptions: in.ArchiverOptions,
 		},
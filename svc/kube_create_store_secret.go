@@ -0,0 +1,68 @@
+package svc
+
+import (
+	"context"
+
+	"github.com/nerdalize/nerd/pkg/kubevisor"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//StoreSecretAccessKey is the secret data key that holds the access key of a store
+	StoreSecretAccessKey = "accessKey"
+	//StoreSecretSecretKey is the secret data key that holds the secret key of a store
+	StoreSecretSecretKey = "secretKey"
+	//StoreSecretSessionToken is the secret data key that holds an optional session token
+	StoreSecretSessionToken = "sessionToken"
+	//StoreSecretUsername is the secret data key that holds the username for basic auth with a HTTP store
+	StoreSecretUsername = "username"
+	//StoreSecretPassword is the secret data key that holds the password for basic auth with a HTTP store
+	StoreSecretPassword = "password"
+	//StoreSecretToken is the secret data key that holds the token for bearer auth with a HTTP store
+	StoreSecretToken = "token"
+)
+
+//CreateStoreSecretInput is the input to CreateStoreSecret
+type CreateStoreSecretInput struct {
+	AccessKey    string `validate:"required"`
+	SecretKey    string `validate:"required"`
+	SessionToken string
+}
+
+//CreateStoreSecretOutput is the output to CreateStoreSecret
+type CreateStoreSecretOutput struct {
+	Name string
+}
+
+//CreateStoreSecret will create a secret on kubernetes that holds credentials for a dataset store
+func (k *Kube) CreateStoreSecret(ctx context.Context, in *CreateStoreSecretInput) (out *CreateStoreSecretOutput, err error) {
+	if err = k.checkInput(ctx, in); err != nil {
+		return nil, err
+	}
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"store": "true"},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			StoreSecretAccessKey: []byte(in.AccessKey),
+			StoreSecretSecretKey: []byte(in.SecretKey),
+		},
+	}
+
+	if in.SessionToken != "" {
+		secret.Data[StoreSecretSessionToken] = []byte(in.SessionToken)
+	}
+
+	err = k.visor.CreateResource(ctx, kubevisor.ResourceTypeSecrets, secret, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateStoreSecretOutput{
+		Name: secret.Name,
+	}, nil
+}
@@ -0,0 +1,215 @@
+package svc
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nerdalize/nerd/pkg/kubevisor"
+	"github.com/pkg/errors"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+//secretEnvNames are parts of environment variable names that hint at a secret value
+var secretEnvNames = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "KEY", "CREDENTIAL"}
+
+//DescribeJobInput is the input to DescribeJob
+type DescribeJobInput struct {
+	Name string `validate:"min=1,printascii"`
+	Tail int64  `validate:"min=0"`
+}
+
+//JobEnv is an environment variable of a job, values that are secret are not returned
+type JobEnv struct {
+	Name   string
+	Value  string
+	Secret bool
+}
+
+//JobAttempt describes one of the pods that was created to run a job
+type JobAttempt struct {
+	Name       string
+	Node       string
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Restarts   int32
+	Details    JobDetails
+}
+
+//DescribeJobOutput is the output to DescribeJob
+type DescribeJobOutput struct {
+	ListJobItem
+
+	Command      []string
+	Args         []string
+	Env          []JobEnv
+	Volumes      []JobVolume
+	BackoffLimit int32
+	Attempts     []JobAttempt //oldest first
+	Events       []JobEvent   //of the job and its pods, oldest first
+	Logs         []byte       //of the last attempt that has logs
+}
+
+//DescribeJob returns the spec of a job, every attempt at running it, related events and the tail of its logs
+func (k *Kube) DescribeJob(ctx context.Context, in *DescribeJobInput) (out *DescribeJobOutput, err error) {
+	if err = k.checkInput(ctx, in); err != nil {
+		return nil, err
+	}
+
+	job := &batchv1.Job{}
+	err = k.visor.GetResource(ctx, kubevisor.ResourceTypeJobs, job, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(job.Spec.Template.Spec.Containers) != 1 {
+		return nil, errors.Errorf("job '%s' does not have exactly one container", in.Name)
+	}
+
+	pods := &pods{}
+	err = k.visor.ListResources(ctx, kubevisor.ResourceTypePods, pods, []string{"controller-uid=" + string(job.GetUID())}, []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	events := &events{}
+	err = k.visor.ListResources(ctx, kubevisor.ResourceTypeEvents, events, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c := job.Spec.Template.Spec.Containers[0]
+	out = &DescribeJobOutput{
+		ListJobItem: *listJobItem(job),
+		Command:     c.Command,
+		Args:        c.Args,
+	}
+
+	if job.Spec.BackoffLimit != nil {
+		out.BackoffLimit = *job.Spec.BackoffLimit
+	}
+
+	for _, env := range c.Env {
+		out.Env = append(out.Env, jobEnv(env))
+	}
+
+	mounts := map[string]string{}
+	for _, m := range c.VolumeMounts {
+		mounts[m.Name] = m.MountPath
+	}
+
+	for _, vol := range job.Spec.Template.Spec.Volumes {
+		if vol.FlexVolume == nil || vol.FlexVolume.Options["logs/dataset"] != "" {
+			continue //the logs volume is not mounted
+		}
+
+		out.Volumes = append(out.Volumes, JobVolume{
+			MountPath:     mounts[vol.Name],
+			InputDataset:  vol.FlexVolume.Options["input/dataset"],
+			OutputDataset: vol.FlexVolume.Options["output/dataset"],
+		})
+	}
+
+	sortAttempts(pods.Items)
+
+	//events refer to the job and its pods by their prefixed name, the uid tells which they are about
+	names := map[types.UID]string{job.UID: job.Name}
+	for _, pod := range pods.Items {
+		names[pod.UID] = pod.Name
+		out.Attempts = append(out.Attempts, jobAttempt(&pod))
+	}
+
+	//the details of the job are those of its last attempt, like when jobs are listed
+	if n := len(out.Attempts); n > 0 {
+		parr := out.Details.Parallelism
+		out.Details = out.Attempts[n-1].Details
+		out.Details.Parallelism = parr
+	}
+
+	for _, ev := range events.Items {
+		name, ok := names[ev.InvolvedObject.UID]
+		if !ok {
+			continue
+		}
+
+		jev := JobEvent{
+			Message: ev.Message,
+			Type:    ev.Type,
+			Reason:  ev.Reason,
+			Object:  ev.InvolvedObject.Kind + "/" + name,
+			Count:   ev.Count,
+			FirstAt: ev.FirstTimestamp.Local(),
+			LastAt:  ev.LastTimestamp.Local(),
+		}
+
+		out.Events = append(out.Events, jev)
+		if ev.InvolvedObject.Kind == "Job" && ev.Reason == "FailedCreate" {
+			out.Details.FailedCreateEvents = append(out.Details.FailedCreateEvents, jev)
+		}
+	}
+
+	sort.Slice(out.Events, func(i int, j int) bool {
+		return out.Events[i].LastAt.Before(out.Events[j].LastAt)
+	})
+
+	buf := bytes.NewBuffer(nil)
+	err = k.fetchLatestLogs(ctx, kubevisor.LogOptions{Tail: in.Tail, Limit: kubevisor.MaxLogBytes + 1}, pods.Items, &pageWriter{w: buf, limit: kubevisor.MaxLogBytes})
+	if err != nil && err != errPageFull {
+		return nil, err
+	}
+
+	out.Logs = buf.Bytes()
+	return out, nil
+}
+
+//jobEnv describes an environment variable, values that come from secrets or that have a
+//name that suggests a secret are masked
+func jobEnv(env corev1.EnvVar) JobEnv {
+	if env.ValueFrom != nil {
+		return JobEnv{Name: env.Name, Secret: env.ValueFrom.SecretKeyRef != nil}
+	}
+
+	name := strings.ToUpper(env.Name)
+	for _, s := range secretEnvNames {
+		if strings.Contains(name, s) {
+			return JobEnv{Name: env.Name, Secret: true}
+		}
+	}
+
+	return JobEnv{Name: env.Name, Value: env.Value}
+}
+
+//jobAttempt describes the pod that attempted to run a job
+func jobAttempt(pod *corev1.Pod) JobAttempt {
+	a := JobAttempt{
+		Name:      pod.Name,
+		Node:      pod.Spec.NodeName,
+		CreatedAt: pod.CreationTimestamp.Local(),
+	}
+
+	a.Details.SeenAt = a.CreatedAt
+	podDetails(pod, &a.Details)
+	for _, cstatus := range pod.Status.ContainerStatuses {
+		if cstatus.Name != "main" {
+			continue
+		}
+
+		a.Restarts = cstatus.RestartCount
+		if cstatus.State.Running != nil {
+			a.StartedAt = cstatus.State.Running.StartedAt.Local()
+		}
+
+		if cstatus.State.Terminated != nil {
+			a.StartedAt = cstatus.State.Terminated.StartedAt.Local()
+			a.FinishedAt = cstatus.State.Terminated.FinishedAt.Local()
+		}
+	}
+
+	return a
+}
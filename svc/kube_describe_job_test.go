@@ -0,0 +1,152 @@
+package svc_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/nerdalize/nerd/pkg/kubevisor"
+	"github.com/nerdalize/nerd/svc"
+)
+
+func TestDescribeJob(t *testing.T) {
+	for _, c := range []struct {
+		Name     string
+		Timeout  time.Duration
+		Jobs     []*svc.RunJobInput
+		Input    *svc.DescribeJobInput
+		IsOutput func(tb testing.TB, out *svc.DescribeJobOutput) bool
+		IsErr    func(error) bool
+	}{
+		{
+			Name:    "when a zero value input is provided it should return a validation error",
+			Timeout: time.Second * 5,
+			Jobs:    nil,
+			Input:   nil,
+			IsErr:   svc.IsValidationErr,
+			IsOutput: func(t testing.TB, out *svc.DescribeJobOutput) bool {
+				return true
+			},
+		},
+		{
+			Name:    "when job doesnt exist it should return a not exists error",
+			Timeout: time.Second * 5,
+			Input:   &svc.DescribeJobInput{Name: "my-job"},
+			IsErr:   kubevisor.IsNotExistsErr,
+			IsOutput: func(t testing.TB, out *svc.DescribeJobOutput) bool {
+				return true
+			},
+		},
+		{
+			Name:    "when a job is run it should describe its spec with secret values masked",
+			Timeout: time.Second * 5,
+			Jobs: []*svc.RunJobInput{{
+				Image:   "hello-world",
+				Name:    "my-job",
+				Args:    []string{"a", "b"},
+				Env:     map[string]string{"FOO": "bar", "API_TOKEN": "my-token"},
+				Volumes: []svc.JobVolume{{MountPath: "/input", InputDataset: "my-dataset"}},
+			}},
+			Input: &svc.DescribeJobInput{Name: "my-job"},
+			IsErr: isNilErr,
+			IsOutput: func(t testing.TB, out *svc.DescribeJobOutput) bool {
+				equals(t, "my-job", out.Name)
+				equals(t, "hello-world", out.Image)
+				equals(t, []string{"a", "b"}, out.Args)
+				equals(t, []svc.JobVolume{{MountPath: "/input", InputDataset: "my-dataset"}}, out.Volumes)
+				equals(t, 2, len(out.Env))
+				for _, env := range out.Env {
+					switch env.Name {
+					case "FOO":
+						assert(t, !env.Secret && env.Value == "bar", "expected regular value to be shown")
+					case "API_TOKEN":
+						assert(t, env.Secret && env.Value == "", "expected secret value to be masked")
+					}
+				}
+
+				return true
+			},
+		},
+		{
+			Name:    "when a job is run with a logs dataset it should link to it without showing it as a volume",
+			Timeout: time.Second * 5,
+			Jobs:    []*svc.RunJobInput{{Image: "hello-world", Name: "my-job", LogsDataset: "my-logs"}},
+			Input:   &svc.DescribeJobInput{Name: "my-job"},
+			IsErr:   isNilErr,
+			IsOutput: func(t testing.TB, out *svc.DescribeJobOutput) bool {
+				equals(t, "my-logs", out.LogsDataset)
+				equals(t, 0, len(out.Volumes))
+				return true
+			},
+		},
+		{
+			Name:    "when a short job is run it should eventually show its attempt, events and logs",
+			Timeout: time.Minute,
+			Jobs:    []*svc.RunJobInput{{Image: "hello-world", Name: "my-job"}},
+			Input:   &svc.DescribeJobInput{Name: "my-job", Tail: 3},
+			IsErr:   isNilErr,
+			IsOutput: func(t testing.TB, out *svc.DescribeJobOutput) bool {
+				if out.CompletedAt.IsZero() || len(out.Logs) < 1 {
+					return false
+				}
+
+				equals(t, 1, len(out.Attempts))
+				a := out.Attempts[0]
+				assert(t, !a.CreatedAt.IsZero() && !a.FinishedAt.IsZero(), "expected the attempt to have timestamps")
+				equals(t, svc.JobDetailsPhaseSucceeded, a.Details.Phase)
+				equals(t, "Completed", a.Details.TerminatedReason)
+				assert(t, len(out.Events) > 0, "expected events of the job and its pod")
+				assert(t, bytes.Contains(out.Logs, []byte("more examples and ideas")), "logs should contain the tail")
+				assert(t, !bytes.Contains(out.Logs, []byte("Hello from Docker")), "logs should not contain the data before the tail")
+				return true
+			},
+		},
+	} {
+		t.Run(c.Name, func(t *testing.T) {
+			if c.Timeout > time.Second*5 && testing.Short() {
+				t.Skipf("skipping long test with contex timeout: %s", c.Timeout)
+			}
+
+			di, clean := testDI(t)
+			defer clean()
+
+			ctx := context.Background()
+			ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+
+			kube := svc.NewKube(di)
+			for _, job := range c.Jobs {
+				_, err := kube.RunJob(ctx, job)
+				ok(t, err)
+			}
+
+			out, err := kube.DescribeJob(ctx, c.Input)
+			if c.IsErr != nil {
+				assert(t, c.IsErr(err), fmt.Sprintf("unexpected '%#v' to match: %#v", err, runtime.FuncForPC(reflect.ValueOf(c.IsErr).Pointer()).Name()))
+			}
+
+			if c.IsOutput == nil || err != nil {
+				return //no output testing
+			}
+
+			for {
+				if c.IsOutput(t, out) {
+					break
+				}
+
+				d := time.Second
+				t.Logf("retrying description in %s...", d)
+				<-time.After(d)
+
+				out, err = kube.DescribeJob(ctx, c.Input)
+				if err != nil {
+					t.Fatalf("failed to describe job during retry: %v", err)
+				}
+			}
+		})
+	}
+}
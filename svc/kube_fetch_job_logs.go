@@ -3,21 +3,38 @@ package svc
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nerdalize/nerd/pkg/kubevisor"
+	"github.com/pkg/errors"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 )
 
 //FetchJobLogsInput is the input to FetchJobLogs
 type FetchJobLogsInput struct {
-	Tail int64  `validate:"min=0"`
-	Name string `validate:"min=1,printascii"`
+	Tail        int64         `validate:"min=0"`
+	Since       time.Duration `validate:"min=0"` //only return logs that are more recent
+	Timestamps  bool          //prefix each line with the time it was written
+	Name        string        `validate:"min=1,printascii"`
+	Attempt     int           `validate:"min=0"` //only the logs of the Nth attempt, oldest first, the latest attempt with logs when zero
+	AllAttempts bool          //the logs of every attempt, oldest first
+	Offset      int64         `validate:"min=0"` //skip this many bytes of the logs, to page beyond MaxLogBytes
+
+	//Output receives the complete logs when provided, they are then not capped at MaxLogBytes
+	//and not returned as Data
+	Output io.Writer
 }
 
 //FetchJobLogsOutput is the output to FetchJobLogs
 type FetchJobLogsOutput struct {
 	Data []byte
+	More bool //the logs continue after Data, fetch them with an Offset that is MaxLogBytes further
 }
 
 //FetchJobLogs will create a job on kubernetes
@@ -38,24 +55,230 @@ func (k *Kube) FetchJobLogs(ctx context.Context, in *FetchJobLogsInput) (out *Fe
 		return nil, err
 	}
 
-	if len(pods.Items) < 1 {
-		return &FetchJobLogsOutput{}, nil
+	//the attempt didn't happen or its pod is gone, its logs may have been archived
+	if in.Attempt > len(pods.Items) {
+		return nil, errAttemptNotExists{errors.Errorf("job '%s' has no attempt %d, it was attempted %d time(s)", in.Name, in.Attempt, len(pods.Items))}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	pw := &pageWriter{w: buf, skip: in.Offset, limit: kubevisor.MaxLogBytes}
+	if in.Output != nil {
+		pw.w = in.Output
+		pw.limit = -1
+	}
+
+	sortAttempts(pods.Items)
+	opts := logOptions(in.Tail, in.Since, in.Timestamps)
+	if in.Output == nil {
+		opts.Limit = in.Offset + kubevisor.MaxLogBytes + 1 //no container needs to send more to fill the page
+	}
+
+	switch {
+	case in.AllAttempts:
+		for i := range pods.Items {
+			if err = k.fetchAttemptLogs(ctx, opts, i+1, &pods.Items[i], pw); err != nil {
+				break
+			}
+		}
+	case in.Attempt > 0:
+		err = k.fetchAttemptLogs(ctx, opts, in.Attempt, &pods.Items[in.Attempt-1], pw)
+	default:
+		err = k.fetchLatestLogs(ctx, opts, pods.Items, pw)
+	}
+
+	if err != nil && err != errPageFull {
+		return nil, err
+	}
+
+	return &FetchJobLogsOutput{Data: buf.Bytes(), More: pw.more}, nil
+}
+
+//FetchPodLogsInput is the input to FetchPodLogs
+type FetchPodLogsInput struct {
+	Name   string    `validate:"min=1,printascii"`
+	Output io.Writer `validate:"required"`
+}
+
+//FetchPodLogsOutput is the output to FetchPodLogs
+type FetchPodLogsOutput struct {
+	Attempt int //the pod is the Nth attempt at running its job, numbered as FetchJobLogs does. Zero when it belongs to no job
+}
+
+//FetchPodLogs writes the complete logs of the main container of a pod, eg. those of one attempt
+//at running a job
+func (k *Kube) FetchPodLogs(ctx context.Context, in *FetchPodLogsInput) (out *FetchPodLogsOutput, err error) {
+	if err = k.checkInput(ctx, in); err != nil {
+		return nil, err
+	}
+
+	pod := &corev1.Pod{}
+	err = k.visor.GetResource(ctx, kubevisor.ResourceTypePods, pod, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	out = &FetchPodLogsOutput{}
+	if uid, ok := pod.GetLabels()["controller-uid"]; ok {
+		pods := &pods{}
+		err = k.visor.ListResources(ctx, kubevisor.ResourceTypePods, pods, []string{"controller-uid=" + uid}, []string{})
+		if err != nil {
+			return nil, err
+		}
+
+		sortAttempts(pods.Items)
+		for i, item := range pods.Items {
+			if item.GetUID() == pod.GetUID() {
+				out.Attempt = i + 1
+			}
+		}
+	}
+
+	err = k.visor.FetchLogs(ctx, kubevisor.LogOptions{}, in.Output, "main", in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+//JobLogsFile is the file in a logs dataset that holds the logs of the Nth attempt at running a job
+func JobLogsFile(attempt int) string {
+	return fmt.Sprintf("attempt-%d.log", attempt)
+}
+
+//JobLogsAttempt returns the attempt whose logs are held by a file in a logs dataset, it returns
+//false when the file was not named by JobLogsFile
+func JobLogsAttempt(name string) (attempt int, ok bool) {
+	if !strings.HasPrefix(name, "attempt-") || !strings.HasSuffix(name, ".log") {
+		return 0, false
+	}
+
+	attempt, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "attempt-"), ".log"))
+	if err != nil || attempt < 1 || JobLogsFile(attempt) != name {
+		return 0, false
+	}
+
+	return attempt, true
+}
+
+//logOptions selects the logs of the last 'tail' lines, written in the last 'since' duration
+func logOptions(tail int64, since time.Duration, timestamps bool) (opts kubevisor.LogOptions) {
+	opts = kubevisor.LogOptions{Tail: tail, Timestamps: timestamps}
+	if since > 0 {
+		opts.Since = time.Now().Add(-since)
 	}
 
-	//sort by latest created
-	sort.Slice(pods.Items, func(i int, j int) bool {
-		return pods.Items[i].CreationTimestamp.UnixNano() > pods.Items[j].CreationTimestamp.UnixNano()
+	return opts
+}
+
+//sortAttempts orders the pods of a job by their creation, the attempts at running the job are
+//numbered in this order. Pods that were created in the same second are ordered by name
+func sortAttempts(items []corev1.Pod) {
+	sort.Slice(items, func(i int, j int) bool {
+		ti, tj := items[i].CreationTimestamp.Time, items[j].CreationTimestamp.Time
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+
+		return items[i].Name < items[j].Name
 	})
+}
 
-	//loop over the pods, return output from the first pod that returns logs, at most 3 times
-	buf := bytes.NewBuffer(nil)
-	for i := 0; i < len(pods.Items) && i < 3; i++ {
-		pod := pods.Items[i]
-		_ = k.visor.FetchLogs(ctx, in.Tail, buf, "main", pod.Name)
-		if buf.Len() > 0 {
+//fetchLatestLogs writes the logs of the most recently created pod that has logs
+func (k *Kube) fetchLatestLogs(ctx context.Context, opts kubevisor.LogOptions, items []corev1.Pod, pw *pageWriter) error {
+	items = append([]corev1.Pod{}, items...)
+	sortAttempts(items)
+
+	//loop over the pods, latest first, return output from the first pod that returns logs, at most 3 times
+	for i := len(items) - 1; i >= 0 && i >= len(items)-3; i-- {
+		pod := items[i]
+		_ = k.visor.FetchLogs(ctx, opts, pw, "main", pod.Name)
+		if pw.err != nil {
+			return pw.err
+		}
+
+		if pw.seen > 0 {
 			break
 		}
 	}
 
-	return &FetchJobLogsOutput{Data: buf.Bytes()}, nil
+	return nil
+}
+
+//fetchAttemptLogs writes the logs of one attempt at running the job, preceded by the logs of
+//its previous container when that was restarted. Each is introduced by a header line
+func (k *Kube) fetchAttemptLogs(ctx context.Context, opts kubevisor.LogOptions, n int, pod *corev1.Pod, pw *pageWriter) (err error) {
+	for _, cstatus := range pod.Status.ContainerStatuses {
+		if cstatus.Name != "main" || cstatus.RestartCount < 1 {
+			continue
+		}
+
+		if _, err = fmt.Fprintf(pw, "-- attempt %d, pod '%s', previous container --\n", n, pod.Name); err != nil {
+			return err
+		}
+
+		popts := opts
+		popts.Previous = true
+		_ = k.visor.FetchLogs(ctx, popts, pw, "main", pod.Name)
+		if pw.err != nil {
+			return pw.err
+		}
+	}
+
+	if _, err = fmt.Fprintf(pw, "-- attempt %d, pod '%s' --\n", n, pod.Name); err != nil {
+		return err
+	}
+
+	//pods that didn't start have no logs, that is not a reason to stop
+	_ = k.visor.FetchLogs(ctx, opts, pw, "main", pod.Name)
+	return pw.err
+}
+
+//errPageFull is returned by the pageWriter once it received more than it will write, it stops
+//the fetching of more logs
+var errPageFull = errors.New("page of logs is full")
+
+//pageWriter writes one page of the logs: the first 'skip' bytes are discarded and at most 'limit'
+//bytes are written after that, or everything when the limit is negative
+type pageWriter struct {
+	w     io.Writer
+	skip  int64
+	limit int64
+	seen  int64 //bytes received, including those that were skipped
+	more  bool  //bytes were received beyond the limit
+	err   error //the page is full or the logs could not be written
+}
+
+//Write implements io.Writer
+func (pw *pageWriter) Write(p []byte) (n int, err error) {
+	n = len(p)
+	pw.seen += int64(n)
+	if pw.skip >= int64(len(p)) {
+		pw.skip -= int64(len(p))
+		return n, nil
+	}
+
+	p = p[pw.skip:]
+	pw.skip = 0
+	if pw.limit >= 0 && int64(len(p)) > pw.limit {
+		p = p[:pw.limit]
+		pw.more = true
+	}
+
+	if _, err = pw.w.Write(p); err != nil {
+		pw.err = errors.Wrap(err, "failed to write logs")
+		return 0, pw.err
+	}
+
+	if pw.limit < 0 {
+		return n, nil
+	}
+
+	pw.limit -= int64(len(p))
+	if pw.more {
+		pw.err = errPageFull
+		return n, pw.err
+	}
+
+	return n, nil
 }
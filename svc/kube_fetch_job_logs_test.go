@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/nerdalize/nerd/pkg/kubevisor"
 	"github.com/nerdalize/nerd/svc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestFetchJobLogs(t *testing.T) {
@@ -72,6 +74,63 @@ func TestFetchJobLogs(t *testing.T) {
 				return true
 			},
 		},
+		{
+			Name:    "when all attempts are requested it should return the logs of each with a header",
+			Timeout: time.Minute,
+			Jobs:    []*svc.RunJobInput{{Image: "hello-world", Name: "my-job"}},
+			Input:   &svc.FetchJobLogsInput{Name: "my-job", AllAttempts: true},
+			IsErr:   nil,
+			IsOutput: func(t testing.TB, out *svc.FetchJobLogsOutput) bool {
+				if out == nil || !bytes.Contains(out.Data, []byte("Hello from Docker")) {
+					return false
+				}
+
+				assert(t, bytes.HasPrefix(out.Data, []byte("-- attempt 1, pod 'my-job-")), "logs should start with a header of the first attempt")
+				assert(t, !out.More, "logs should not continue on another page")
+				return true
+			},
+		},
+		{
+			Name:    "when the first attempt is requested it should return its logs with a header",
+			Timeout: time.Minute,
+			Jobs:    []*svc.RunJobInput{{Image: "hello-world", Name: "my-job"}},
+			Input:   &svc.FetchJobLogsInput{Name: "my-job", Attempt: 1},
+			IsErr:   nil,
+			IsOutput: func(t testing.TB, out *svc.FetchJobLogsOutput) bool {
+				if out == nil || !bytes.Contains(out.Data, []byte("Hello from Docker")) {
+					return false
+				}
+
+				assert(t, bytes.HasPrefix(out.Data, []byte("-- attempt 1, pod 'my-job-")), "logs should start with a header of the first attempt")
+				return true
+			},
+		},
+		{
+			Name:    "when an attempt is requested that has no pod it should return a not exists error",
+			Timeout: time.Second * 5,
+			Jobs:    []*svc.RunJobInput{{Image: "hello-world", Name: "my-job"}},
+			Input:   &svc.FetchJobLogsInput{Name: "my-job", Attempt: 5},
+			IsErr:   func(err error) bool { return kubevisor.IsNotExistsErr(err) && svc.IsAttemptNotExistsErr(err) },
+			IsOutput: func(t testing.TB, out *svc.FetchJobLogsOutput) bool {
+				return true
+			},
+		},
+		{
+			Name:    "offset option should allow for skipping the start of the logs",
+			Timeout: time.Minute,
+			Jobs:    []*svc.RunJobInput{{Image: "hello-world", Name: "my-job"}},
+			Input:   &svc.FetchJobLogsInput{Name: "my-job", Offset: 100},
+			IsErr:   nil,
+			IsOutput: func(t testing.TB, out *svc.FetchJobLogsOutput) bool {
+				if out == nil || len(out.Data) < 1 {
+					return false
+				}
+
+				assert(t, !bytes.Contains(out.Data, []byte("Hello from Docker")), "logs should not contain the data before the offset")
+				assert(t, bytes.Contains(out.Data, []byte("more examples and ideas")), "logs should contain the data after the offset")
+				return true
+			},
+		},
 
 		//@TODO find a way to not be dependant on a specific key to be present on s3
 		// {
@@ -148,3 +207,121 @@ func TestFetchJobLogs(t *testing.T) {
 		})
 	}
 }
+
+//testPodName returns the name of a pod that was created for the job, without the prefix of the
+//visor. It is empty when no pod was created yet
+func testPodName(tb testing.TB, di svc.DI) string {
+	pods, err := di.Kube().CoreV1().Pods(di.Namespace()).List(metav1.ListOptions{})
+	ok(tb, err)
+	if len(pods.Items) < 1 {
+		return ""
+	}
+
+	return strings.TrimPrefix(pods.Items[0].Name, kubevisor.DefaultPrefix)
+}
+
+func TestFetchPodLogs(t *testing.T) {
+	for _, c := range []struct {
+		Name     string
+		Timeout  time.Duration
+		Jobs     []*svc.RunJobInput
+		Input    *svc.FetchPodLogsInput
+		IsOutput func(tb testing.TB, out *svc.FetchPodLogsOutput, logs []byte) bool
+		IsErr    func(error) bool
+	}{
+		{
+			Name:    "when a zero value input is provided it should return a validation error",
+			Timeout: time.Second * 5,
+			Jobs:    nil,
+			Input:   nil,
+			IsErr:   svc.IsValidationErr,
+			IsOutput: func(t testing.TB, out *svc.FetchPodLogsOutput, logs []byte) bool {
+				return true
+			},
+		},
+		{
+			Name:    "when pod doesnt exist it should return a not exists error",
+			Timeout: time.Second * 5,
+			Input:   &svc.FetchPodLogsInput{Name: "my-pod"},
+			IsErr:   kubevisor.IsNotExistsErr,
+			IsOutput: func(t testing.TB, out *svc.FetchPodLogsOutput, logs []byte) bool {
+				return true
+			},
+		},
+		{
+			Name:    "when the pod of a job is provided it should return its logs as the first attempt",
+			Timeout: time.Minute,
+			Jobs:    []*svc.RunJobInput{{Image: "hello-world", Name: "my-job"}},
+			Input:   &svc.FetchPodLogsInput{}, //named after the pod of the job
+			IsErr:   nil,
+			IsOutput: func(t testing.TB, out *svc.FetchPodLogsOutput, logs []byte) bool {
+				if out == nil || !bytes.Contains(logs, []byte("Hello from Docker")) {
+					return false
+				}
+
+				equals(t, 1, out.Attempt)
+				return true
+			},
+		},
+	} {
+		t.Run(c.Name, func(t *testing.T) {
+			if c.Timeout > time.Second*5 && testing.Short() {
+				t.Skipf("skipping long test with contex timeout: %s", c.Timeout)
+			}
+
+			di, clean := testDI(t)
+			defer clean()
+
+			ctx := context.Background()
+			ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+
+			kube := svc.NewKube(di)
+			for _, job := range c.Jobs {
+				_, err := kube.RunJob(ctx, job)
+				ok(t, err)
+			}
+
+			buf := bytes.NewBuffer(nil)
+			if c.Input != nil {
+				c.Input.Output = buf
+			}
+
+			for {
+				if len(c.Jobs) > 0 {
+					c.Input.Name = testPodName(t, di)
+				}
+
+				buf.Reset()
+				out, err := kube.FetchPodLogs(ctx, c.Input)
+				if c.IsErr != nil { //if c.IsErr is nil we dont care about errors
+					assert(t, c.IsErr(err), fmt.Sprintf("unexpected '%#v' to match: %#v", err, runtime.FuncForPC(reflect.ValueOf(c.IsErr).Pointer()).Name()))
+				}
+
+				if c.IsOutput == nil || c.IsOutput(t, out, buf.Bytes()) {
+					break
+				}
+
+				d := time.Second
+				t.Logf("retrying logs in %s...", d)
+				<-time.After(d)
+			}
+		})
+	}
+}
+
+func TestJobLogsAttempt(t *testing.T) {
+	for name, exp := range map[string]int{
+		svc.JobLogsFile(1):  1,
+		svc.JobLogsFile(12): 12,
+		"attempt-0.log":     0,
+		"attempt-01.log":    0,
+		"attempt-1.txt":     0,
+		"dir/attempt-1.log": 0,
+		"my-file.log":       0,
+	} {
+		attempt, isLogs := svc.JobLogsAttempt(name)
+		equals(t, exp, attempt)
+		equals(t, exp > 0, isLogs)
+	}
+}
@@ -0,0 +1,112 @@
+package svc
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/nerdalize/nerd/pkg/kubevisor"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+//FollowJobLogsInterval is how often a job is checked for a new pod whose logs can be followed
+var FollowJobLogsInterval = time.Second * 2
+
+//FollowJobLogsInput is the input to FollowJobLogs
+type FollowJobLogsInput struct {
+	Tail       int64         `validate:"min=0"`
+	Since      time.Duration `validate:"min=0"`
+	Timestamps bool
+	Name       string    `validate:"min=1,printascii"`
+	Output     io.Writer `validate:"required"`
+
+	//Attempt is called before the logs of a pod are followed, when a failed pod is retried it
+	//is called again for the new pod
+	Attempt func(pod string)
+}
+
+//FollowJobLogsOutput is the output to FollowJobLogs
+type FollowJobLogsOutput struct {
+	Attempts int //number of pods whose logs were followed
+}
+
+//FollowJobLogs will write logs of a job to the output as they are produced, until the job completed
+//or failed. When a pod of the job fails and is retried the logs of the new pod are followed next
+func (k *Kube) FollowJobLogs(ctx context.Context, in *FollowJobLogsInput) (out *FollowJobLogsOutput, err error) {
+	if err = k.checkInput(ctx, in); err != nil {
+		return nil, err
+	}
+
+	out = &FollowJobLogsOutput{}
+	opts := logOptions(in.Tail, in.Since, in.Timestamps)
+	opts.Follow = true
+	followed := map[string]bool{}
+	for {
+		job := &batchv1.Job{}
+		err = k.visor.GetResource(ctx, kubevisor.ResourceTypeJobs, job, in.Name)
+		if err != nil {
+			return out, err
+		}
+
+		pods := &pods{}
+		err = k.visor.ListResources(ctx, kubevisor.ResourceTypePods, pods, []string{"controller-uid=" + string(job.GetUID())}, []string{})
+		if err != nil {
+			return out, err
+		}
+
+		//only the most recent pod is followed, earlier pods of the job failed already
+		sortAttempts(pods.Items)
+		if n := len(pods.Items); n > 0 && !followed[pods.Items[n-1].Name] && containerStarted(&pods.Items[n-1]) {
+			pod := pods.Items[n-1]
+			followed[pod.Name] = true
+			out.Attempts++
+			if in.Attempt != nil {
+				in.Attempt(pod.Name)
+			}
+
+			//the stream ends when the container stops
+			err = k.visor.FetchLogs(ctx, opts, in.Output, "main", pod.Name)
+			if err != nil {
+				return out, err
+			}
+
+			//retried pods are shown from their start
+			opts.Tail = 0
+			opts.Since = time.Time{}
+			continue
+		}
+
+		if jobFinished(job) {
+			return out, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return out, ctx.Err()
+		case <-time.After(FollowJobLogsInterval):
+		}
+	}
+}
+
+//containerStarted returns whether the main container of the pod started, only then it has logs
+func containerStarted(pod *corev1.Pod) bool {
+	for _, cstatus := range pod.Status.ContainerStatuses {
+		if cstatus.Name == "main" {
+			return cstatus.State.Running != nil || cstatus.State.Terminated != nil
+		}
+	}
+
+	return false
+}
+
+//jobFinished returns whether the job completed, failed or was stopped such that it will not create new pods
+func jobFinished(job *batchv1.Job) bool {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status == corev1.ConditionTrue && (cond.Type == batchv1.JobComplete || cond.Type == batchv1.JobFailed) {
+			return true
+		}
+	}
+
+	return job.Spec.Parallelism != nil && *job.Spec.Parallelism == 0 && job.Status.Active == 0
+}
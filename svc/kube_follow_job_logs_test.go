@@ -0,0 +1,85 @@
+package svc_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/nerdalize/nerd/pkg/kubevisor"
+	"github.com/nerdalize/nerd/svc"
+)
+
+func TestFollowJobLogs(t *testing.T) {
+	for _, c := range []struct {
+		Name     string
+		Timeout  time.Duration
+		Jobs     []*svc.RunJobInput
+		Input    *svc.FollowJobLogsInput
+		IsOutput func(tb testing.TB, out *svc.FollowJobLogsOutput, logs []byte)
+		IsErr    func(error) bool
+	}{
+		{
+			Name:    "when a zero value input is provided it should return a validation error",
+			Timeout: time.Second * 5,
+			Jobs:    nil,
+			Input:   nil,
+			IsErr:   svc.IsValidationErr,
+		},
+		{
+			Name:    "when job doesnt exist it should return a not exists error",
+			Timeout: time.Second * 5,
+			Input:   &svc.FollowJobLogsInput{Name: "my-job"},
+			IsErr:   kubevisor.IsNotExistsErr,
+		},
+		{
+			Name:    "when a short job is run it should stream its logs until it completed",
+			Timeout: time.Minute,
+			Jobs:    []*svc.RunJobInput{{Image: "hello-world", Name: "my-job"}},
+			Input:   &svc.FollowJobLogsInput{Name: "my-job"},
+			IsErr:   isNilErr,
+			IsOutput: func(t testing.TB, out *svc.FollowJobLogsOutput, logs []byte) {
+				equals(t, 1, out.Attempts)
+				assert(t, bytes.Contains(logs, []byte("Hello from Docker")), "logs should contain the data we expect")
+			},
+		},
+	} {
+		t.Run(c.Name, func(t *testing.T) {
+			if c.Timeout > time.Second*5 && testing.Short() {
+				t.Skipf("skipping long test with contex timeout: %s", c.Timeout)
+			}
+
+			di, clean := testDI(t)
+			defer clean()
+
+			ctx := context.Background()
+			ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+
+			kube := svc.NewKube(di)
+			for _, job := range c.Jobs {
+				_, err := kube.RunJob(ctx, job)
+				ok(t, err)
+			}
+
+			buf := bytes.NewBuffer(nil)
+			if c.Input != nil {
+				c.Input.Output = buf
+			}
+
+			out, err := kube.FollowJobLogs(ctx, c.Input)
+			if c.IsErr != nil {
+				assert(t, c.IsErr(err), fmt.Sprintf("unexpected '%#v' to match: %#v", err, runtime.FuncForPC(reflect.ValueOf(c.IsErr).Pointer()).Name()))
+			}
+
+			if c.IsOutput == nil || err != nil {
+				return //no output testing
+			}
+
+			c.IsOutput(t, out, buf.Bytes())
+		})
+	}
+}
@@ -22,9 +22,15 @@ type GetDatasetOutput struct {
 
 	InputFor   []string
 	OutputFrom []string
+	LogsOf     []string
+
+	State        datasetsv1.DatasetState
+	StateMessage string
 
 	StoreOptions    transferstore.StoreOptions
 	ArchiverOptions transferarchiver.ArchiverOptions
+
+	Digests map[string]string
 }
 
 //GetDataset will retrieve a dataset from kubernetes
@@ -49,7 +55,11 @@ func GetDatasetOutputFromSpec(dataset *datasetsv1.Dataset) *GetDatasetOutput {
 		Size:            dataset.Spec.Size,
 		InputFor:        dataset.Spec.InputFor,
 		OutputFrom:      dataset.Spec.OutputFrom,
+		LogsOf:          dataset.Spec.LogsOf,
+		State:           dataset.Spec.State,
+		StateMessage:    dataset.Spec.StateMessage,
 		StoreOptions:    dataset.Spec.StoreOptions,
 		ArchiverOptions: dataset.Spec.ArchiverOptions,
+		Digests:         dataset.Spec.Digests,
 	}
 }
@@ -0,0 +1,43 @@
+package svc
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/nerdalize/nerd/pkg/kubevisor"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+//JobDefaultsArchiveLogsAnnotation can be set to "true" on a namespace to archive the logs of
+//every job that runs in it, unless the job is run with explicit options
+var JobDefaultsArchiveLogsAnnotation = "nerd.nerdalize.com/archive-logs"
+
+//GetJobDefaultsInput is the input to GetJobDefaults
+type GetJobDefaultsInput struct{}
+
+//GetJobDefaultsOutput is the output to GetJobDefaults
+type GetJobDefaultsOutput struct {
+	ArchiveLogs bool
+}
+
+//GetJobDefaults returns the options that jobs in the namespace use by default, they are read from
+//annotations on the namespace
+func (k *Kube) GetJobDefaults(ctx context.Context, in *GetJobDefaultsInput) (out *GetJobDefaultsOutput, err error) {
+	if err = k.checkInput(ctx, in); err != nil {
+		return nil, err
+	}
+
+	ns := &corev1.Namespace{}
+	err = k.visor.GetClusterResource(ctx, kubevisor.ResourceTypeNamespaces, ns, k.visor.Namespace())
+	if err != nil {
+		return nil, err
+	}
+
+	out = &GetJobDefaultsOutput{}
+	if v, ok := ns.GetAnnotations()[JobDefaultsArchiveLogsAnnotation]; ok {
+		out.ArchiveLogs, _ = strconv.ParseBool(v)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,94 @@
+package svc_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/nerdalize/nerd/svc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetJobDefaults(t *testing.T) {
+	for _, c := range []struct {
+		Name        string
+		Timeout     time.Duration
+		Annotations map[string]string
+		Input       *svc.GetJobDefaultsInput
+		IsOutput    func(tb testing.TB, out *svc.GetJobDefaultsOutput)
+		IsErr       func(error) bool
+	}{
+		{
+			Name:    "when the namespace has no annotations it should not archive logs",
+			Timeout: time.Second * 5,
+			Input:   &svc.GetJobDefaultsInput{},
+			IsErr:   isNilErr,
+			IsOutput: func(t testing.TB, out *svc.GetJobDefaultsOutput) {
+				assert(t, !out.ArchiveLogs, "logs should not be archived by default")
+			},
+		},
+		{
+			Name:        "when the namespace is annotated to archive logs it should archive logs",
+			Timeout:     time.Second * 5,
+			Annotations: map[string]string{svc.JobDefaultsArchiveLogsAnnotation: "true"},
+			Input:       &svc.GetJobDefaultsInput{},
+			IsErr:       isNilErr,
+			IsOutput: func(t testing.TB, out *svc.GetJobDefaultsOutput) {
+				assert(t, out.ArchiveLogs, "logs should be archived")
+			},
+		},
+		{
+			Name:        "when the annotation is not a boolean it should not archive logs",
+			Timeout:     time.Second * 5,
+			Annotations: map[string]string{svc.JobDefaultsArchiveLogsAnnotation: "sometimes"},
+			Input:       &svc.GetJobDefaultsInput{},
+			IsErr:       isNilErr,
+			IsOutput: func(t testing.TB, out *svc.GetJobDefaultsOutput) {
+				assert(t, !out.ArchiveLogs, "logs should not be archived")
+			},
+		},
+	} {
+		t.Run(c.Name, func(t *testing.T) {
+			di, clean := testDI(t)
+			defer clean()
+
+			ctx := context.Background()
+			ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+
+			if c.Annotations != nil {
+				ns, err := di.Kube().CoreV1().Namespaces().Get(di.Namespace(), metav1.GetOptions{})
+				ok(t, err)
+
+				ns.SetAnnotations(c.Annotations)
+				_, err = di.Kube().CoreV1().Namespaces().Update(ns)
+				ok(t, err)
+			}
+
+			kube := svc.NewKube(di)
+			out, err := kube.GetJobDefaults(ctx, c.Input)
+			if c.IsErr != nil {
+				assert(t, c.IsErr(err), fmt.Sprintf("unexpected '%#v' to match: %#v", err, runtime.FuncForPC(reflect.ValueOf(c.IsErr).Pointer()).Name()))
+			}
+
+			if c.IsOutput != nil {
+				c.IsOutput(t, out)
+			}
+		})
+	}
+}
+
+func TestGetJobDefaultsWithoutNamespace(t *testing.T) {
+	di := testDIWithoutNamespace(t)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	kube := svc.NewKube(di)
+	_, err := kube.GetJobDefaults(ctx, &svc.GetJobDefaultsInput{})
+	assert(t, err != nil, "expected an error when the namespace doesn't exist")
+}
@@ -0,0 +1,42 @@
+package svc
+
+import (
+	"context"
+
+	"github.com/nerdalize/nerd/pkg/kubevisor"
+)
+
+//GetJobLogsDatasetInput is the input to GetJobLogsDataset
+type GetJobLogsDatasetInput struct {
+	Name string `validate:"min=1,printascii"`
+}
+
+//GetJobLogsDatasetOutput is the output to GetJobLogsDataset
+type GetJobLogsDatasetOutput struct {
+	Name string //empty when the logs of the job are not archived
+}
+
+//GetJobLogsDataset returns the dataset that the logs of a job are archived in, they are kept
+//there once the pods of the job are gone
+func (k *Kube) GetJobLogsDataset(ctx context.Context, in *GetJobLogsDatasetInput) (out *GetJobLogsDatasetOutput, err error) {
+	if err = k.checkInput(ctx, in); err != nil {
+		return nil, err
+	}
+
+	datasets := &datasets{}
+	err = k.visor.ListResources(ctx, kubevisor.ResourceTypeDatasets, datasets, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	out = &GetJobLogsDatasetOutput{}
+	for _, dataset := range datasets.Items {
+		for _, job := range dataset.Spec.LogsOf {
+			if job == in.Name {
+				out.Name = dataset.GetName()
+			}
+		}
+	}
+
+	return out, nil
+}
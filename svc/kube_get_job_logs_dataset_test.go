@@ -0,0 +1,90 @@
+package svc_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/nerdalize/nerd/pkg/transfer/archiver"
+	"github.com/nerdalize/nerd/pkg/transfer/store"
+	"github.com/nerdalize/nerd/svc"
+)
+
+func TestGetJobLogsDataset(t *testing.T) {
+	for _, c := range []struct {
+		Name     string
+		Timeout  time.Duration
+		Datasets map[string]*svc.UpdateDatasetInput //datasets that are created and then updated
+		Input    *svc.GetJobLogsDatasetInput
+		IsOutput func(tb testing.TB, out *svc.GetJobLogsDatasetOutput)
+		IsErr    func(error) bool
+	}{
+		{
+			Name:    "when a zero value input is provided it should return a validation error",
+			Timeout: time.Second * 5,
+			Input:   nil,
+			IsErr:   svc.IsValidationErr,
+		},
+		{
+			Name:    "when no dataset holds the logs of the job it should return no dataset",
+			Timeout: time.Second * 5,
+			Datasets: map[string]*svc.UpdateDatasetInput{
+				"my-input": {InputFor: "my-job"},
+			},
+			Input: &svc.GetJobLogsDatasetInput{Name: "my-job"},
+			IsErr: isNilErr,
+			IsOutput: func(t testing.TB, out *svc.GetJobLogsDatasetOutput) {
+				equals(t, "", out.Name)
+			},
+		},
+		{
+			Name:    "when a dataset holds the logs of the job it should return that dataset",
+			Timeout: time.Second * 5,
+			Datasets: map[string]*svc.UpdateDatasetInput{
+				"my-input": {InputFor: "my-job"},
+				"my-logs":  {LogsOf: "my-job"},
+				"old-logs": {LogsOf: "my-other-job"},
+			},
+			Input: &svc.GetJobLogsDatasetInput{Name: "my-job"},
+			IsErr: isNilErr,
+			IsOutput: func(t testing.TB, out *svc.GetJobLogsDatasetOutput) {
+				equals(t, "my-logs", out.Name)
+			},
+		},
+	} {
+		t.Run(c.Name, func(t *testing.T) {
+			di, clean := testDI(t)
+			defer clean()
+
+			ctx := context.Background()
+			ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+
+			kube := svc.NewKube(di)
+			for name, update := range c.Datasets {
+				_, err := kube.CreateDataset(ctx, &svc.CreateDatasetInput{
+					Name:            name,
+					StoreOptions:    transferstore.StoreOptions{Type: transferstore.StoreTypeS3},
+					ArchiverOptions: transferarchiver.ArchiverOptions{Type: transferarchiver.ArchiverTypeTar},
+				})
+				ok(t, err)
+
+				update.Name = name
+				_, err = kube.UpdateDataset(ctx, update)
+				ok(t, err)
+			}
+
+			out, err := kube.GetJobLogsDataset(ctx, c.Input)
+			if c.IsErr != nil {
+				assert(t, c.IsErr(err), fmt.Sprintf("unexpected '%#v' to match: %#v", err, runtime.FuncForPC(reflect.ValueOf(c.IsErr).Pointer()).Name()))
+			}
+
+			if c.IsOutput != nil {
+				c.IsOutput(t, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package svc
+
+import (
+	"context"
+
+	"github.com/nerdalize/nerd/pkg/kubevisor"
+	"k8s.io/api/core/v1"
+)
+
+//GetStoreSecretInput is the input to GetStoreSecret
+type GetStoreSecretInput struct {
+	Name string `validate:"printascii"`
+}
+
+//GetStoreSecretOutput is the output to GetStoreSecret
+type GetStoreSecretOutput struct {
+	Name         string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Username     string
+	Password     string
+	Token        string
+}
+
+//GetStoreSecret will retrieve the store credentials from the secret matching the provided name
+func (k *Kube) GetStoreSecret(ctx context.Context, in *GetStoreSecretInput) (out *GetStoreSecretOutput, err error) {
+	if err = k.checkInput(ctx, in); err != nil {
+		return nil, err
+	}
+
+	secret := &v1.Secret{}
+	err = k.visor.GetResource(ctx, kubevisor.ResourceTypeSecrets, secret, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetStoreSecretOutput{
+		Name:         secret.Name,
+		AccessKey:    string(secret.Data[StoreSecretAccessKey]),
+		SecretKey:    string(secret.Data[StoreSecretSecretKey]),
+		SessionToken: string(secret.Data[StoreSecretSessionToken]),
+		Username:     string(secret.Data[StoreSecretUsername]),
+		Password:     string(secret.Data[StoreSecretPassword]),
+		Token:        string(secret.Data[StoreSecretToken]),
+	}, nil
+}
@@ -0,0 +1,34 @@
+package svc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nerdalize/nerd/svc"
+)
+
+func TestGetStoreSecret(t *testing.T) {
+	di, clean := testDI(t)
+	defer clean()
+
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	kube := svc.NewKube(di)
+	_, err := kube.CreateStoreSecret(ctx, &svc.CreateStoreSecretInput{})
+	assert(t, svc.IsValidationErr(err), "expected validation error when no credentials are provided")
+
+	out, err := kube.CreateStoreSecret(ctx, &svc.CreateStoreSecretInput{
+		AccessKey: "my-access-key",
+		SecretKey: "my-secret-key",
+	})
+	ok(t, err)
+
+	o, err := kube.GetStoreSecret(ctx, &svc.GetStoreSecretInput{Name: out.Name})
+	ok(t, err)
+	equals(t, "my-access-key", o.AccessKey)
+	equals(t, "my-secret-key", o.SecretKey)
+	equals(t, "", o.SessionToken)
+}
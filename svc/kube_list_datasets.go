@@ -16,6 +16,10 @@ type DatasetDetails struct {
 	Size       uint64
 	InputFor   []string
 	OutputFrom []string
+	LogsOf     []string
+
+	State        datasetsv1.DatasetState
+	StateMessage string
 }
 
 //ListDatasetItem is a dataset listing item
@@ -55,7 +59,11 @@ func (k *Kube) ListDatasets(ctx context.Context, in *ListDatasetsInput) (out *Li
 				Size:       dataset.Spec.Size,
 				InputFor:   dataset.Spec.InputFor,
 				OutputFrom: dataset.Spec.OutputFrom,
+				LogsOf:     dataset.Spec.LogsOf,
 				CreatedAt:  dataset.CreationTimestamp.Local(),
+
+				State:        dataset.Spec.State,
+				StateMessage: dataset.Spec.StateMessage,
 			},
 		}
 
@@ -36,6 +36,12 @@ var (
 //JobEvent contains infromation from the events
 type JobEvent struct {
 	Message string
+	Type    string //Normal or Warning
+	Reason  string
+	Object  string //kind and name of the resource the event is about
+	Count   int32
+	FirstAt time.Time
+	LastAt  time.Time
 }
 
 //JobDetails tells us more about the job by looking at underlying resources
@@ -60,6 +66,7 @@ type ListJobItem struct {
 	Image       string
 	Input       []string
 	Output      []string
+	LogsDataset string //receives the logs of each attempt
 	Memory      int64
 	VCPU        int64
 	CreatedAt   time.Time
@@ -115,52 +122,7 @@ func (k *Kube) ListJobs(ctx context.Context, in *ListJobsInput) (out *ListJobsOu
 			continue
 		}
 
-		c := job.Spec.Template.Spec.Containers[0]
-		item := &ListJobItem{
-			Name:      job.GetName(),
-			Image:     c.Image,
-			CreatedAt: job.CreationTimestamp.Local(),
-			Details:   JobDetails{},
-		}
-
-		if parr := job.Spec.Parallelism; parr != nil {
-			item.Details.Parallelism = *parr
-		}
-
-		if dt := job.GetDeletionTimestamp(); dt != nil {
-			item.DeletedAt = dt.Local() //mark as deleting
-		}
-
-		if job.Status.StartTime != nil {
-			item.ActiveAt = job.Status.StartTime.Local()
-		}
-
-		for _, dataset := range job.Spec.Template.Spec.Volumes {
-			if dataset.FlexVolume != nil {
-				if dataset.FlexVolume.Options["input/dataset"] != "" {
-					item.Input = append(item.Input, dataset.FlexVolume.Options["input/dataset"])
-				}
-				if dataset.FlexVolume.Options["output/dataset"] != "" {
-					item.Output = append(item.Output, dataset.FlexVolume.Options["output/dataset"])
-				}
-			}
-		}
-
-		for _, cond := range job.Status.Conditions {
-			if cond.Status != corev1.ConditionTrue {
-				continue
-			}
-
-			switch cond.Type {
-			case batchv1.JobComplete:
-				item.CompletedAt = cond.LastTransitionTime.Local()
-			case batchv1.JobFailed:
-				item.FailedAt = cond.LastTransitionTime.Local()
-			}
-		}
-		item.Memory = job.Spec.Template.Spec.Containers[0].Resources.Requests.Memory().MilliValue()
-		item.VCPU = job.Spec.Template.Spec.Containers[0].Resources.Requests.Cpu().MilliValue()
-
+		item := listJobItem(&job)
 		mapping[job.UID] = item
 		out.Items = append(out.Items, item)
 	}
@@ -196,63 +158,122 @@ func (k *Kube) ListJobs(ctx context.Context, in *ListJobsInput) (out *ListJobsOu
 			continue //this pod was created before the other one in the item, ignore
 		}
 
-		//the pod phase allows us to distinguish between Pending and Running
-		switch pod.Status.Phase {
-		case corev1.PodPending:
-			jobItem.Details.Phase = JobDetailsPhasePending
-		case corev1.PodRunning:
-			jobItem.Details.Phase = JobDetailsPhaseRunning
-		case corev1.PodFailed:
-			jobItem.Details.Phase = JobDetailsPhaseFailed
-		case corev1.PodSucceeded:
-			jobItem.Details.Phase = JobDetailsPhaseSucceeded
-		default:
-			jobItem.Details.Phase = JobDetailsPhaseUnknown
-		}
+		podDetails(&pod, &jobItem.Details)
+	}
 
-		for _, cond := range pod.Status.Conditions {
-			//onschedulable is a reason for being pending
-			if cond.Type == corev1.PodScheduled {
-				if cond.Status == corev1.ConditionFalse {
-					if cond.Reason == corev1.PodReasonUnschedulable {
-						// From src: "PodReasonUnschedulable reason in PodScheduled PodCondition means that the scheduler
-						// can't schedule the pod right now"
-						jobItem.Details.UnschedulableReason = "NotYetSchedulable" //special case
-						jobItem.Details.UnschedulableMessage = cond.Message
-					} else {
-						jobItem.Details.UnschedulableReason = cond.Reason
-						jobItem.Details.UnschedulableMessage = cond.Message
-					}
-
-					//NotScheduled
-
-				} else if cond.Status == corev1.ConditionTrue {
-					jobItem.Details.Scheduled = true
-				}
+	return out, nil
+}
+
+//listJobItem describes a job from its spec and status, the details are filled in from its pods
+func listJobItem(job *batchv1.Job) *ListJobItem {
+	c := job.Spec.Template.Spec.Containers[0]
+	item := &ListJobItem{
+		Name:      job.GetName(),
+		Image:     c.Image,
+		CreatedAt: job.CreationTimestamp.Local(),
+		Details:   JobDetails{},
+	}
+
+	if parr := job.Spec.Parallelism; parr != nil {
+		item.Details.Parallelism = *parr
+	}
+
+	if dt := job.GetDeletionTimestamp(); dt != nil {
+		item.DeletedAt = dt.Local() //mark as deleting
+	}
+
+	if job.Status.StartTime != nil {
+		item.ActiveAt = job.Status.StartTime.Local()
+	}
+
+	for _, dataset := range job.Spec.Template.Spec.Volumes {
+		if dataset.FlexVolume != nil {
+			if dataset.FlexVolume.Options["input/dataset"] != "" {
+				item.Input = append(item.Input, dataset.FlexVolume.Options["input/dataset"])
 			}
+			if dataset.FlexVolume.Options["output/dataset"] != "" {
+				item.Output = append(item.Output, dataset.FlexVolume.Options["output/dataset"])
+			}
+			if dataset.FlexVolume.Options["logs/dataset"] != "" {
+				item.LogsDataset = dataset.FlexVolume.Options["logs/dataset"]
+			}
+		}
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
 		}
 
-		//container conditions allow us to capture ErrImageNotFound
-		for _, cstatus := range pod.Status.ContainerStatuses {
-			if cstatus.Name != "main" { //we only care about the main container
-				continue
-			}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			item.CompletedAt = cond.LastTransitionTime.Local()
+		case batchv1.JobFailed:
+			item.FailedAt = cond.LastTransitionTime.Local()
+		}
+	}
+	item.Memory = job.Spec.Template.Spec.Containers[0].Resources.Requests.Memory().MilliValue()
+	item.VCPU = job.Spec.Template.Spec.Containers[0].Resources.Requests.Cpu().MilliValue()
 
-			//waiting reasons give us ErrImagePull/Backoff
-			if cstatus.State.Waiting != nil {
-				jobItem.Details.WaitingReason = cstatus.State.Waiting.Reason
-				jobItem.Details.WaitingMessage = cstatus.State.Waiting.Message
-			}
+	return item
+}
+
+//podDetails fills in the details of a job from one of its pods
+func podDetails(pod *corev1.Pod, d *JobDetails) {
+	//the pod phase allows us to distinguish between Pending and Running
+	switch pod.Status.Phase {
+	case corev1.PodPending:
+		d.Phase = JobDetailsPhasePending
+	case corev1.PodRunning:
+		d.Phase = JobDetailsPhaseRunning
+	case corev1.PodFailed:
+		d.Phase = JobDetailsPhaseFailed
+	case corev1.PodSucceeded:
+		d.Phase = JobDetailsPhaseSucceeded
+	default:
+		d.Phase = JobDetailsPhaseUnknown
+	}
 
-			if cstatus.State.Terminated != nil {
-				jobItem.Details.TerminatedReason = cstatus.State.Terminated.Reason
-				jobItem.Details.TerminatedMessage = cstatus.State.Terminated.Message
-				jobItem.Details.TerminatedExitCode = cstatus.State.Terminated.ExitCode
+	for _, cond := range pod.Status.Conditions {
+		//onschedulable is a reason for being pending
+		if cond.Type == corev1.PodScheduled {
+			if cond.Status == corev1.ConditionFalse {
+				if cond.Reason == corev1.PodReasonUnschedulable {
+					// From src: "PodReasonUnschedulable reason in PodScheduled PodCondition means that the scheduler
+					// can't schedule the pod right now"
+					d.UnschedulableReason = "NotYetSchedulable" //special case
+					d.UnschedulableMessage = cond.Message
+				} else {
+					d.UnschedulableReason = cond.Reason
+					d.UnschedulableMessage = cond.Message
+				}
+
+				//NotScheduled
+
+			} else if cond.Status == corev1.ConditionTrue {
+				d.Scheduled = true
 			}
 		}
 	}
 
-	return out, nil
+	//container conditions allow us to capture ErrImageNotFound
+	for _, cstatus := range pod.Status.ContainerStatuses {
+		if cstatus.Name != "main" { //we only care about the main container
+			continue
+		}
+
+		//waiting reasons give us ErrImagePull/Backoff
+		if cstatus.State.Waiting != nil {
+			d.WaitingReason = cstatus.State.Waiting.Reason
+			d.WaitingMessage = cstatus.State.Waiting.Message
+		}
+
+		if cstatus.State.Terminated != nil {
+			d.TerminatedReason = cstatus.State.Terminated.Reason
+			d.TerminatedMessage = cstatus.State.Terminated.Message
+			d.TerminatedExitCode = cstatus.State.Terminated.ExitCode
+		}
+	}
 }
 
 //jobs implements the list transformer interface to allow the kubevisor the manage names for us
@@ -29,6 +29,10 @@ type RunJobInput struct {
 	Memory       string
 	VCPU         string
 	Secret       string
+
+	//LogsDataset receives the logs of each attempt at running the job once it finished, the
+	//logs are then kept after the pods are gone
+	LogsDataset string
 }
 
 //JobVolumeType determines if its content will be uploaded or downloaded
@@ -131,6 +135,20 @@ func (k *Kube) RunJob(ctx context.Context, in *RunJobInput) (out *RunJobOutput,
 		})
 	}
 
+	//the volume is not mounted into the container, the driver uses it to archive the logs
+	//when the pod terminates
+	if in.LogsDataset != "" {
+		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, v1.Volume{
+			Name: "nerd-logs",
+			VolumeSource: v1.VolumeSource{
+				FlexVolume: &v1.FlexVolumeSource{
+					Driver:  "nerdalize.com/dataset",
+					Options: map[string]string{"logs/dataset": in.LogsDataset},
+				},
+			},
+		})
+	}
+
 	if in.Secret != "" {
 		job.Spec.Template.Spec.ImagePullSecrets = append(job.Spec.Template.Spec.ImagePullSecrets, v1.LocalObjectReference{
 			Name: kubevisor.DefaultPrefix + in.Secret,
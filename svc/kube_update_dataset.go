@@ -15,6 +15,35 @@ type UpdateDatasetInput struct {
 	Size       *uint64
 	InputFor   string
 	OutputFrom string
+	LogsOf     string //the job whose logs are archived in the dataset
+
+	//State replaces the state of the dataset together with its message
+	State        datasetsv1.DatasetState
+	StateMessage string
+
+	//AppendLayer records an archive layer that was pushed on top of the existing
+	//content, the size of the dataset grows by LayerSize
+	AppendLayer string
+	LayerSize   uint64
+
+	//ResetLayers forgets all recorded layers, eg. when the content was replaced as a whole
+	ResetLayers bool
+
+	//AddAppending changes the number of layers that are being appended, eg. 1 when an append
+	//starts and -1 when it finished
+	AddAppending int
+
+	//Digests replaces the recorded object digests, AddDigests records digests of additional
+	//objects, eg. those of an appended layer
+	Digests    map[string]string
+	AddDigests map[string]string
+
+	//Clear records that the content of the dataset was removed: the size, layers and digests
+	//are reset and the state becomes cleared, it takes precedence over the other content fields
+	Clear bool
+
+	//StorageClass records the storage class the objects of the dataset were moved to
+	StorageClass string
 }
 
 // UpdateDatasetOutput is the output for UpdateDataset
@@ -23,7 +52,7 @@ type UpdateDatasetOutput struct {
 }
 
 // UpdateDataset will update a dataset resource.
-// Fields that can be updated: name, input, output and size. Input and output are the jobs the dataset is used for or coming from.
+// Fields that can be updated: name, input, output, logs, size, state, archive layers and digests, or the content can be marked as cleared. Input and output are the jobs the dataset is used for or coming from.
 func (k *Kube) UpdateDataset(ctx context.Context, in *UpdateDatasetInput) (out *UpdateDatasetOutput, err error) {
 	dataset := &datasetsv1.Dataset{}
 	err = k.visor.GetResource(ctx, kubevisor.ResourceTypeDatasets, dataset, in.Name)
@@ -43,6 +72,42 @@ func (k *Kube) UpdateDataset(ctx context.Context, in *UpdateDatasetInput) (out *
 	if in.OutputFrom != "" {
 		dataset.Spec.OutputFrom = append(dataset.Spec.OutputFrom, in.OutputFrom)
 	}
+	if in.LogsOf != "" {
+		dataset.Spec.LogsOf = append(dataset.Spec.LogsOf, in.LogsOf)
+	}
+	if in.ResetLayers {
+		dataset.Spec.ArchiverOptions.TarArchiverLayers = nil
+	}
+	if in.AppendLayer != "" {
+		dataset.Spec.ArchiverOptions.TarArchiverLayers = append(dataset.Spec.ArchiverOptions.TarArchiverLayers, in.AppendLayer)
+		dataset.Spec.Size += in.LayerSize
+	}
+	if dataset.Spec.Appending += in.AddAppending; dataset.Spec.Appending < 0 {
+		dataset.Spec.Appending = 0
+	}
+	if in.Digests != nil {
+		dataset.Spec.Digests = in.Digests
+	}
+	if len(in.AddDigests) > 0 && dataset.Spec.Digests == nil {
+		dataset.Spec.Digests = map[string]string{}
+	}
+	for k, sum := range in.AddDigests {
+		dataset.Spec.Digests[k] = sum
+	}
+	if in.Clear {
+		dataset.Spec.Size = 0
+		dataset.Spec.ArchiverOptions.TarArchiverLayers = nil
+		dataset.Spec.Digests = nil
+		dataset.Spec.State = datasetsv1.DatasetStateCleared
+		dataset.Spec.StateMessage = ""
+	}
+	if in.StorageClass != "" {
+		dataset.Spec.StoreOptions.S3StoreStorageClass = in.StorageClass
+	}
+	if in.State != "" {
+		dataset.Spec.State = in.State
+		dataset.Spec.StateMessage = in.StateMessage
+	}
 
 	err = k.visor.UpdateResource(ctx, kubevisor.ResourceTypeDatasets, dataset, in.Name)
 	if err != nil {
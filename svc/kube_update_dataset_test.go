@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	datasetsv1 "github.com/nerdalize/nerd/crd/pkg/apis/stable.nerdalize.com/v1"
 	"github.com/nerdalize/nerd/pkg/transfer/archiver"
 	"github.com/nerdalize/nerd/pkg/transfer/store"
 	"github.com/nerdalize/nerd/svc"
@@ -53,4 +54,89 @@ func TestUpdateDataset(t *testing.T) {
 	equals(t, o.Size, o2.Size)
 	equals(t, o.InputFor, o2.InputFor)
 	equals(t, o.OutputFrom, o2.OutputFrom)
+	equals(t, datasetsv1.DatasetStateCreating, o2.State)
+
+	//Check if the state and its message are replaced together
+	_, err = kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{
+		Name:         out.Name,
+		State:        datasetsv1.DatasetStateFailed,
+		StateMessage: "upload interrupted",
+	})
+	ok(t, err)
+
+	_, err = kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{
+		Name:  out.Name,
+		State: datasetsv1.DatasetStateReady,
+	})
+	ok(t, err)
+
+	o3, err := kube.GetDataset(ctx, &svc.GetDatasetInput{Name: out.Name})
+	ok(t, err)
+	equals(t, datasetsv1.DatasetStateReady, o3.State)
+	equals(t, "", o3.StateMessage)
+	assert(t, o3.State.IsReady(), "expected dataset to be ready")
+
+	//Check if layers accumulate their size until they are reset
+	for _, l := range []string{"abc/layers/1/", "abc/layers/2/"} {
+		_, err = kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{
+			Name:        out.Name,
+			AppendLayer: l,
+			LayerSize:   10,
+		})
+		ok(t, err)
+	}
+
+	o4, err := kube.GetDataset(ctx, &svc.GetDatasetInput{Name: out.Name})
+	ok(t, err)
+	equals(t, []string{"abc/layers/1/", "abc/layers/2/"}, o4.ArchiverOptions.TarArchiverLayers)
+	equals(t, uint64(1357), o4.Size)
+
+	_, err = kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{
+		Name:        out.Name,
+		ResetLayers: true,
+	})
+	ok(t, err)
+
+	o5, err := kube.GetDataset(ctx, &svc.GetDatasetInput{Name: out.Name})
+	ok(t, err)
+	equals(t, 0, len(o5.ArchiverOptions.TarArchiverLayers))
+
+	//Check if digests are replaced as a whole and added to
+	_, err = kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{
+		Name:    out.Name,
+		Digests: map[string]string{"abc/archive.tar": "aa"},
+	})
+	ok(t, err)
+
+	_, err = kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{
+		Name:       out.Name,
+		AddDigests: map[string]string{"abc/layers/3/archive.tar": "bb"},
+	})
+	ok(t, err)
+
+	o6, err := kube.GetDataset(ctx, &svc.GetDatasetInput{Name: out.Name})
+	ok(t, err)
+	equals(t, map[string]string{"abc/archive.tar": "aa", "abc/layers/3/archive.tar": "bb"}, o6.Digests)
+
+	//Check if clearing resets the content without marking the dataset as ready
+	_, err = kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{
+		Name:        out.Name,
+		AppendLayer: "abc/layers/4/",
+		LayerSize:   10,
+	})
+	ok(t, err)
+
+	_, err = kube.UpdateDataset(ctx, &svc.UpdateDatasetInput{
+		Name:  out.Name,
+		Clear: true,
+	})
+	ok(t, err)
+
+	o7, err := kube.GetDataset(ctx, &svc.GetDatasetInput{Name: out.Name})
+	ok(t, err)
+	equals(t, uint64(0), o7.Size)
+	equals(t, 0, len(o7.ArchiverOptions.TarArchiverLayers))
+	equals(t, 0, len(o7.Digests))
+	equals(t, datasetsv1.DatasetStateCleared, o7.State)
+	assert(t, !o7.State.IsReady(), "expected cleared dataset not to be ready")
 }